@@ -0,0 +1,609 @@
+// The build package provides functions for constructing parse trees.
+//
+// Each function returns a valid node whose tokens have the correct types and
+// bytes, and whose prefixes contain default spacing, so that writing the node
+// produces readable source code. Statements within a block are placed on
+// separate lines, and indented with tabs according to their depth.
+//
+// Nodes received as arguments become a part of the returned node, and may have
+// the prefixes of their tokens modified; a node must not be passed to more
+// than one function. The offsets of tokens are not set; File does so for the
+// entire tree, and otherwise tree.FixTokenOffsets may be used.
+package build
+
+import (
+	"bytes"
+	"github.com/anaminus/luasyntax/go/token"
+	"github.com/anaminus/luasyntax/go/tree"
+	"math"
+	"strings"
+)
+
+// tok returns a token of type t, with the bytes of the token set to the string
+// representation of t.
+func tok(t token.Type) tree.Token {
+	return tree.Token{Type: t, Bytes: []byte(t.String())}
+}
+
+// nameTok returns a NAME token.
+func nameTok(name string) tree.Token {
+	return tree.Token{Type: token.NAME, Bytes: []byte(name)}
+}
+
+// space sets the prefix of tok to whitespace s, if the token has no prefix.
+func space(tok *tree.Token, s string) {
+	if tok != nil && len(tok.Prefix) == 0 {
+		tok.Prefix = []tree.Prefix{{Type: token.SPACE, Bytes: []byte(s)}}
+	}
+}
+
+// spaceNode sets the prefix of the first token of node to whitespace s, if the
+// token has no prefix.
+func spaceNode(node tree.Node, s string) {
+	if node != nil {
+		space(node.FirstToken(), s)
+	}
+}
+
+// indenter indents each line within the prefixes of a tree.
+type indenter struct{}
+
+func (v indenter) Visit(tree.Node) tree.Visitor { return v }
+
+func (v indenter) VisitToken(_ tree.Node, _ int, tok *tree.Token) {
+	for i, p := range tok.Prefix {
+		if p.Type == token.SPACE {
+			tok.Prefix[i].Bytes = bytes.Replace(p.Bytes, []byte{'\n'}, []byte{'\n', '\t'}, -1)
+		}
+	}
+}
+
+// block returns a Block containing stmts, with each statement on a separate
+// line, and indented one level.
+func block(stmts []tree.Stmt) tree.Block {
+	b := tree.Block{Items: stmts, Seps: make([]tree.Token, len(stmts))}
+	for _, stmt := range stmts {
+		spaceNode(stmt, "\n")
+	}
+	tree.Walk(indenter{}, &b)
+	return b
+}
+
+// closeBlock sets the prefix of tok, which closes a block containing body. The
+// token is placed on a separate line, or on the same line if body is empty.
+func closeBlock(tok *tree.Token, body []tree.Stmt) {
+	if len(body) == 0 {
+		space(tok, " ")
+	} else {
+		space(tok, "\n")
+	}
+}
+
+// exprList returns an ExprList containing exprs, separated by commas.
+func exprList(exprs []tree.Expr) tree.ExprList {
+	l := tree.ExprList{Items: exprs}
+	if len(exprs) > 1 {
+		l.Seps = make([]tree.Token, len(exprs)-1)
+	}
+	for i := range l.Seps {
+		l.Seps[i] = tok(token.COMMA)
+		spaceNode(exprs[i+1], " ")
+	}
+	return l
+}
+
+// nameList returns a NameList containing names, separated by commas.
+func nameList(names []string) tree.NameList {
+	l := tree.NameList{Items: make([]tree.Token, len(names))}
+	if len(names) > 1 {
+		l.Seps = make([]tree.Token, len(names)-1)
+	}
+	for i, name := range names {
+		l.Items[i] = nameTok(name)
+		if i > 0 {
+			l.Seps[i-1] = tok(token.COMMA)
+			space(&l.Items[i], " ")
+		}
+	}
+	return l
+}
+
+// File returns a File containing stmts, with each statement on a separate
+// line. The offsets of the tokens in the file are set, and the line
+// information of the file is generated.
+func File(stmts ...tree.Stmt) *tree.File {
+	f := &tree.File{
+		Info: token.NewFile(""),
+		Body: tree.Block{Items: stmts, Seps: make([]tree.Token, len(stmts))},
+	}
+	for i, stmt := range stmts {
+		if i > 0 {
+			spaceNode(stmt, "\n")
+		}
+	}
+	f.EOFToken = tree.Token{Type: token.EOF}
+	if len(stmts) > 0 {
+		space(&f.EOFToken, "\n")
+	}
+	tree.FixTokenOffsets(f, 0)
+	return f
+}
+
+// Name returns a variable expression that refers to name.
+func Name(name string) *tree.VariableExpr {
+	return &tree.VariableExpr{NameToken: nameTok(name)}
+}
+
+// Nil returns a nil expression.
+func Nil() *tree.NilExpr {
+	return &tree.NilExpr{NilToken: tok(token.NIL)}
+}
+
+// Bool returns a boolean expression with value v.
+func Bool(v bool) *tree.BoolExpr {
+	e := &tree.BoolExpr{}
+	e.FormatValue(v)
+	return e
+}
+
+// True returns a true expression.
+func True() *tree.BoolExpr {
+	return Bool(true)
+}
+
+// False returns a false expression.
+func False() *tree.BoolExpr {
+	return Bool(false)
+}
+
+// VarArg returns a variable argument expression.
+func VarArg() *tree.VarArgExpr {
+	return &tree.VarArgExpr{VarArgToken: tok(token.VARARG)}
+}
+
+// Num returns an expression with the numeric value v, using the shortest
+// representation that reads back as the same value. A negative number is
+// returned as a UnopExpr, and an infinity or NaN as a BinopExpr that divides
+// by zero.
+func Num(v float64) tree.Expr {
+	switch {
+	case math.IsNaN(v):
+		return Binop(Num(0), token.SLASH, Num(0))
+	case math.IsInf(v, 0):
+		return Binop(Num(math.Copysign(1, v)), token.SLASH, Num(0))
+	}
+	e := &tree.NumberExpr{}
+	e.FormatValue(v, 'r', -1)
+	if math.Signbit(v) {
+		return Unop(token.MINUS, e)
+	}
+	return e
+}
+
+// Str returns a string expression with value v, which is enclosed in double
+// quotes.
+func Str(v string) *tree.StringExpr {
+	e := &tree.StringExpr{StringToken: tree.Token{Type: token.STRING}}
+	e.FormatValue(v, false)
+	return e
+}
+
+// Unop returns a unary expression that applies op to operand. The operand is
+// enclosed in parentheses as needed to retain the meaning of the expression.
+func Unop(op token.Type, operand tree.Expr) *tree.UnopExpr {
+	e := &tree.UnopExpr{UnopToken: tok(op)}
+	e.Operand = Parenthesize(e, "Operand", operand)
+	if first := e.Operand.FirstToken(); op == token.NOT || op == first.Type {
+		// Separate keywords, and avoid forming a comment.
+		space(first, " ")
+	}
+	return e
+}
+
+// Binop returns a binary expression that applies op to left and right. The
+// operands are enclosed in parentheses as needed to retain the meaning of the
+// expression.
+func Binop(left tree.Expr, op token.Type, right tree.Expr) *tree.BinopExpr {
+	e := &tree.BinopExpr{BinopToken: tok(op)}
+	e.Left = Parenthesize(e, "Left", left)
+	e.Right = Parenthesize(e, "Right", right)
+	space(&e.BinopToken, " ")
+	spaceNode(e.Right, " ")
+	return e
+}
+
+// Paren returns e enclosed in parentheses.
+func Paren(e tree.Expr) *tree.ParenExpr {
+	return &tree.ParenExpr{
+		LParenToken: tok(token.LPAREN),
+		Value:       e,
+		RParenToken: tok(token.RPAREN),
+	}
+}
+
+// isPrefixExpr returns whether expr may be used as the value of a call, field,
+// index, or method expression without parentheses.
+func isPrefixExpr(expr tree.Expr) bool {
+	switch expr.(type) {
+	case *tree.VariableExpr, *tree.ParenExpr, *tree.FieldExpr, *tree.IndexExpr,
+		*tree.MethodExpr, *tree.CallExpr:
+		return true
+	}
+	return false
+}
+
+// Parenthesize returns expr, enclosed in parentheses if necessary for it to
+// retain its meaning when stored in the named field of parent, as reported by
+// tree.Cursor. Only the type of parent and the type of its operator token are
+// considered.
+func Parenthesize(parent tree.Node, name string, expr tree.Expr) tree.Expr {
+	need := false
+	switch p := parent.(type) {
+	case *tree.FieldExpr, *tree.IndexExpr, *tree.MethodExpr, *tree.CallExpr:
+		need = name == "Value" && !isPrefixExpr(expr)
+	case *tree.UnopExpr:
+		if e, ok := expr.(*tree.BinopExpr); ok {
+			need = e.BinopToken.Type.Precedence()[0] <= token.UnaryPrecedence
+		}
+	case *tree.BinopExpr:
+		prec := p.BinopToken.Type.Precedence()
+		switch e := expr.(type) {
+		case *tree.UnopExpr:
+			need = name == "Left" && prec[0] > token.UnaryPrecedence
+		case *tree.BinopExpr:
+			if name == "Left" {
+				need = e.BinopToken.Type.Precedence()[1] < prec[0]
+			} else {
+				need = e.BinopToken.Type.Precedence()[0] <= prec[1]
+			}
+		}
+	}
+	if !need {
+		return expr
+	}
+	return Paren(expr)
+}
+
+// Field returns an expression that indexes value with name.
+func Field(value tree.Expr, name string) *tree.FieldExpr {
+	e := &tree.FieldExpr{DotToken: tok(token.DOT), NameToken: nameTok(name)}
+	e.Value = Parenthesize(e, "Value", value)
+	return e
+}
+
+// bracket separates expression e from an enclosing LBRACK, if necessary to
+// avoid forming a long bracket.
+func bracket(e tree.Expr) {
+	if e.FirstToken().Type == token.LONGSTRING {
+		spaceNode(e, " ")
+	}
+}
+
+// Index returns an expression that indexes value with index.
+func Index(value, index tree.Expr) *tree.IndexExpr {
+	bracket(index)
+	e := &tree.IndexExpr{
+		LBrackToken: tok(token.LBRACK),
+		Index:       index,
+		RBrackToken: tok(token.RBRACK),
+	}
+	e.Value = Parenthesize(e, "Value", value)
+	return e
+}
+
+// listArgs returns a ListArgs containing args.
+func listArgs(args []tree.Expr) *tree.ListArgs {
+	a := &tree.ListArgs{LParenToken: tok(token.LPAREN), RParenToken: tok(token.RPAREN)}
+	if len(args) > 0 {
+		l := exprList(args)
+		a.Values = &l
+	}
+	return a
+}
+
+// Call returns an expression that calls fn with args.
+func Call(fn tree.Expr, args ...tree.Expr) *tree.CallExpr {
+	e := &tree.CallExpr{Args: listArgs(args)}
+	e.Value = Parenthesize(e, "Value", fn)
+	return e
+}
+
+// Method returns an expression that calls the method name of value with args.
+func Method(value tree.Expr, name string, args ...tree.Expr) *tree.MethodExpr {
+	e := &tree.MethodExpr{
+		ColonToken: tok(token.COLON),
+		NameToken:  nameTok(name),
+		Args:       listArgs(args),
+	}
+	e.Value = Parenthesize(e, "Value", value)
+	return e
+}
+
+// Table returns a table constructor containing entries, which are separated
+// by commas.
+func Table(entries ...tree.Entry) *tree.TableCtor {
+	t := &tree.TableCtor{
+		LBraceToken: tok(token.LBRACE),
+		Entries:     tree.EntryList{Items: entries},
+		RBraceToken: tok(token.RBRACE),
+	}
+	if len(entries) > 1 {
+		t.Entries.Seps = make([]tree.Token, len(entries)-1)
+	}
+	for i := range t.Entries.Seps {
+		t.Entries.Seps[i] = tok(token.COMMA)
+		spaceNode(entries[i+1], " ")
+	}
+	return t
+}
+
+// ValueEntry returns a table entry that sets value at the next integer key.
+func ValueEntry(value tree.Expr) *tree.ValueEntry {
+	return &tree.ValueEntry{Value: value}
+}
+
+// FieldEntry returns a table entry that sets value at key name.
+func FieldEntry(name string, value tree.Expr) *tree.FieldEntry {
+	e := &tree.FieldEntry{NameToken: nameTok(name), AssignToken: tok(token.ASSIGN), Value: value}
+	space(&e.AssignToken, " ")
+	spaceNode(value, " ")
+	return e
+}
+
+// IndexEntry returns a table entry that sets value at key.
+func IndexEntry(key, value tree.Expr) *tree.IndexEntry {
+	e := &tree.IndexEntry{
+		LBrackToken: tok(token.LBRACK),
+		Key:         key,
+		RBrackToken: tok(token.RBRACK),
+		AssignToken: tok(token.ASSIGN),
+		Value:       value,
+	}
+	bracket(key)
+	space(&e.AssignToken, " ")
+	spaceNode(value, " ")
+	return e
+}
+
+// function returns a FunctionExpr with the given parameters and body. If the
+// last parameter is "...", then the function receives variable arguments.
+func function(params []string, body []tree.Stmt) tree.FunctionExpr {
+	f := tree.FunctionExpr{
+		FuncToken:   tok(token.FUNCTION),
+		LParenToken: tok(token.LPAREN),
+		RParenToken: tok(token.RPAREN),
+		Body:        block(body),
+		EndToken:    tok(token.END),
+	}
+	if n := len(params); n > 0 && params[n-1] == "..." {
+		params = params[:n-1]
+		f.VarArgToken = tok(token.VARARG)
+		if len(params) > 0 {
+			f.VarArgSepToken = tok(token.COMMA)
+			space(&f.VarArgToken, " ")
+		}
+	}
+	if len(params) > 0 {
+		l := nameList(params)
+		f.Params = &l
+	}
+	closeBlock(&f.EndToken, body)
+	return f
+}
+
+// Function returns a function expression with the given parameters and body.
+// If the last parameter is "...", then the function receives variable
+// arguments.
+func Function(params []string, body ...tree.Stmt) *tree.FunctionExpr {
+	f := function(params, body)
+	return &f
+}
+
+// Local returns a statement that declares local variables names, assigned to
+// values. If values is empty, then the variables are declared without
+// assignment.
+func Local(names []string, values ...tree.Expr) *tree.LocalVarStmt {
+	s := &tree.LocalVarStmt{LocalToken: tok(token.LOCAL), Names: nameList(names)}
+	space(&s.Names.Items[0], " ")
+	if len(values) > 0 {
+		s.AssignToken = tok(token.ASSIGN)
+		space(&s.AssignToken, " ")
+		l := exprList(values)
+		s.Values = &l
+		spaceNode(values[0], " ")
+	}
+	return s
+}
+
+// Assign returns a statement that assigns values on the right to the
+// variables on the left.
+func Assign(left []tree.Expr, right ...tree.Expr) *tree.AssignStmt {
+	s := &tree.AssignStmt{Left: exprList(left), AssignToken: tok(token.ASSIGN), Right: exprList(right)}
+	space(&s.AssignToken, " ")
+	spaceNode(right[0], " ")
+	return s
+}
+
+// CallStmt returns a statement that evaluates call.
+func CallStmt(call tree.Call) *tree.CallStmt {
+	return &tree.CallStmt{Call: call}
+}
+
+// Do returns a do statement containing body.
+func Do(body ...tree.Stmt) *tree.DoStmt {
+	s := &tree.DoStmt{DoToken: tok(token.DO), Body: block(body), EndToken: tok(token.END)}
+	closeBlock(&s.EndToken, body)
+	return s
+}
+
+// If returns an if statement that executes body when cond is true. ElseIf and
+// Else may be used to add further clauses.
+func If(cond tree.Expr, body ...tree.Stmt) *tree.IfStmt {
+	s := &tree.IfStmt{
+		IfToken:   tok(token.IF),
+		Cond:      cond,
+		ThenToken: tok(token.THEN),
+		Body:      block(body),
+		EndToken:  tok(token.END),
+	}
+	spaceNode(cond, " ")
+	space(&s.ThenToken, " ")
+	space(&s.EndToken, "\n")
+	return s
+}
+
+// ElseIf appends to s a clause that executes body when cond is true, and
+// returns s. Panics if s already has an else clause.
+func ElseIf(s *tree.IfStmt, cond tree.Expr, body ...tree.Stmt) *tree.IfStmt {
+	if s.Else != nil {
+		panic("build: else-if clause after else clause")
+	}
+	c := tree.ElseIfClause{
+		ElseIfToken: tok(token.ELSEIF),
+		Cond:        cond,
+		ThenToken:   tok(token.THEN),
+		Body:        block(body),
+	}
+	space(&c.ElseIfToken, "\n")
+	spaceNode(cond, " ")
+	space(&c.ThenToken, " ")
+	s.ElseIf = append(s.ElseIf, c)
+	return s
+}
+
+// Else sets the clause of s that executes body when no other condition is
+// true, and returns s.
+func Else(s *tree.IfStmt, body ...tree.Stmt) *tree.IfStmt {
+	s.Else = &tree.ElseClause{ElseToken: tok(token.ELSE), Body: block(body)}
+	space(&s.Else.ElseToken, "\n")
+	return s
+}
+
+// While returns a statement that executes body while cond is true.
+func While(cond tree.Expr, body ...tree.Stmt) *tree.WhileStmt {
+	s := &tree.WhileStmt{
+		WhileToken: tok(token.WHILE),
+		Cond:       cond,
+		DoToken:    tok(token.DO),
+		Body:       block(body),
+		EndToken:   tok(token.END),
+	}
+	spaceNode(cond, " ")
+	space(&s.DoToken, " ")
+	closeBlock(&s.EndToken, body)
+	return s
+}
+
+// Repeat returns a statement that executes body until cond is true.
+func Repeat(cond tree.Expr, body ...tree.Stmt) *tree.RepeatStmt {
+	s := &tree.RepeatStmt{
+		RepeatToken: tok(token.REPEAT),
+		Body:        block(body),
+		UntilToken:  tok(token.UNTIL),
+		Cond:        cond,
+	}
+	closeBlock(&s.UntilToken, body)
+	spaceNode(cond, " ")
+	return s
+}
+
+// NumericFor returns a statement that executes body for each value of the
+// variable name from min to max, incremented by step. If step is nil, then it
+// is omitted.
+func NumericFor(name string, min, max, step tree.Expr, body ...tree.Stmt) *tree.NumericForStmt {
+	s := &tree.NumericForStmt{
+		ForToken:    tok(token.FOR),
+		NameToken:   nameTok(name),
+		AssignToken: tok(token.ASSIGN),
+		Min:         min,
+		MaxSepToken: tok(token.COMMA),
+		Max:         max,
+		DoToken:     tok(token.DO),
+		Body:        block(body),
+		EndToken:    tok(token.END),
+	}
+	space(&s.NameToken, " ")
+	space(&s.AssignToken, " ")
+	spaceNode(min, " ")
+	spaceNode(max, " ")
+	if step != nil {
+		s.StepSepToken = tok(token.COMMA)
+		s.Step = step
+		spaceNode(step, " ")
+	}
+	space(&s.DoToken, " ")
+	closeBlock(&s.EndToken, body)
+	return s
+}
+
+// GenericFor returns a statement that executes body for each set of values
+// produced by iterator, assigned to the variables names.
+func GenericFor(names []string, iterator []tree.Expr, body ...tree.Stmt) *tree.GenericForStmt {
+	s := &tree.GenericForStmt{
+		ForToken: tok(token.FOR),
+		Names:    nameList(names),
+		InToken:  tok(token.IN),
+		Iterator: exprList(iterator),
+		DoToken:  tok(token.DO),
+		Body:     block(body),
+		EndToken: tok(token.END),
+	}
+	space(&s.Names.Items[0], " ")
+	space(&s.InToken, " ")
+	spaceNode(iterator[0], " ")
+	space(&s.DoToken, " ")
+	closeBlock(&s.EndToken, body)
+	return s
+}
+
+// LocalFunction returns a statement that declares a local function name with
+// the given parameters and body. If the last parameter is "...", then the
+// function receives variable arguments.
+func LocalFunction(name string, params []string, body ...tree.Stmt) *tree.LocalFunctionStmt {
+	s := &tree.LocalFunctionStmt{
+		LocalToken: tok(token.LOCAL),
+		NameToken:  nameTok(name),
+		Func:       function(params, body),
+	}
+	space(&s.Func.FuncToken, " ")
+	space(&s.NameToken, " ")
+	return s
+}
+
+// FunctionStmt returns a statement that assigns a function with the given
+// parameters and body to name. The name is a sequence of names separated by
+// dots, optionally followed by a colon and a method name, as in "a.b:c". If
+// the last parameter is "...", then the function receives variable
+// arguments.
+func FunctionStmt(name string, params []string, body ...tree.Stmt) *tree.FunctionStmt {
+	s := &tree.FunctionStmt{Func: function(params, body)}
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		s.Name.ColonToken = tok(token.COLON)
+		s.Name.MethodToken = nameTok(name[i+1:])
+		name = name[:i]
+	}
+	for i, name := range strings.Split(name, ".") {
+		if i > 0 {
+			s.Name.Seps = append(s.Name.Seps, tok(token.DOT))
+		}
+		s.Name.Items = append(s.Name.Items, nameTok(name))
+	}
+	space(&s.Name.Items[0], " ")
+	return s
+}
+
+// Return returns a statement that returns values.
+func Return(values ...tree.Expr) *tree.ReturnStmt {
+	s := &tree.ReturnStmt{ReturnToken: tok(token.RETURN)}
+	if len(values) > 0 {
+		l := exprList(values)
+		s.Values = &l
+		spaceNode(values[0], " ")
+	}
+	return s
+}
+
+// Break returns a break statement.
+func Break() *tree.BreakStmt {
+	return &tree.BreakStmt{BreakToken: tok(token.BREAK)}
+}
@@ -0,0 +1,191 @@
+package build
+
+import (
+	"bytes"
+	"github.com/anaminus/luasyntax/go/parser"
+	"github.com/anaminus/luasyntax/go/token"
+	"github.com/anaminus/luasyntax/go/tree"
+	"math"
+	"testing"
+)
+
+func source(node tree.Node) string {
+	var buf bytes.Buffer
+	node.WriteTo(&buf)
+	return buf.String()
+}
+
+// validator records the first invalid node within a tree.
+type validator struct {
+	invalid tree.Node
+}
+
+func (v *validator) Visit(node tree.Node) tree.Visitor {
+	if v.invalid != nil {
+		return nil
+	}
+	if !node.IsValid() {
+		v.invalid = node
+		return nil
+	}
+	return v
+}
+
+// checkFile checks that f is valid, and that its source parses to an
+// equivalent tree.
+func checkFile(t *testing.T, f *tree.File) {
+	t.Helper()
+	var v validator
+	tree.Walk(&v, f)
+	if v.invalid != nil {
+		t.Errorf("%q: invalid %T", source(f), v.invalid)
+		return
+	}
+	g, err := parser.ParseFile("", source(f))
+	if err != nil {
+		t.Errorf("%q: unexpected error %s", source(f), err)
+		return
+	}
+	if !tree.Equal(f, g, 0) {
+		t.Errorf("%q: expected equal tree after parsing", source(f))
+	}
+}
+
+func TestExprs(t *testing.T) {
+	tests := []struct {
+		expr tree.Expr
+		want string
+	}{
+		{Name("x"), "x"},
+		{Nil(), "nil"},
+		{True(), "true"},
+		{False(), "false"},
+		{Bool(true), "true"},
+		{VarArg(), "..."},
+		{Num(1.5), "1.5"},
+		{Num(-2), "-2"},
+		{Num(math.Inf(1)), "1 / 0"},
+		{Num(math.Inf(-1)), "-1 / 0"},
+		{Num(math.NaN()), "0 / 0"},
+		{Str("a\"b\n"), `"a\"b\n"`},
+		{Unop(token.NOT, Name("x")), "not x"},
+		{Unop(token.MINUS, Unop(token.MINUS, Name("x"))), "- -x"},
+		{Unop(token.MINUS, Binop(Name("a"), token.PLUS, Name("b"))), "-(a + b)"},
+		{Unop(token.MINUS, Binop(Name("a"), token.CARET, Name("b"))), "-a ^ b"},
+		{Binop(Binop(Name("a"), token.PLUS, Name("b")), token.ASTERISK, Name("c")), "(a + b) * c"},
+		{Binop(Name("a"), token.ASTERISK, Binop(Name("b"), token.PLUS, Name("c"))), "a * (b + c)"},
+		{Binop(Binop(Name("a"), token.MINUS, Name("b")), token.MINUS, Name("c")), "a - b - c"},
+		{Binop(Name("a"), token.MINUS, Binop(Name("b"), token.MINUS, Name("c"))), "a - (b - c)"},
+		{Binop(Name("a"), token.CONCAT, Binop(Name("b"), token.CONCAT, Name("c"))), "a .. b .. c"},
+		{Binop(Binop(Name("a"), token.CARET, Name("b")), token.CARET, Name("c")), "(a ^ b) ^ c"},
+		{Binop(Unop(token.MINUS, Name("a")), token.CARET, Name("b")), "(-a) ^ b"},
+		{Paren(Name("x")), "(x)"},
+		{Field(Name("a"), "b"), "a.b"},
+		{Field(Str("s"), "len"), `("s").len`},
+		{Index(Name("t"), Num(1)), "t[1]"},
+		{Call(Name("f")), "f()"},
+		{Call(Name("f"), Name("a"), Num(1)), "f(a, 1)"},
+		{Call(Function(nil), Name("a")), "(function() end)(a)"},
+		{Method(Name("obj"), "m", Str("x")), `obj:m("x")`},
+		{Table(), "{}"},
+		{Table(ValueEntry(Num(1)), FieldEntry("a", Num(2)), IndexEntry(Str("b"), Num(3))), `{1, a = 2, ["b"] = 3}`},
+		{Function([]string{"a", "..."}, Return(VarArg())), "function(a, ...)\n\treturn ...\nend"},
+	}
+	for _, test := range tests {
+		if got := source(test.expr); got != test.want {
+			t.Errorf("expected %q, got %q", test.want, got)
+		}
+		checkFile(t, File(Return(test.expr)))
+	}
+}
+
+func TestStmts(t *testing.T) {
+	tests := []struct {
+		stmt tree.Stmt
+		want string
+	}{
+		{Local([]string{"a", "b"}), "local a, b"},
+		{Local([]string{"a"}, Num(1)), "local a = 1"},
+		{Assign([]tree.Expr{Name("a"), Field(Name("t"), "x")}, Num(1), Num(2)), "a, t.x = 1, 2"},
+		{CallStmt(Call(Name("print"), Str("hi"))), `print("hi")`},
+		{Do(), "do end"},
+		{Do(Break()), "do\n\tbreak\nend"},
+		{If(Name("x"), CallStmt(Call(Name("f")))), "if x then\n\tf()\nend"},
+		{
+			Else(ElseIf(If(Name("x")), Name("y"), Return()), CallStmt(Call(Name("g")))),
+			"if x then\nelseif y then\n\treturn\nelse\n\tg()\nend",
+		},
+		{While(True(), Break()), "while true do\n\tbreak\nend"},
+		{Repeat(Name("done")), "repeat until done"},
+		{NumericFor("i", Num(1), Num(10), nil), "for i = 1, 10 do end"},
+		{NumericFor("i", Num(10), Num(1), Num(-1)), "for i = 10, 1, -1 do end"},
+		{
+			GenericFor([]string{"k", "v"}, []tree.Expr{Call(Name("pairs"), Name("t"))}),
+			"for k, v in pairs(t) do end",
+		},
+		{LocalFunction("f", []string{"a"}, Return(Name("a"))), "local function f(a)\n\treturn a\nend"},
+		{FunctionStmt("a.b:c", nil), "function a.b:c() end"},
+		{Return(Name("a"), Name("b")), "return a, b"},
+		{
+			Do(If(Name("x"), While(Name("y"), Break()))),
+			"do\n\tif x then\n\t\twhile y do\n\t\t\tbreak\n\t\tend\n\tend\nend",
+		},
+	}
+	for _, test := range tests {
+		if got := source(test.stmt); got != test.want {
+			t.Errorf("expected %q, got %q", test.want, got)
+		}
+		checkFile(t, File(test.stmt))
+	}
+}
+
+func TestFile(t *testing.T) {
+	f := File(
+		Local([]string{"x"}, Num(1)),
+		CallStmt(Call(Name("print"), Name("x"))),
+	)
+	want := "local x = 1\nprint(x)\n"
+	if got := source(f); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if off := f.Body.Items[1].Offset(); off != 12 {
+		t.Errorf("expected offset 12, got %d", off)
+	}
+	checkFile(t, f)
+
+	if got := source(File()); got != "" {
+		t.Errorf("expected empty file, got %q", got)
+	}
+}
+
+func TestParenthesize(t *testing.T) {
+	binop := func(op token.Type) *tree.BinopExpr {
+		return &tree.BinopExpr{BinopToken: tree.Token{Type: op}}
+	}
+	tests := []struct {
+		parent tree.Node
+		name   string
+		expr   tree.Expr
+		paren  bool
+	}{
+		{&tree.CallExpr{}, "Value", Name("f"), false},
+		{&tree.CallExpr{}, "Value", Str("s"), true},
+		{&tree.FieldExpr{}, "Value", Call(Name("f")), false},
+		{&tree.IndexExpr{}, "Index", Binop(Name("a"), token.PLUS, Name("b")), false},
+		{&tree.UnopExpr{}, "Operand", Binop(Name("a"), token.CARET, Name("b")), false},
+		{&tree.UnopExpr{}, "Operand", Binop(Name("a"), token.ASTERISK, Name("b")), true},
+		{binop(token.ASTERISK), "Left", Binop(Name("a"), token.PLUS, Name("b")), true},
+		{binop(token.PLUS), "Left", Binop(Name("a"), token.ASTERISK, Name("b")), false},
+		{binop(token.PLUS), "Right", Binop(Name("a"), token.PLUS, Name("b")), true},
+		{binop(token.CARET), "Right", Binop(Name("a"), token.CARET, Name("b")), false},
+		{binop(token.CARET), "Left", Unop(token.MINUS, Name("a")), true},
+		{binop(token.PLUS), "Left", Unop(token.MINUS, Name("a")), false},
+		{&tree.ExprList{}, "Items", Binop(Name("a"), token.PLUS, Name("b")), false},
+	}
+	for _, test := range tests {
+		_, paren := Parenthesize(test.parent, test.name, test.expr).(*tree.ParenExpr)
+		if paren != test.paren {
+			t.Errorf("%T.%s with %q: expected paren=%t, got %t", test.parent, test.name, source(test.expr), test.paren, paren)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+// Luagrep searches Lua source files for code that structurally matches a
+// pattern, and optionally rewrites it.
+//
+// Usage:
+//
+//	luagrep [flags] pattern [path ...]
+//
+// The pattern is an expression, a statement, or a sequence of statements, in
+// which a metavariable such as `$x` matches any expression, statement, or
+// name. Each occurrence of a metavariable must match equal code. Whitespace
+// and comments are ignored. For example:
+//
+//	luagrep '$x == nil or $x == false' src
+//
+// Each path is a file, or a directory that is searched recursively for files
+// with a .lua or .luau extension. If no path is given, then standard input is
+// searched.
+//
+// Each match is printed with its position and the first line of its source.
+// With the -r flag, each match is replaced with the given replacement, which
+// may refer to the metavariables of the pattern, and the rewritten source of
+// each file that contains a match is printed instead.
+//
+// The flags are:
+//
+//	-r replacement
+//		Replace each match with replacement.
+//	-w
+//		With -r, write the result to the source file instead of printing.
+//	-l
+//		Print only the names of files that contain matches.
+//	-dialect name
+//		Parse files and patterns as the given dialect: lua51, lua52, lua53,
+//		lua54, luajit, or luau. The default is lua51.
+//
+// The exit status is 0 if a match was found, 1 if no match was found, and 2
+// if an error occurred.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/anaminus/luasyntax/go/match"
+	"github.com/anaminus/luasyntax/go/parser"
+	"github.com/anaminus/luasyntax/go/tmpl"
+	"github.com/anaminus/luasyntax/go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	replacement = flag.String("r", "", "replace each match with `replacement`")
+	write       = flag.Bool("w", false, "with -r, write result to source file instead of printing")
+	list        = flag.Bool("l", false, "print only the names of files that contain matches")
+	dialectName = flag.String("dialect", "lua51", "parse as dialect `name`")
+)
+
+var dialects = map[string]token.Dialect{
+	"lua51":  token.Lua51,
+	"lua52":  token.Lua52,
+	"lua53":  token.Lua53,
+	"lua54":  token.Lua54,
+	"luajit": token.LuaJIT,
+	"luau":   token.Luau,
+}
+
+// grep holds the state of a search.
+type grep struct {
+	config  parser.Config
+	pattern *match.Pattern
+	repl    *tmpl.Template
+	found   bool
+	failed  bool
+}
+
+// fail reports an error, and marks the search as failed.
+func (g *grep) fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	g.failed = true
+}
+
+// file searches the source of a single file.
+func (g *grep) file(filename string, src []byte) error {
+	f, err := g.config.ParseFile(filename, src)
+	if err != nil {
+		return err
+	}
+	matches := g.pattern.Find(f)
+	if len(matches) == 0 {
+		return nil
+	}
+	g.found = true
+	if *list {
+		fmt.Println(filename)
+		return nil
+	}
+	if g.repl == nil {
+		for _, m := range matches {
+			start, end := m.Node.Offset(), m.Node.EndOffset()
+			if start < 0 {
+				// The node has no tokens to report.
+				continue
+			}
+			if end > len(src) {
+				end = len(src)
+			}
+			pos := f.Info.Position(start)
+			text := src[start:end]
+			if i := bytes.IndexByte(text, '\n'); i >= 0 {
+				text = text[:i]
+			}
+			fmt.Printf("%s: %s\n", pos, text)
+		}
+		return nil
+	}
+	if _, _, err := g.pattern.Replace(f, g.repl); err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+	var buf bytes.Buffer
+	if _, err := f.WriteTo(&buf); err != nil {
+		return err
+	}
+	if *write && filename != "<stdin>" {
+		return ioutil.WriteFile(filename, buf.Bytes(), 0666)
+	}
+	_, err = os.Stdout.Write(buf.Bytes())
+	return err
+}
+
+// isLuaFile returns whether a file found while walking a directory is
+// searched.
+func isLuaFile(info os.FileInfo) bool {
+	name := info.Name()
+	return info.Mode().IsRegular() && !strings.HasPrefix(name, ".") &&
+		(strings.HasSuffix(name, ".lua") || strings.HasSuffix(name, ".luau"))
+}
+
+// path searches a file, or the files within a directory. A file given
+// directly is searched regardless of its extension.
+func (g *grep) path(root string) {
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || path != root && !isLuaFile(info) {
+			return nil
+		}
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := g.file(path, src); err != nil {
+			g.fail(err)
+		}
+		return nil
+	})
+	if err != nil {
+		g.fail(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: luagrep [flags] pattern [path ...]")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 1 {
+		usage()
+	}
+	dialect, ok := dialects[*dialectName]
+	if !ok {
+		fmt.Fprintln(os.Stderr, "unknown dialect "+*dialectName)
+		os.Exit(2)
+	}
+
+	g := grep{config: parser.Config{Dialect: dialect}}
+	var err error
+	config := match.Config{Dialect: dialect}
+	if g.pattern, err = config.Compile(flag.Arg(0)); err != nil {
+		fmt.Fprintln(os.Stderr, "pattern:", err)
+		os.Exit(2)
+	}
+	if *replacement != "" {
+		if g.repl, err = g.pattern.ParseReplacement(*replacement); err != nil {
+			fmt.Fprintln(os.Stderr, "replacement:", err)
+			os.Exit(2)
+		}
+	}
+
+	if flag.NArg() == 1 {
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			g.fail(err)
+		} else if err := g.file("<stdin>", src); err != nil {
+			g.fail(err)
+		}
+	}
+	for _, path := range flag.Args()[1:] {
+		g.path(path)
+	}
+
+	switch {
+	case g.failed:
+		os.Exit(2)
+	case !g.found:
+		os.Exit(1)
+	}
+}
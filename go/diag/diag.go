@@ -0,0 +1,478 @@
+// The diag package renders diagnostics for Lua source files. A diagnostic is
+// displayed with excerpts of the source, with the relevant ranges underlined
+// and labeled, along with notes and suggested fixes.
+package diag
+
+import (
+	"bytes"
+	"github.com/anaminus/luasyntax/go/scanner"
+	"github.com/anaminus/luasyntax/go/token"
+	"github.com/anaminus/luasyntax/go/tree"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Severity indicates the seriousness of a diagnostic.
+type Severity int
+
+const (
+	Error   Severity = iota // A problem that prevents the source from being used.
+	Warning                 // A potential problem.
+	Info                    // Informative message.
+)
+
+var severities = [...]string{
+	Error:   "error",
+	Warning: "warning",
+	Info:    "info",
+}
+
+// String returns a string representation of the severity.
+func (s Severity) String() string {
+	if 0 <= s && int(s) < len(severities) {
+		return severities[s]
+	}
+	return "severity(" + strconv.Itoa(int(s)) + ")"
+}
+
+// Span is a range of bytes within a file.
+type Span struct {
+	Start int // Offset of the first byte.
+	End   int // Offset following the last byte.
+}
+
+// TokenSpan returns the span of a token, excluding the prefix of the token.
+func TokenSpan(tok *tree.Token) Span {
+	return Span{Start: tok.Offset, End: tok.EndOffset()}
+}
+
+// FullTokenSpan returns the span of a token, including the prefix of the token.
+func FullTokenSpan(tok *tree.Token) Span {
+	return Span{Start: tok.StartOffset(), End: tok.EndOffset()}
+}
+
+// Label annotates a span of the source with a message.
+type Label struct {
+	Span    Span
+	Message string // Optional.
+}
+
+// Fix is a suggested change to the source, which replaces a span with new
+// text. An empty span inserts the text.
+type Fix struct {
+	Message     string
+	Span        Span
+	Replacement string
+}
+
+// Diagnostic describes a problem within a file.
+type Diagnostic struct {
+	// Severity is the seriousness of the problem.
+	Severity Severity
+	// Message describes the problem.
+	Message string
+	// File is the file in which the problem occurs, which is used to locate
+	// spans. The content of the file must be set for source excerpts to be
+	// displayed.
+	File *token.File
+	// Primary is the location of the problem.
+	Primary Label
+	// Secondary contains additional locations that are related to the
+	// problem.
+	Secondary []Label
+	// Notes contains additional information about the problem.
+	Notes []string
+	// Fixes contains suggested changes that would resolve the problem.
+	Fixes []Fix
+}
+
+// FromError creates an error diagnostic from a scanner error that occurred
+// within file. The span of the diagnostic starts at the offset of the error,
+// and covers one character.
+func FromError(file *token.File, err *scanner.Error) *Diagnostic {
+	off := err.Position.Offset
+	end := off
+	if content := file.Content(); off < len(content) {
+		_, n := utf8.DecodeRune(content[off:])
+		end += n
+	}
+	return &Diagnostic{
+		Severity: Error,
+		Message:  err.Message,
+		File:     file,
+		Primary:  Label{Span: Span{Start: off, End: end}},
+	}
+}
+
+// FromErrorList creates a diagnostic for each error in a list of errors that
+// occurred within file.
+func FromErrorList(file *token.File, list scanner.ErrorList) []*Diagnostic {
+	diags := make([]*Diagnostic, len(list))
+	for i, err := range list {
+		diags[i] = FromError(file, err)
+	}
+	return diags
+}
+
+// ANSI escape sequences used for color output.
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiRed    = "\x1b[1;31m"
+	ansiYellow = "\x1b[1;33m"
+	ansiBlue   = "\x1b[1;34m"
+	ansiCyan   = "\x1b[1;36m"
+	ansiGreen  = "\x1b[1;32m"
+)
+
+var severityColors = [...]string{
+	Error:   ansiRed,
+	Warning: ansiYellow,
+	Info:    ansiCyan,
+}
+
+// Printer renders diagnostics. The zero value is a valid printer that
+// produces plain text.
+type Printer struct {
+	// Color causes the output to be colored with ANSI escape sequences.
+	Color bool
+	// TabWidth is the number of columns between tab stops, used when
+	// displaying source excerpts. If zero or less, 4 is used.
+	TabWidth int
+}
+
+// line is a line of source to be displayed, along with the labels that apply
+// to it.
+type line struct {
+	num     int
+	text    []byte
+	markers []marker
+}
+
+// marker is a label positioned within the display columns of a line.
+type marker struct {
+	start, end int // Display columns.
+	primary    bool
+	message    string
+}
+
+// buffer accumulates output, optionally with color.
+type buffer struct {
+	bytes.Buffer
+	color bool
+}
+
+// style writes s with the given color sequence.
+func (b *buffer) style(seq, s string) {
+	if b.color && seq != "" {
+		b.WriteString(seq)
+		b.WriteString(s)
+		b.WriteString(ansiReset)
+		return
+	}
+	b.WriteString(s)
+}
+
+func (p *Printer) tabWidth() int {
+	if p.TabWidth <= 0 {
+		return 4
+	}
+	return p.TabWidth
+}
+
+// expand returns text with tabs expanded to spaces, and the display column of
+// each byte offset within text, plus the offset at the end of text.
+func (p *Printer) expand(text []byte) (out []byte, cols []int) {
+	tw := p.tabWidth()
+	cols = make([]int, len(text)+1)
+	col := 0
+	for i := 0; i < len(text); {
+		r, n := utf8.DecodeRune(text[i:])
+		for j := 0; j < n; j++ {
+			cols[i+j] = col
+		}
+		if r == '\t' {
+			w := tw - col%tw
+			out = append(out, strings.Repeat(" ", w)...)
+			col += w
+		} else {
+			out = append(out, text[i:i+n]...)
+			col++
+		}
+		i += n
+	}
+	cols[len(text)] = col
+	return out, cols
+}
+
+// addLabel adds a label to the displayed lines, returning the updated lines.
+func (p *Printer) addLabel(lines []*line, file *token.File, label Label, primary bool) []*line {
+	pos := file.Position(label.Span.Start)
+	if !pos.IsValid() {
+		return lines
+	}
+	text, err := file.LineContent(pos.Line)
+	if err != nil {
+		return lines
+	}
+	var l *line
+	for _, m := range lines {
+		if m.num == pos.Line {
+			l = m
+			break
+		}
+	}
+	if l == nil {
+		l = &line{num: pos.Line, text: text}
+		lines = append(lines, l)
+	}
+	_, cols := p.expand(text)
+	lineStart := label.Span.Start - (pos.Column - 1)
+	start := clamp(label.Span.Start-lineStart, 0, len(text))
+	end := clamp(label.Span.End-lineStart, start, len(text))
+	m := marker{
+		start:   cols[start],
+		end:     cols[end],
+		primary: primary,
+		message: label.Message,
+	}
+	if m.end <= m.start {
+		// Always mark at least one column.
+		m.end = m.start + 1
+	}
+	l.markers = append(l.markers, m)
+	return lines
+}
+
+func clamp(n, min, max int) int {
+	if n < min {
+		return min
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
+
+// Fprint renders a diagnostic to w.
+func (p *Printer) Fprint(w io.Writer, d *Diagnostic) error {
+	b := &buffer{color: p.Color}
+	sevColor := ""
+	if 0 <= d.Severity && int(d.Severity) < len(severityColors) {
+		sevColor = severityColors[d.Severity]
+	}
+
+	// Header.
+	b.style(sevColor, d.Severity.String())
+	b.style(ansiBold, ": "+d.Message)
+	b.WriteByte('\n')
+
+	// Collect lines.
+	var lines []*line
+	var pos token.Position
+	if d.File != nil {
+		pos = d.File.Position(d.Primary.Span.Start)
+		lines = p.addLabel(lines, d.File, d.Primary, true)
+		for _, label := range d.Secondary {
+			lines = p.addLabel(lines, d.File, label, false)
+		}
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].num < lines[j].num })
+
+	// Width of the gutter containing line numbers.
+	width := 0
+	for _, l := range lines {
+		if n := len(strconv.Itoa(l.num)); n > width {
+			width = n
+		}
+	}
+	for _, fix := range d.Fixes {
+		if d.File == nil {
+			break
+		}
+		if n := len(strconv.Itoa(d.File.Position(fix.Span.Start).Line)); n > width {
+			width = n
+		}
+	}
+	pad := strings.Repeat(" ", width)
+	gutter := func(num string) {
+		b.style(ansiBlue, num+strings.Repeat(" ", width-len(num))+" |")
+	}
+
+	// Location.
+	if pos.IsValid() || pos.Filename != "" {
+		b.style(ansiBlue, pad+"--> ")
+		b.WriteString(pos.String())
+		b.WriteByte('\n')
+	}
+
+	// Source excerpts.
+	if len(lines) > 0 {
+		gutter("")
+		b.WriteByte('\n')
+	}
+	for i, l := range lines {
+		if i > 0 && l.num > lines[i-1].num+1 {
+			b.style(ansiBlue, "...")
+			b.WriteByte('\n')
+		}
+		p.writeLine(b, l, gutter, sevColor)
+	}
+
+	// Notes.
+	if len(d.Notes) > 0 || len(d.Fixes) > 0 {
+		if len(lines) > 0 {
+			gutter("")
+			b.WriteByte('\n')
+		}
+	}
+	for _, note := range d.Notes {
+		b.style(ansiBlue, pad+" = ")
+		b.style(ansiBold, "note")
+		b.WriteString(": " + note + "\n")
+	}
+
+	// Suggested fixes.
+	for _, fix := range d.Fixes {
+		b.style(ansiBlue, pad+" = ")
+		b.style(ansiGreen, "help")
+		b.WriteString(": " + fix.Message)
+		if !p.writeFix(b, d.File, fix, gutter) {
+			b.WriteString(": `" + fix.Replacement + "`\n")
+		}
+	}
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// writeLine writes a line of source, followed by its markers.
+func (p *Printer) writeLine(b *buffer, l *line, gutter func(string), sevColor string) {
+	text, _ := p.expand(l.text)
+	gutter(strconv.Itoa(l.num))
+	b.WriteByte(' ')
+	b.Write(text)
+	b.WriteByte('\n')
+
+	markers := l.markers
+	sort.SliceStable(markers, func(i, j int) bool { return markers[i].start < markers[j].start })
+
+	// Underlines, with the message of the rightmost marker inline.
+	gutter("")
+	b.WriteByte(' ')
+	col := 0
+	for _, m := range markers {
+		if m.start < col {
+			// Overlaps previous marker.
+			continue
+		}
+		b.WriteString(strings.Repeat(" ", m.start-col))
+		if m.primary {
+			b.style(sevColor, strings.Repeat("^", m.end-m.start))
+		} else {
+			b.style(ansiBlue, strings.Repeat("-", m.end-m.start))
+		}
+		col = m.end
+	}
+	last := markers[len(markers)-1]
+	if last.message != "" {
+		b.WriteByte(' ')
+		if last.primary {
+			b.style(sevColor, last.message)
+		} else {
+			b.style(ansiBlue, last.message)
+		}
+	}
+	b.WriteByte('\n')
+
+	// Remaining messages, from right to left.
+	for i := len(markers) - 2; i >= 0; i-- {
+		m := markers[i]
+		if m.message == "" {
+			continue
+		}
+		gutter("")
+		b.WriteByte(' ')
+		b.WriteString(strings.Repeat(" ", m.start))
+		if m.primary {
+			b.style(sevColor, m.message)
+		} else {
+			b.style(ansiBlue, m.message)
+		}
+		b.WriteByte('\n')
+	}
+}
+
+// writeFix writes the line of source affected by a fix, with the fix
+// applied. Returns false if the fix could not be displayed this way.
+func (p *Printer) writeFix(b *buffer, file *token.File, fix Fix, gutter func(string)) bool {
+	if file == nil || strings.ContainsAny(fix.Replacement, "\n\r") {
+		return false
+	}
+	pos := file.Position(fix.Span.Start)
+	if !pos.IsValid() {
+		return false
+	}
+	text, err := file.LineContent(pos.Line)
+	if err != nil {
+		return false
+	}
+	lineStart := fix.Span.Start - (pos.Column - 1)
+	start := clamp(fix.Span.Start-lineStart, 0, len(text))
+	end := clamp(fix.Span.End-lineStart, start, len(text))
+	if fix.Span.End-lineStart > len(text) {
+		// Span crosses lines.
+		return false
+	}
+	patched := make([]byte, 0, len(text)+len(fix.Replacement))
+	patched = append(patched, text[:start]...)
+	patched = append(patched, fix.Replacement...)
+	patched = append(patched, text[end:]...)
+
+	l := &line{num: pos.Line, text: patched}
+	_, cols := p.expand(patched)
+	m := marker{start: cols[start], end: cols[start+len(fix.Replacement)]}
+	b.WriteByte('\n')
+	gutter(strconv.Itoa(l.num))
+	b.WriteByte(' ')
+	expanded, _ := p.expand(patched)
+	b.Write(expanded)
+	b.WriteByte('\n')
+	gutter("")
+	b.WriteByte(' ')
+	b.WriteString(strings.Repeat(" ", m.start))
+	if m.end > m.start {
+		b.style(ansiGreen, strings.Repeat("+", m.end-m.start))
+	} else {
+		// Deletion.
+		b.style(ansiGreen, "^")
+	}
+	b.WriteByte('\n')
+	return true
+}
+
+// String returns the diagnostic rendered as plain text.
+func (d *Diagnostic) String() string {
+	var b bytes.Buffer
+	var p Printer
+	p.Fprint(&b, d)
+	return b.String()
+}
+
+// FprintAll renders each diagnostic in a list to w, separated by blank lines.
+func (p *Printer) FprintAll(w io.Writer, diags []*Diagnostic) error {
+	for i, d := range diags {
+		if i > 0 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		if err := p.Fprint(w, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
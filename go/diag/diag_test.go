@@ -0,0 +1,241 @@
+package diag
+
+import (
+	"bytes"
+	"github.com/anaminus/luasyntax/go/parser"
+	"github.com/anaminus/luasyntax/go/scanner"
+	"github.com/anaminus/luasyntax/go/token"
+	"strings"
+	"testing"
+)
+
+func TestSeverityString(t *testing.T) {
+	tests := []struct {
+		s    Severity
+		want string
+	}{
+		{Error, "error"},
+		{Warning, "warning"},
+		{Info, "info"},
+		{Severity(7), "severity(7)"},
+		{Severity(-1), "severity(-1)"},
+	}
+	for _, test := range tests {
+		if got := test.s.String(); got != test.want {
+			t.Errorf("%d: expected %q, got %q", int(test.s), test.want, got)
+		}
+	}
+}
+
+func newFile(src string) *token.File {
+	f := token.NewFile("test.lua")
+	f.SetLinesForContent([]byte(src))
+	f.SetContent([]byte(src))
+	return f
+}
+
+func TestFromErrorList(t *testing.T) {
+	tests := []struct {
+		src   string
+		spans []Span
+	}{
+		{"x = 1", []Span{}},
+		{"x = @", []Span{{4, 5}}},
+		{"x = 'é\ny = @ + @", []Span{{4, 5}, {12, 13}, {16, 17}}},
+		{"function f()", []Span{{12, 12}}},
+	}
+	for _, test := range tests {
+		f, err := (&parser.Config{Mode: parser.RecoverErrors | parser.AllErrors}).ParseFile("test.lua", test.src)
+		list, _ := err.(scanner.ErrorList)
+		diags := FromErrorList(f.Info, list)
+		if len(diags) != len(test.spans) {
+			t.Errorf("%q: expected %d diagnostics, got %d", test.src, len(test.spans), len(diags))
+			continue
+		}
+		for i, d := range diags {
+			if d.Severity != Error || d.File != f.Info || d.Message != list[i].Message {
+				t.Errorf("%q: unexpected diagnostic %+v", test.src, d)
+			}
+			if d.Primary.Span != test.spans[i] {
+				t.Errorf("%q: expected span %v, got %v", test.src, test.spans[i], d.Primary.Span)
+			}
+		}
+	}
+}
+
+func TestFromError(t *testing.T) {
+	tests := []struct {
+		src    string
+		offset int
+		span   Span
+	}{
+		{"x = @", 4, Span{4, 5}},
+		{"x = €", 4, Span{4, 7}},
+		{"x = ", 4, Span{4, 4}},
+	}
+	for _, test := range tests {
+		file := newFile(test.src)
+		err := &scanner.Error{Position: file.Position(test.offset), Message: "msg"}
+		if span := FromError(file, err).Primary.Span; span != test.span {
+			t.Errorf("%q: expected span %v, got %v", test.src, test.span, span)
+		}
+	}
+}
+
+func TestFprint(t *testing.T) {
+	tests := []struct {
+		name string
+		diag *Diagnostic
+		want string
+	}{
+		{
+			"message only",
+			&Diagnostic{Severity: Warning, Message: "something"},
+			"warning: something\n",
+		},
+		{
+			"primary",
+			&Diagnostic{
+				Message: "unexpected symbol",
+				File:    newFile("local x = 1\nx = @ + 2\n"),
+				Primary: Label{Span: Span{16, 17}, Message: "here"},
+			},
+			"error: unexpected symbol\n" +
+				" --> test.lua:2:5\n" +
+				"  |\n" +
+				"2 | x = @ + 2\n" +
+				"  |     ^ here\n",
+		},
+		{
+			"secondary",
+			&Diagnostic{
+				Severity:  Warning,
+				Message:   "shadowed variable",
+				File:      newFile("local x = 1\n\n\nlocal x = 2\n"),
+				Primary:   Label{Span: Span{20, 21}, Message: "shadows"},
+				Secondary: []Label{{Span: Span{6, 7}, Message: "declared here"}},
+				Notes:     []string{"rename one of the variables"},
+			},
+			"warning: shadowed variable\n" +
+				" --> test.lua:4:7\n" +
+				"  |\n" +
+				"1 | local x = 1\n" +
+				"  |       - declared here\n" +
+				"...\n" +
+				"4 | local x = 2\n" +
+				"  |       ^ shadows\n" +
+				"  |\n" +
+				"  = note: rename one of the variables\n",
+		},
+		{
+			"same line",
+			&Diagnostic{
+				Message:   "mismatch",
+				File:      newFile("f(abc, de)"),
+				Primary:   Label{Span: Span{7, 9}, Message: "second"},
+				Secondary: []Label{{Span: Span{2, 5}, Message: "first"}},
+			},
+			"error: mismatch\n" +
+				" --> test.lua:1:8\n" +
+				"  |\n" +
+				"1 | f(abc, de)\n" +
+				"  |   ---  ^^ second\n" +
+				"  |   first\n",
+		},
+		{
+			"tabs",
+			&Diagnostic{
+				Message: "tab",
+				File:    newFile("\tx = @"),
+				Primary: Label{Span: Span{5, 6}},
+			},
+			"error: tab\n" +
+				" --> test.lua:1:6\n" +
+				"  |\n" +
+				"1 |     x = @\n" +
+				"  |         ^\n",
+		},
+		{
+			"fix",
+			&Diagnostic{
+				Message: "'end' expected",
+				File:    newFile("if x then y()"),
+				Primary: Label{Span: Span{13, 13}},
+				Fixes:   []Fix{{Message: "add 'end'", Span: Span{13, 13}, Replacement: " end"}},
+			},
+			"error: 'end' expected\n" +
+				" --> test.lua:1:14\n" +
+				"  |\n" +
+				"1 | if x then y()\n" +
+				"  |              ^\n" +
+				"  |\n" +
+				"  = help: add 'end'\n" +
+				"1 | if x then y() end\n" +
+				"  |              ++++\n",
+		},
+		{
+			"multiline fix",
+			&Diagnostic{
+				Message: "bad",
+				File:    newFile("x = @"),
+				Primary: Label{Span: Span{4, 5}},
+				Fixes:   []Fix{{Message: "replace", Span: Span{4, 5}, Replacement: "1\n"}},
+			},
+			"error: bad\n" +
+				" --> test.lua:1:5\n" +
+				"  |\n" +
+				"1 | x = @\n" +
+				"  |     ^\n" +
+				"  |\n" +
+				"  = help: replace: `1\n`\n",
+		},
+	}
+	for _, test := range tests {
+		var p Printer
+		var buf bytes.Buffer
+		if err := p.Fprint(&buf, test.diag); err != nil {
+			t.Errorf("%s: unexpected error %s", test.name, err)
+			continue
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("%s: expected\n%s\ngot\n%s", test.name, test.want, got)
+		}
+		if got := test.diag.String(); got != test.want {
+			t.Errorf("%s: expected String to match Fprint, got\n%s", test.name, got)
+		}
+	}
+}
+
+func TestFprintColor(t *testing.T) {
+	d := &Diagnostic{
+		Message: "unexpected symbol",
+		File:    newFile("x = @"),
+		Primary: Label{Span: Span{4, 5}},
+	}
+	p := Printer{Color: true}
+	var buf bytes.Buffer
+	p.Fprint(&buf, d)
+	got := buf.String()
+	if !strings.Contains(got, ansiRed+"error"+ansiReset) {
+		t.Errorf("expected colored severity, got %q", got)
+	}
+	if !strings.Contains(got, ansiRed+"^"+ansiReset) {
+		t.Errorf("expected colored marker, got %q", got)
+	}
+}
+
+func TestFprintAll(t *testing.T) {
+	diags := []*Diagnostic{
+		{Message: "a"},
+		{Severity: Info, Message: "b"},
+	}
+	var p Printer
+	var buf bytes.Buffer
+	if err := p.FprintAll(&buf, diags); err != nil {
+		t.Fatal(err)
+	}
+	want := "error: a\n\ninfo: b\n"
+	if got := buf.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
@@ -1,6 +1,7 @@
 package extend
 
 import (
+	"github.com/anaminus/luasyntax/go/token"
 	"github.com/anaminus/luasyntax/go/tree"
 )
 
@@ -16,6 +17,15 @@ type FileScope struct {
 	// ScopeMap maps a Node to the scope that is opened by or is otherwise
 	// associated with the node.
 	ScopeMap map[tree.Node]*Scope
+	// Env is the variable representing the implicit _ENV upvalue of the file.
+	// It is nil if the dialect of the file does not have the token.Env
+	// feature.
+	Env *Variable
+	// LabelMap maps the NAME token of a label or goto statement to a Label.
+	LabelMap map[*tree.Token]*Label
+	// UnresolvedGotos is a list of NAME tokens of goto statements that do not
+	// refer to any visible label.
+	UnresolvedGotos []*tree.Token
 }
 
 // Scope contains a list of the variables declared in the scope.
@@ -26,6 +36,8 @@ type Scope struct {
 	Children []*Scope
 	// Variables is the list of variables declared in the scope.
 	Variables []*Variable
+	// Labels is the list of labels declared in the scope.
+	Labels []*Label
 	// Node is the tree node that opens or is otherwise associated with the
 	// scope. May be nil.
 	Node tree.Node
@@ -60,6 +72,7 @@ const (
 
 	LocalVar  // LocalVar indicates a variable local to its scope.
 	GlobalVar // GlobalVar indicates a variable defined in the global table.
+	EnvVar    // EnvVar indicates the implicit _ENV upvalue of a file.
 )
 
 func (t VariableType) String() string {
@@ -68,6 +81,8 @@ func (t VariableType) String() string {
 		return "Local"
 	case GlobalVar:
 		return "Global"
+	case EnvVar:
+		return "Env"
 	}
 	return "<invalid>"
 }
@@ -97,6 +112,21 @@ type Variable struct {
 	// has no objective meaning, and should be used only for comparing with
 	// other lifetimes within the same generated FileScope.
 	ScopeEnd int
+	// Attrib is the name of the attribute of a local variable, such as
+	// "const" or "close". It is empty if the variable has no attribute.
+	Attrib string
+	// Env is the variable through which a global variable is resolved. This
+	// is either the Env of the FileScope, or a local variable named _ENV. It
+	// is nil if the variable is not global, or if the dialect does not have
+	// the token.Env feature.
+	Env *Variable
+}
+
+// IsConst returns whether the variable cannot be assigned to after its
+// declaration. This is the case for local variables with the "const" or
+// "close" attribute.
+func (v *Variable) IsConst() bool {
+	return v.Attrib == "const" || v.Attrib == "close"
 }
 
 // VisiblityOverlapsWith returns whether the visiblity of v overlaps with the
@@ -105,20 +135,40 @@ func (v *Variable) VisiblityOverlapsWith(w *Variable) bool {
 	return v.ScopeEnd >= w.LifeStart && v.LifeStart <= w.ScopeEnd
 }
 
+// Label describes a label, and the goto statements that jump to it.
+type Label struct {
+	// Name is the name of the label.
+	Name string
+	// Declaration is the NAME token of the label statement.
+	Declaration *tree.Token
+	// Scope is the scope in which the label is declared.
+	Scope *Scope
+	// Gotos is a list of NAME tokens of goto statements that jump to the
+	// label.
+	Gotos []*tree.Token
+}
+
 // scopeParser holds the scope state while walking a parse tree. It must be
 // initialized with init before using.
 type scopeParser struct {
 	fileScope    *FileScope
 	currentScope *Scope
 	position     int
+	dialect      token.Dialect
+	// gotos maps a scope to the goto statements that have yet to be resolved
+	// within the scope.
+	gotos map[*Scope][]*tree.Token
 }
 
 // init prepares the parser to walk a parse tree.
-func (p *scopeParser) init() {
+func (p *scopeParser) init(dialect token.Dialect) {
 	p.currentScope = nil
+	p.dialect = dialect
+	p.gotos = map[*Scope][]*tree.Token{}
 	p.fileScope = &FileScope{
 		VariableMap: make(map[*tree.Token]*Variable, 4),
 		ScopeMap:    make(map[tree.Node]*Scope, 4),
+		LabelMap:    make(map[*tree.Token]*Label),
 	}
 }
 
@@ -146,9 +196,62 @@ func (p *scopeParser) closeScope() {
 	for _, v := range p.currentScope.Variables {
 		v.ScopeEnd = p.currentScope.End
 	}
+	p.resolveGotos()
 	p.currentScope = p.currentScope.Parent
 }
 
+// isFuncScope returns whether a scope is opened by a function body, which
+// labels are not visible outside of.
+func isFuncScope(scope *Scope) bool {
+	switch scope.Node.(type) {
+	case *tree.File, *tree.FunctionExpr:
+		return true
+	}
+	return false
+}
+
+// resolveGotos matches each pending goto statement of the current scope with a
+// label declared in the scope. Unmatched gotos are passed to the parent scope,
+// unless the scope is a function body.
+func (p *scopeParser) resolveGotos() {
+	scope := p.currentScope
+	gotos := p.gotos[scope]
+	delete(p.gotos, scope)
+loop:
+	for _, name := range gotos {
+		for _, label := range scope.Labels {
+			if label.Name == string(name.Bytes) {
+				label.Gotos = append(label.Gotos, name)
+				p.fileScope.LabelMap[name] = label
+				continue loop
+			}
+		}
+		if scope.Parent == nil || isFuncScope(scope) {
+			p.fileScope.UnresolvedGotos = append(p.fileScope.UnresolvedGotos, name)
+			continue
+		}
+		p.gotos[scope.Parent] = append(p.gotos[scope.Parent], name)
+	}
+}
+
+// addLabel creates a new Label, named by the given NAME token, and adds it to
+// the current scope.
+func (p *scopeParser) addLabel(name *tree.Token) {
+	label := &Label{
+		Name:        string(name.Bytes),
+		Declaration: name,
+		Scope:       p.currentScope,
+	}
+	p.currentScope.Labels = append(p.currentScope.Labels, label)
+	p.fileScope.LabelMap[name] = label
+}
+
+// addGoto adds a goto statement, jumping to the label named by the given NAME
+// token, to be resolved when the current scope closes.
+func (p *scopeParser) addGoto(name *tree.Token) {
+	p.gotos[p.currentScope] = append(p.gotos[p.currentScope], name)
+}
+
 func (p *scopeParser) addVariableName(v *Variable, name *tree.Token) {
 	v.References = append(v.References, name)
 	v.Scopes = append(v.Scopes, p.currentScope)
@@ -169,20 +272,21 @@ func (p *scopeParser) newVariable(name *tree.Token) *Variable {
 
 // AddLocalVar creates a new Variable, named by the given NAME token, and adds
 // it to the current scope.
-func (p *scopeParser) addLocalVar(name *tree.Token) {
+func (p *scopeParser) addLocalVar(name *tree.Token) *Variable {
 	v := p.newVariable(name)
 	v.Type = LocalVar
 	p.currentScope.Variables = append(p.currentScope.Variables, v)
+	return v
 }
 
-// getLocalVar retrieves a variable, named by the given NAME token, from the
-// current scope, or each outer scope until it is found. Returns nil if no
-// variable of the given name could be found.
-func (p *scopeParser) getLocalVar(name *tree.Token) *Variable {
+// getLocalVar retrieves a variable of the given name from the current scope,
+// or each outer scope until it is found. Returns nil if no variable of the
+// given name could be found.
+func (p *scopeParser) getLocalVar(name string) *Variable {
 	for scope := p.currentScope; scope != nil; scope = scope.Parent {
 		// Iterate in reverse order to handle shadowing correctly.
 		for i := len(scope.Variables) - 1; i >= 0; i-- {
-			if scope.Variables[i].Name == string(name.Bytes) {
+			if scope.Variables[i].Name == name {
 				return scope.Variables[i]
 			}
 		}
@@ -190,10 +294,26 @@ func (p *scopeParser) getLocalVar(name *tree.Token) *Variable {
 	return nil
 }
 
+// getEnv returns the variable through which free names are resolved: either
+// the closest local variable named _ENV, or the implicit _ENV upvalue of the
+// file. Returns nil if the dialect does not have the token.Env feature.
+func (p *scopeParser) getEnv() *Variable {
+	if p.fileScope.Env == nil {
+		return nil
+	}
+	if v := p.getLocalVar("_ENV"); v != nil {
+		return v
+	}
+	return p.fileScope.Env
+}
+
 // referenceVariable adds a reference to the variable named by the given NAME
 // token. The variable may be local or global.
 func (p *scopeParser) referenceVariable(name *tree.Token) *Variable {
-	v := p.getLocalVar(name)
+	v := p.getLocalVar(string(name.Bytes))
+	if v == nil && p.fileScope.Env != nil && string(name.Bytes) == "_ENV" {
+		v = p.fileScope.Env
+	}
 	if v != nil {
 		p.addVariableName(v, name)
 	} else {
@@ -203,10 +323,12 @@ func (p *scopeParser) referenceVariable(name *tree.Token) *Variable {
 }
 
 // addGlobalVar adds a reference to a global variable, named by the given NAME
-// token. A new Variable is created, if necessary.
+// token. A new Variable is created, if necessary. Globals of the same name are
+// distinct when they are resolved through different environments.
 func (p *scopeParser) addGlobalVar(name *tree.Token) (v *Variable) {
+	env := p.getEnv()
 	for _, g := range p.fileScope.Globals {
-		if g.Name == string(name.Bytes) {
+		if g.Name == string(name.Bytes) && g.Env == env {
 			v = g
 			break
 		}
@@ -216,6 +338,7 @@ func (p *scopeParser) addGlobalVar(name *tree.Token) (v *Variable) {
 	} else {
 		v = p.newVariable(name)
 		v.Type = GlobalVar
+		v.Env = env
 		p.fileScope.Globals = append(p.fileScope.Globals, v)
 	}
 	return v
@@ -230,13 +353,29 @@ func (p *scopeParser) Visit(node tree.Node) tree.Visitor {
 		}
 		p.openScope(node)
 		p.fileScope.Root = p.currentScope
+		if p.dialect.Has(token.Env) {
+			p.fileScope.Env = &Variable{
+				Type: EnvVar,
+				Name: "_ENV",
+			}
+		}
 		tree.Walk(p, &node.Body)
 		p.closeScope()
 		return nil
 
 	case *tree.NameList:
+		// Type annotations may refer to variables through typeof, which must
+		// be resolved before the names are added.
+		for _, typ := range node.Types {
+			if typ != nil {
+				tree.Walk(p, typ)
+			}
+		}
 		for i := range node.Items {
-			p.addLocalVar(&node.Items[i])
+			v := p.addLocalVar(&node.Items[i])
+			if i < len(node.Attribs) && node.Attribs[i] != nil {
+				v.Attrib = string(node.Attribs[i].NameToken.Bytes)
+			}
 		}
 		return nil
 
@@ -249,6 +388,12 @@ func (p *scopeParser) Visit(node tree.Node) tree.Visitor {
 		if node.Params != nil {
 			tree.Walk(p, node.Params)
 		}
+		if node.VarArgType != nil {
+			tree.Walk(p, node.VarArgType)
+		}
+		if node.ReturnType != nil {
+			tree.Walk(p, node.ReturnType)
+		}
 		tree.Walk(p, &node.Body)
 		p.closeScope()
 		return nil
@@ -297,6 +442,9 @@ func (p *scopeParser) Visit(node tree.Node) tree.Visitor {
 		}
 		p.closeScope()
 		p.openScope(node)
+		if node.NameType != nil {
+			tree.Walk(p, node.NameType)
+		}
 		p.addLocalVar(&node.NameToken)
 		tree.Walk(p, &node.Body)
 		p.closeScope()
@@ -356,16 +504,25 @@ func (p *scopeParser) Visit(node tree.Node) tree.Visitor {
 		p.referenceVariable(&node.Items[0])
 		return nil
 
+	case *tree.LabelStmt:
+		p.addLabel(&node.NameToken)
+		return nil
+
+	case *tree.GotoStmt:
+		p.addGoto(&node.NameToken)
+		return nil
+
 	default:
 	}
 	return p
 }
 
 // BuildFileScope walks the given parse tree, building a tree of scopes and the
-// variables they contain.
+// variables they contain. Scoping rules are determined by the dialect of the
+// file.
 func BuildFileScope(file *tree.File) *FileScope {
 	var p scopeParser
-	p.init()
+	p.init(file.Dialect)
 	tree.Walk(&p, file)
 	if p.currentScope != nil {
 		panic("unbalanced scopes")
@@ -375,5 +532,10 @@ func BuildFileScope(file *tree.File) *FileScope {
 		g.LifeEnd = p.fileScope.Root.End
 		g.ScopeEnd = p.fileScope.Root.End
 	}
+	if env := p.fileScope.Env; env != nil {
+		env.LifeStart = p.fileScope.Root.Start
+		env.LifeEnd = p.fileScope.Root.End
+		env.ScopeEnd = p.fileScope.Root.End
+	}
 	return p.fileScope
 }
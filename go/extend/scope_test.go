@@ -0,0 +1,160 @@
+package extend
+
+import (
+	"github.com/anaminus/luasyntax/go/parser"
+	"github.com/anaminus/luasyntax/go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func buildScope(t *testing.T, dialect token.Dialect, src string) *FileScope {
+	t.Helper()
+	f, err := (&parser.Config{Dialect: dialect}).ParseFile("", src)
+	if err != nil {
+		t.Fatalf("%q: %s", src, err)
+	}
+	return BuildFileScope(f)
+}
+
+func TestGotoResolution(t *testing.T) {
+	tests := []struct {
+		src        string
+		labels     string // Each label with its number of gotos.
+		unresolved string
+	}{
+		{"::a:: goto a", "a:1", ""},
+		{"goto a ::a::", "a:1", ""},
+		{"goto a", "", "a"},
+		{"do ::a:: end goto a", "a:0", "a"},
+		{"::a:: do goto a end", "a:1", ""},
+		{"::a:: function f() goto a end", "a:0", "a"},
+		{"for i = 1, 3 do\n  if i == 2 then goto continue end\n  ::continue::\nend\ngoto continue",
+			"continue:1", "continue"},
+		{"while x do goto a ::a:: end while y do goto a ::a:: end", "a:1 a:1", ""},
+	}
+	for _, test := range tests {
+		fs := buildScope(t, token.Lua52, test.src)
+		var labels []string
+		seen := map[*Label]bool{}
+		for _, l := range fs.LabelMap {
+			if !seen[l] {
+				seen[l] = true
+				labels = append(labels, l.Name+":"+strconv.Itoa(len(l.Gotos)))
+			}
+		}
+		sort.Strings(labels)
+		if got := strings.Join(labels, " "); got != test.labels {
+			t.Errorf("%q: expected labels %q, got %q", test.src, test.labels, got)
+		}
+		var unresolved []string
+		for _, tok := range fs.UnresolvedGotos {
+			unresolved = append(unresolved, string(tok.Bytes))
+		}
+		if got := strings.Join(unresolved, " "); got != test.unresolved {
+			t.Errorf("%q: expected unresolved %q, got %q", test.src, test.unresolved, got)
+		}
+	}
+}
+
+func TestEnv(t *testing.T) {
+	tests := []struct {
+		dialect token.Dialect
+		src     string
+		globals string // Each global with the type of its environment.
+		envRefs int
+	}{
+		{token.Lua51, "x = 1 print(_ENV)", "_ENV:<invalid> print:<invalid> x:<invalid>", -1},
+		{token.Lua52, "x = 1", "x:Env", 0},
+		{token.Lua52, "print(_ENV)", "print:Env", 1},
+		{token.Lua52, "local _ENV = {} x = 1", "x:Local", 0},
+		{token.Lua52, "do local _ENV = {} x = 1 end x = 2", "x:Env x:Local", 0},
+		{token.Lua52, "function f(_ENV) return y end", "f:Env y:Local", 0},
+	}
+	for _, test := range tests {
+		fs := buildScope(t, test.dialect, test.src)
+		var globals []string
+		for _, g := range fs.Globals {
+			env := InvalidVar
+			if g.Env != nil {
+				env = g.Env.Type
+			}
+			globals = append(globals, g.Name+":"+env.String())
+		}
+		sort.Strings(globals)
+		if got := strings.Join(globals, " "); got != test.globals {
+			t.Errorf("%s %q: expected globals %q, got %q", test.dialect, test.src, test.globals, got)
+		}
+		envRefs := -1
+		if fs.Env != nil {
+			envRefs = len(fs.Env.References)
+		}
+		if envRefs != test.envRefs {
+			t.Errorf("%s %q: expected %d references to _ENV, got %d", test.dialect, test.src, test.envRefs, envRefs)
+		}
+	}
+}
+
+func TestAttribVariables(t *testing.T) {
+	tests := []struct {
+		src    string
+		vars   string // Each local variable with its attribute.
+		consts string // Each local variable that is constant.
+	}{
+		{"local a, b = 1", "a: b:", ""},
+		{"local a <const>, b <close> = 1", "a:const b:close", "a b"},
+		{"local a <const> = 1 do local a = 2 end", "a:const a:", "a"},
+	}
+	for _, test := range tests {
+		fs := buildScope(t, token.Lua54, test.src)
+		var vars, consts []string
+		var visit func(s *Scope)
+		visit = func(s *Scope) {
+			for _, v := range s.Variables {
+				vars = append(vars, v.Name+":"+v.Attrib)
+				if v.IsConst() {
+					consts = append(consts, v.Name)
+				}
+			}
+			for _, c := range s.Children {
+				visit(c)
+			}
+		}
+		visit(fs.Root)
+		if got := strings.Join(vars, " "); got != test.vars {
+			t.Errorf("%q: expected variables %q, got %q", test.src, test.vars, got)
+		}
+		if got := strings.Join(consts, " "); got != test.consts {
+			t.Errorf("%q: expected constants %q, got %q", test.src, test.consts, got)
+		}
+	}
+}
+
+func TestLuauTypes(t *testing.T) {
+	tests := []struct {
+		src     string
+		globals string
+	}{
+		{"local x: number = 1", ""},
+		{"local x: typeof(x) = 1", "x"},
+		{"local y = 1 local x: typeof(y) = y", ""},
+		{"type T = typeof(g)", "g"},
+		{"local function f(a: typeof(a), ...: typeof(b)): typeof(c) end", "a b c"},
+		{"for i: typeof(i) = 1, 2 do end", "i"},
+		{"x += 1", "x"},
+		{"local x = if a then b else c", "a b c"},
+		{"local s = `{a} {b}`", "a b"},
+	}
+	for _, test := range tests {
+		fs := buildScope(t, token.Luau, test.src)
+		var globals []string
+		for _, g := range fs.Globals {
+			globals = append(globals, g.Name)
+		}
+		sort.Strings(globals)
+		if got := strings.Join(globals, " "); got != test.globals {
+			t.Errorf("%q: expected globals %q, got %q", test.src, test.globals, got)
+		}
+	}
+}
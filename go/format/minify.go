@@ -6,7 +6,18 @@ import (
 	"github.com/anaminus/luasyntax/go/tree"
 )
 
-type minify struct{}
+// A Mode value is a set of flags that control the behavior of formatting.
+type Mode uint
+
+const (
+	// KeepHeader causes a byte order mark or shebang line at the start of a
+	// file to be retained.
+	KeepHeader Mode = 1 << iota
+)
+
+type minify struct {
+	mode Mode
+}
 
 func (m *minify) Visit(tree.Node) tree.Visitor {
 	return m
@@ -16,7 +27,15 @@ func (m *minify) VisitToken(_ tree.Node, _ int, tok *tree.Token) {
 	if !tok.Type.IsValid() {
 		return
 	}
-	tok.Prefix = tok.Prefix[:0]
+	prefix := tok.Prefix[:0]
+	if m.mode&KeepHeader != 0 {
+		for _, p := range tok.Prefix {
+			if p.Type == token.BOM || p.Type == token.SHEBANG {
+				prefix = append(prefix, p)
+			}
+		}
+	}
+	tok.Prefix = prefix
 }
 
 const chars = `abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ_0123456789`
@@ -162,7 +181,14 @@ func descendItems(items []interface{}, cb func(items []interface{}, i int, item
 	}
 }
 
+// Minify reduces the size of a file by removing spaces and comments, and by
+// renaming local variables to shorter names.
 func Minify(file *tree.File) {
+	MinifyMode(file, 0)
+}
+
+// MinifyMode is like Minify, but the mode determines what is retained.
+func MinifyMode(file *tree.File, mode Mode) {
 	fileScope := extend.BuildFileScope(file)
 
 	type indexKey struct {
@@ -210,6 +236,14 @@ func Minify(file *tree.File) {
 			}
 		})
 	}
+	// Local variables used as the environment of globals cannot be renamed.
+	envs := map[*extend.Variable]bool{}
+	for _, variable := range fileScope.Globals {
+		if variable.Env != nil && variable.Env.Type == extend.LocalVar {
+			envs[variable.Env] = true
+		}
+	}
+
 	// Traverse local variables.
 	descendItems(fileScope.Root.Items, func(items []interface{}, i int, item interface{}) {
 		token, ok := item.(*tree.Token)
@@ -227,14 +261,20 @@ func Minify(file *tree.File) {
 			return
 		}
 
-		// Check each index until an available one is found.
 		index := 0
-		// TODO: sort locals by descending reference frequency.
-		for ; ; index++ {
-			v, used := usedIndexes[indexKey{variable.Scopes[0], index}]
-			if !used || v != nil && !variable.VisiblityOverlapsWith(v) {
-				varIndexes[variable] = index
-				break
+		if envs[variable] {
+			// Retain the current name.
+			index = IdentIndex(variable.Name)
+			varIndexes[variable] = index
+		} else {
+			// Check each index until an available one is found.
+			// TODO: sort locals by descending reference frequency.
+			for ; ; index++ {
+				v, used := usedIndexes[indexKey{variable.Scopes[0], index}]
+				if !used || v != nil && !variable.VisiblityOverlapsWith(v) {
+					varIndexes[variable] = index
+					break
+				}
 			}
 		}
 
@@ -262,8 +302,8 @@ func Minify(file *tree.File) {
 		}
 	}
 
-	var m minify
+	m := minify{mode: mode}
 	tree.Walk(&m, file)
-	tree.FixAdjoinedTokens(file)
+	tree.FixAdjoinedTokensDialect(file, file.Dialect)
 	tree.FixTokenOffsets(file, 0)
 }
@@ -0,0 +1,81 @@
+package format
+
+import (
+	"bytes"
+	"github.com/anaminus/luasyntax/go/parser"
+	"github.com/anaminus/luasyntax/go/tree"
+	"testing"
+)
+
+func source(node tree.Node) string {
+	var buf bytes.Buffer
+	node.WriteTo(&buf)
+	return buf.String()
+}
+
+func TestMinifyHeader(t *testing.T) {
+	tests := []struct {
+		src    string
+		minify string
+		keep   string
+	}{
+		{
+			"\xEF\xBB\xBF#!/usr/bin/env lua\nlocal x = 1 print(x)",
+			"local a=1 print(a)",
+			"\xEF\xBB\xBF#!/usr/bin/env lua\nlocal a=1 print(a)",
+		},
+		{"#!/usr/bin/env lua\r\nprint(1)", "print(1)", "#!/usr/bin/env lua\nprint(1)"},
+		{"\xEF\xBB\xBFprint(1)", "print(1)", "\xEF\xBB\xBFprint(1)"},
+		{"# comment line", "", "# comment line"},
+		{"#!lua\n-- comment\nprint(#x)", "print(#x)", "#!lua\nprint(#x)"},
+		{"print(#x)", "print(#x)", "print(#x)"},
+		{"", "", ""},
+	}
+	for _, test := range tests {
+		for _, mode := range []Mode{0, KeepHeader} {
+			want := test.minify
+			if mode == KeepHeader {
+				want = test.keep
+			}
+			f, err := parser.ParseFile("", test.src)
+			if err != nil {
+				t.Errorf("%q: unexpected error %s", test.src, err)
+				continue
+			}
+			if got := source(f); got != test.src {
+				t.Errorf("%q: expected round trip, got %q", test.src, got)
+			}
+			MinifyMode(f, mode)
+			got := source(f)
+			if got != want {
+				t.Errorf("%q with mode %d: expected %q, got %q", test.src, mode, want, got)
+			}
+			if _, err := parser.ParseFile("", got); err != nil {
+				t.Errorf("%q: unexpected error %s", got, err)
+			}
+		}
+	}
+}
+
+func TestMinify(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"local x = 1 print(x)", "local a=1 print(a)"},
+		{"-- comment\nx = 1", "x=1"},
+		{"\xEF\xBB\xBFx = 1", "x=1"},
+		{"local a, b = 1, 2\nreturn a - -b", "local a,b=1,2 return a- -b"},
+	}
+	for _, test := range tests {
+		f, err := parser.ParseFile("", test.src)
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", test.src, err)
+			continue
+		}
+		Minify(f)
+		if got := source(f); got != test.want {
+			t.Errorf("%q: expected %q, got %q", test.src, test.want, got)
+		}
+	}
+}
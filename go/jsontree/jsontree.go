@@ -0,0 +1,457 @@
+// The jsontree package encodes parse trees as JSON, and decodes them back into
+// trees.
+//
+// The encoding is versioned by the Version constant. An encoded tree is an
+// object of the following form:
+//
+//	{"version": 1, "root": NODE}
+//
+// A NODE is null, or an object containing a "type" member with the name of the
+// node type, such as "LocalVarStmt", followed by a member for each field of
+// the node, named after the field and in the same order as the definition of
+// the node within the tree package. A field holding a node or list of nodes
+// is encoded as a NODE or array of NODEs. A field holding a Token is encoded
+// as a TOKEN, and a list of tokens as an array of TOKENs. The Info field of a
+// File is encoded as the name of the file, and the Dialect field as the string
+// representation of the dialect.
+//
+// A TOKEN is null when the token is INVALID and has no bytes or prefixes.
+// Otherwise, it is an object of the following form:
+//
+//	{"type": TYPE, "offset": 0, "text": "", "prefix": [PREFIX]}
+//
+// TYPE is the name of the token type, such as "NAME" or "LOCAL". The bytes of
+// the token are encoded in the "text" member when they are valid UTF-8, and in
+// the "data" member as base64 otherwise. The "prefix" member is omitted when
+// the token has no prefixes. A PREFIX has the same form as a TOKEN, without
+// the "offset" and "prefix" members.
+//
+// The Schema function returns a JSON Schema document describing the encoding,
+// which is generated from the definitions of the tree package.
+package jsontree
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/anaminus/luasyntax/go/token"
+	"github.com/anaminus/luasyntax/go/tree"
+	"reflect"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Version is the version of the encoding produced by Marshal.
+const Version = 1
+
+var (
+	nodeType  = reflect.TypeOf((*tree.Node)(nil)).Elem()
+	tokenType = reflect.TypeOf(tree.Token{})
+	fileType  = reflect.TypeOf((*token.File)(nil))
+	dlctType  = reflect.TypeOf(token.Dialect(0))
+)
+
+var (
+	// nodeNames maps a pointer to a node type to its name.
+	nodeNames = map[reflect.Type]string{}
+	// nodeTypes maps the name of a node type to its pointer type.
+	nodeTypes = map[string]reflect.Type{}
+	// tokenTypes maps the name of a token type to the token type.
+	tokenTypes = map[string]token.Type{}
+)
+
+func init() {
+	for _, node := range nodes {
+		t := reflect.TypeOf(node)
+		nodeNames[t] = t.Elem().Name()
+		nodeTypes[t.Elem().Name()] = t
+	}
+	for t, name := range tokenNames {
+		tokenTypes[name] = t
+	}
+}
+
+// Marshal returns the JSON encoding of the tree of node.
+func Marshal(node tree.Node) ([]byte, error) {
+	var e encoder
+	e.WriteString(`{"version":` + strconv.Itoa(Version) + `,"root":`)
+	if err := e.node(reflect.ValueOf(&node).Elem()); err != nil {
+		return nil, err
+	}
+	e.WriteString("}")
+	return e.Bytes(), nil
+}
+
+// MarshalIndent is like Marshal, but applies json.Indent to the result.
+func MarshalIndent(node tree.Node, prefix, indent string) ([]byte, error) {
+	b, err := Marshal(node)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, b, prefix, indent); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encoder writes the JSON encoding of a tree.
+type encoder struct {
+	bytes.Buffer
+}
+
+// string writes s as a JSON string.
+func (e *encoder) string(s string) {
+	enc := json.NewEncoder(&e.Buffer)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	// Remove newline written by Encode.
+	e.Truncate(e.Len() - 1)
+}
+
+// text writes the bytes of a token or prefix.
+func (e *encoder) text(b []byte) {
+	if utf8.Valid(b) {
+		e.WriteString(`,"text":`)
+		e.string(string(b))
+		return
+	}
+	e.WriteString(`,"data":`)
+	e.string(base64.StdEncoding.EncodeToString(b))
+}
+
+func (e *encoder) token(t *tree.Token) error {
+	if t.Type == token.INVALID && len(t.Bytes) == 0 && len(t.Prefix) == 0 {
+		e.WriteString("null")
+		return nil
+	}
+	name, ok := tokenNames[t.Type]
+	if !ok {
+		return errors.New("unknown token type " + t.Type.String())
+	}
+	e.WriteString(`{"type":`)
+	e.string(name)
+	e.WriteString(`,"offset":` + strconv.Itoa(t.Offset))
+	e.text(t.Bytes)
+	if len(t.Prefix) > 0 {
+		e.WriteString(`,"prefix":[`)
+		for i, p := range t.Prefix {
+			if i > 0 {
+				e.WriteString(",")
+			}
+			name, ok := tokenNames[p.Type]
+			if !ok {
+				return errors.New("unknown prefix type " + p.Type.String())
+			}
+			e.WriteString(`{"type":`)
+			e.string(name)
+			e.text(p.Bytes)
+			e.WriteString("}")
+		}
+		e.WriteString("]")
+	}
+	e.WriteString("}")
+	return nil
+}
+
+// node writes v, which is an interface, pointer, or struct holding a node.
+func (e *encoder) node(v reflect.Value) error {
+	if v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			e.WriteString("null")
+			return nil
+		}
+		if v.Kind() == reflect.Interface {
+			v = v.Elem()
+		}
+		v = v.Elem()
+	}
+	name, ok := nodeNames[reflect.PtrTo(v.Type())]
+	if !ok {
+		return errors.New("unknown node type " + v.Type().String())
+	}
+	e.WriteString(`{"type":`)
+	e.string(name)
+	for i := 0; i < v.NumField(); i++ {
+		e.WriteString(",")
+		e.string(v.Type().Field(i).Name)
+		e.WriteString(":")
+		if err := e.field(v.Field(i)); err != nil {
+			return err
+		}
+	}
+	e.WriteString("}")
+	return nil
+}
+
+// field writes the value of a field of a node.
+func (e *encoder) field(v reflect.Value) error {
+	switch {
+	case v.Type() == tokenType:
+		return e.token(v.Addr().Interface().(*tree.Token))
+	case v.Type() == fileType:
+		if v.IsNil() {
+			e.WriteString("null")
+		} else {
+			e.string(v.Interface().(*token.File).Name())
+		}
+		return nil
+	case v.Type() == dlctType:
+		e.string(v.Interface().(token.Dialect).String())
+		return nil
+	case v.Kind() == reflect.Slice:
+		e.WriteString("[")
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				e.WriteString(",")
+			}
+			if err := e.field(v.Index(i)); err != nil {
+				return err
+			}
+		}
+		e.WriteString("]")
+		return nil
+	default:
+		return e.node(v)
+	}
+}
+
+// Unmarshal decodes a tree from its JSON encoding. An error is returned if
+// the version of the encoding is not supported, if the encoding is malformed,
+// or if the decoded tree contains nodes that are not valid.
+//
+// If the root is a File, then its Info is rebuilt with the content produced
+// by writing the tree.
+func Unmarshal(data []byte) (tree.Node, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc struct {
+		Version json.Number
+		Root    interface{}
+	}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if doc.Version.String() != strconv.Itoa(Version) {
+		return nil, errors.New("unsupported version " + doc.Version.String())
+	}
+	var root tree.Node
+	v := reflect.ValueOf(&root).Elem()
+	if err := decodeNode(v, doc.Root, "root"); err != nil {
+		return nil, err
+	}
+	if root == nil {
+		return nil, nil
+	}
+	var verr error
+	tree.Walk(validator{err: &verr}, root)
+	if verr != nil {
+		return nil, verr
+	}
+	if file, ok := root.(*tree.File); ok {
+		var buf bytes.Buffer
+		if _, err := file.WriteTo(&buf); err != nil {
+			return nil, err
+		}
+		if file.Info == nil {
+			file.Info = token.NewFile("")
+		}
+		file.Info.SetLinesForContent(buf.Bytes())
+		file.Info.SetContent(buf.Bytes())
+	}
+	return root, nil
+}
+
+// validator reports the first node that is not valid.
+type validator struct {
+	err *error
+}
+
+func (v validator) Visit(node tree.Node) tree.Visitor {
+	if node == nil || *v.err != nil {
+		return nil
+	}
+	if !node.IsValid() {
+		*v.err = fmt.Errorf("invalid node %s at offset %d", nodeNames[reflect.TypeOf(node)], node.Offset())
+		return nil
+	}
+	return v
+}
+
+// decodeNode decodes x into v, which is an interface, pointer, or struct that
+// holds a node.
+func decodeNode(v reflect.Value, x interface{}, path string) error {
+	if x == nil {
+		if v.Kind() == reflect.Struct {
+			return errors.New(path + ": missing required node")
+		}
+		return nil
+	}
+	m, ok := x.(map[string]interface{})
+	if !ok {
+		return errors.New(path + ": expected object")
+	}
+	name, _ := m["type"].(string)
+	t, ok := nodeTypes[name]
+	if !ok {
+		return fmt.Errorf("%s: unknown node type %q", path, name)
+	}
+	var node reflect.Value
+	switch v.Kind() {
+	case reflect.Struct:
+		if t.Elem() != v.Type() {
+			return fmt.Errorf("%s: expected node type %s, got %s", path, v.Type().Name(), name)
+		}
+		node = v
+	default:
+		if !t.AssignableTo(v.Type()) {
+			return fmt.Errorf("%s: node type %s not allowed here", path, name)
+		}
+		p := reflect.New(t.Elem())
+		v.Set(p)
+		node = p.Elem()
+	}
+	for key := range m {
+		if key == "type" {
+			continue
+		}
+		if _, ok := node.Type().FieldByName(key); !ok {
+			return fmt.Errorf("%s: unknown field %q of %s", path, key, name)
+		}
+	}
+	for i := 0; i < node.NumField(); i++ {
+		f := node.Type().Field(i)
+		if err := decodeField(node.Field(i), m[f.Name], path+"."+f.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeField decodes x into v, which is a field of a node.
+func decodeField(v reflect.Value, x interface{}, path string) error {
+	switch {
+	case v.Type() == tokenType:
+		return decodeToken(v.Addr().Interface().(*tree.Token), x, path)
+	case v.Type() == fileType:
+		if x == nil {
+			return nil
+		}
+		name, ok := x.(string)
+		if !ok {
+			return errors.New(path + ": expected string")
+		}
+		v.Set(reflect.ValueOf(token.NewFile(name)))
+		return nil
+	case v.Type() == dlctType:
+		name, ok := x.(string)
+		if !ok {
+			return errors.New(path + ": expected string")
+		}
+		for d := token.Dialect(0); d.IsValid(); d++ {
+			if d.String() == name {
+				v.Set(reflect.ValueOf(d))
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: unknown dialect %q", path, name)
+	case v.Kind() == reflect.Slice:
+		if x == nil {
+			return nil
+		}
+		a, ok := x.([]interface{})
+		if !ok {
+			return errors.New(path + ": expected array")
+		}
+		s := reflect.MakeSlice(v.Type(), len(a), len(a))
+		for i, x := range a {
+			if err := decodeField(s.Index(i), x, path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	default:
+		return decodeNode(v, x, path)
+	}
+}
+
+// decodeBytes decodes the "text" or "data" member of m.
+func decodeBytes(m map[string]interface{}, path string) ([]byte, error) {
+	if x, ok := m["data"]; ok {
+		s, ok := x.(string)
+		if !ok {
+			return nil, errors.New(path + ".data: expected string")
+		}
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, errors.New(path + ".data: " + err.Error())
+		}
+		return b, nil
+	}
+	s, ok := m["text"].(string)
+	if !ok {
+		return nil, errors.New(path + ".text: expected string")
+	}
+	return []byte(s), nil
+}
+
+// decodeType decodes the "type" member of m.
+func decodeType(m map[string]interface{}, path string) (token.Type, error) {
+	name, _ := m["type"].(string)
+	t, ok := tokenTypes[name]
+	if !ok {
+		return 0, fmt.Errorf("%s: unknown token type %q", path, name)
+	}
+	return t, nil
+}
+
+func decodeToken(t *tree.Token, x interface{}, path string) (err error) {
+	if x == nil {
+		return nil
+	}
+	m, ok := x.(map[string]interface{})
+	if !ok {
+		return errors.New(path + ": expected object")
+	}
+	if t.Type, err = decodeType(m, path); err != nil {
+		return err
+	}
+	if t.Bytes, err = decodeBytes(m, path); err != nil {
+		return err
+	}
+	if n, ok := m["offset"].(json.Number); ok {
+		off, err := strconv.Atoi(n.String())
+		if err != nil {
+			return errors.New(path + ".offset: " + err.Error())
+		}
+		t.Offset = off
+	}
+	if x, ok := m["prefix"]; ok {
+		a, ok := x.([]interface{})
+		if !ok {
+			return errors.New(path + ".prefix: expected array")
+		}
+		t.Prefix = make([]tree.Prefix, len(a))
+		for i, x := range a {
+			ppath := path + ".prefix[" + strconv.Itoa(i) + "]"
+			m, ok := x.(map[string]interface{})
+			if !ok {
+				return errors.New(ppath + ": expected object")
+			}
+			p := &t.Prefix[i]
+			if p.Type, err = decodeType(m, ppath); err != nil {
+				return err
+			}
+			if !p.Type.IsPrefix() {
+				return fmt.Errorf("%s: token type %q is not a prefix", ppath, tokenNames[p.Type])
+			}
+			if p.Bytes, err = decodeBytes(m, ppath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
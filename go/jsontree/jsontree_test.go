@@ -0,0 +1,156 @@
+package jsontree
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/anaminus/luasyntax/go/parser"
+	"github.com/anaminus/luasyntax/go/token"
+	"github.com/anaminus/luasyntax/go/tree"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func source(node tree.Node) string {
+	var buf bytes.Buffer
+	node.WriteTo(&buf)
+	return buf.String()
+}
+
+var roundTripTests = []struct {
+	dialect token.Dialect
+	src     string
+}{
+	{token.Lua51, ""},
+	{token.Lua51, "\xEF\xBB\xBF#!/usr/bin/env lua\n-- comment\nlocal x, y = 1, 'a'\nprint(x .. y)\n"},
+	{token.Lua51, "local t = {1, [2] = 2; a = function(...) return ... end,}\nt:m{}\nt.f('x')"},
+	{token.Lua51, "if a then b() elseif c then d() else e() end\nwhile x do break end\nrepeat until y"},
+	{token.Lua51, "for i = 1, 10, 2 do end for k, v in pairs(t) do end"},
+	{token.Lua51, "x = 'invalid \xff utf-8' --[==[ long\ncomment ]==]"},
+	{token.Lua52, "::top:: goto top"},
+	{token.Lua53, "x = a // b | c ~ d & e << f >> g ~ h"},
+	{token.Lua54, "local x <const>, y <close> = 1, nil"},
+	{token.LuaJIT, "x = 1LL + 2ULL + 3i"},
+	{token.Luau, "type T<U> = {x: U, y: (number) -> string?}\nlocal s = `a {b} c`\nx += if c then 1 else 2\nfor i = 1, 2 do continue end"},
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, test := range roundTripTests {
+		f, err := (&parser.Config{Dialect: test.dialect}).ParseFile("test.lua", test.src)
+		if err != nil {
+			t.Errorf("%s %q: unexpected error %s", test.dialect, test.src, err)
+			continue
+		}
+		b, err := Marshal(f)
+		if err != nil {
+			t.Errorf("%s %q: unexpected error %s", test.dialect, test.src, err)
+			continue
+		}
+		if !json.Valid(b) {
+			t.Errorf("%s %q: invalid JSON %s", test.dialect, test.src, b)
+			continue
+		}
+		node, err := Unmarshal(b)
+		if err != nil {
+			t.Errorf("%s %q: unexpected error %s", test.dialect, test.src, err)
+			continue
+		}
+		g, ok := node.(*tree.File)
+		if !ok {
+			t.Errorf("%s %q: expected *tree.File, got %T", test.dialect, test.src, node)
+			continue
+		}
+		if !tree.Equal(f, g, 0) {
+			t.Errorf("%s %q: expected equal tree", test.dialect, test.src)
+		}
+		if got := source(g); got != test.src {
+			t.Errorf("%s %q: expected same source, got %q", test.dialect, test.src, got)
+		}
+		if g.Dialect != test.dialect {
+			t.Errorf("%s %q: expected dialect %s, got %s", test.dialect, test.src, test.dialect, g.Dialect)
+		}
+		if name := g.Info.Name(); name != "test.lua" {
+			t.Errorf("%s %q: expected file name %q, got %q", test.dialect, test.src, "test.lua", name)
+		}
+		for i := range test.src {
+			if a, b := f.Info.Position(i), g.Info.Position(i); a != b {
+				t.Errorf("%s %q: expected position %s, got %s", test.dialect, test.src, a, b)
+				break
+			}
+		}
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"-a", `{"version":1,"root":{"type":"UnopExpr",` +
+			`"UnopToken":{"type":"MINUS","offset":0,"text":"-"},` +
+			`"Operand":{"type":"VariableExpr","NameToken":{"type":"NAME","offset":1,"text":"a"}}}}`},
+		{"--c\n'\xff'", `{"version":1,"root":{"type":"StringExpr",` +
+			`"StringToken":{"type":"STRING","offset":4,"data":"J/8n",` +
+			`"prefix":[{"type":"COMMENT","text":"--c"},{"type":"SPACE","text":"\n"}]}}}`},
+	}
+	for _, test := range tests {
+		expr, err := parser.ParseExpr("", test.src)
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", test.src, err)
+			continue
+		}
+		b, err := Marshal(expr)
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", test.src, err)
+			continue
+		}
+		if got := string(b); got != test.want {
+			t.Errorf("%q: expected\n%s\ngot\n%s", test.src, test.want, got)
+		}
+	}
+
+	b, err := Marshal(nil)
+	if err != nil || string(b) != `{"version":1,"root":null}` {
+		t.Errorf("nil: unexpected result %s (%v)", b, err)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		data string
+		err  string
+	}{
+		{`{"version":2,"root":null}`, "unsupported version 2"},
+		{`{"version":1,"root":[]}`, "expected object"},
+		{`{"version":1,"root":{"type":"Foo"}}`, "unknown node type"},
+		{`{"version":1,"root":{"type":"VariableExpr","Foo":null}}`, "unknown field"},
+		{`{"version":1,"root":{"type":"VariableExpr","NameToken":null}}`, "invalid node"},
+		{`{"version":1,"root":{"type":"VariableExpr","NameToken":{"type":"FOO","offset":0,"text":"a"}}}`, "FOO"},
+		{`{"version":1,"root":{"type":"UnopExpr","UnopToken":{"type":"MINUS","offset":0,"text":"-"},"Operand":{"type":"Block"}}}`, "not allowed"},
+		{`{"version":1`, "EOF"},
+	}
+	for _, test := range tests {
+		_, err := Unmarshal([]byte(test.data))
+		if err == nil {
+			t.Errorf("%s: expected error", test.data)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected error containing %q, got %q", test.data, test.err, err)
+		}
+	}
+}
+
+func TestSchema(t *testing.T) {
+	schema := Schema()
+	if !json.Valid(schema) {
+		t.Fatal("invalid schema")
+	}
+	b, err := ioutil.ReadFile("schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, schema) {
+		t.Error("schema.json is out of date; run go generate")
+	}
+}
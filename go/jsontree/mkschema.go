@@ -0,0 +1,17 @@
+//go:build ignore
+// +build ignore
+
+// Generates schema.json.
+package main
+
+import (
+	"github.com/anaminus/luasyntax/go/jsontree"
+	"io/ioutil"
+	"log"
+)
+
+func main() {
+	if err := ioutil.WriteFile("schema.json", jsontree.Schema(), 0666); err != nil {
+		log.Fatal(err)
+	}
+}
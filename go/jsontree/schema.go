@@ -0,0 +1,180 @@
+package jsontree
+
+//go:generate go run mkschema.go
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/anaminus/luasyntax/go/token"
+	"reflect"
+	"sort"
+)
+
+// object is a JSON object that retains the order of its members.
+type object []member
+
+type member struct {
+	key   string
+	value interface{}
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(m.key)
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func ref(name string) object {
+	return object{{"$ref", "#/definitions/" + name}}
+}
+
+func nullable(schema interface{}) object {
+	return object{{"anyOf", []interface{}{schema, object{{"type", "null"}}}}}
+}
+
+// schemaBuilder accumulates the definitions of a schema.
+type schemaBuilder struct {
+	defs   object
+	ifaces map[reflect.Type]bool
+}
+
+// Schema returns a JSON Schema document that describes the encoding produced
+// by Marshal. The schema is generated from the definitions of the tree
+// package.
+func Schema() []byte {
+	s := schemaBuilder{ifaces: map[reflect.Type]bool{}}
+
+	var tokens, prefixes []string
+	types := make([]token.Type, 0, len(tokenNames))
+	for t := range tokenNames {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	for _, t := range types {
+		tokens = append(tokens, tokenNames[t])
+		if t.IsPrefix() {
+			prefixes = append(prefixes, tokenNames[t])
+		}
+	}
+	var dialects []string
+	for d := token.Dialect(0); d.IsValid(); d++ {
+		dialects = append(dialects, d.String())
+	}
+	bytesSchema := []interface{}{
+		object{{"required", []string{"text"}}},
+		object{{"required", []string{"data"}}},
+	}
+	s.defs = append(s.defs,
+		member{"TokenType", object{{"enum", tokens}}},
+		member{"PrefixType", object{{"enum", prefixes}}},
+		member{"Dialect", object{{"enum", dialects}}},
+		member{"Token", object{
+			{"type", "object"},
+			{"required", []string{"type", "offset"}},
+			{"oneOf", bytesSchema},
+			{"additionalProperties", false},
+			{"properties", object{
+				{"type", ref("TokenType")},
+				{"offset", object{{"type", "integer"}, {"minimum", 0}}},
+				{"text", object{{"type", "string"}}},
+				{"data", object{{"type", "string"}, {"contentEncoding", "base64"}}},
+				{"prefix", object{{"type", "array"}, {"items", ref("Prefix")}}},
+			}},
+		}},
+		member{"Prefix", object{
+			{"type", "object"},
+			{"required", []string{"type"}},
+			{"oneOf", bytesSchema},
+			{"additionalProperties", false},
+			{"properties", object{
+				{"type", ref("PrefixType")},
+				{"text", object{{"type", "string"}}},
+				{"data", object{{"type", "string"}, {"contentEncoding", "base64"}}},
+			}},
+		}},
+	)
+	for _, node := range nodes {
+		s.node(reflect.TypeOf(node).Elem())
+	}
+	var ifaces []reflect.Type
+	for t := range s.ifaces {
+		ifaces = append(ifaces, t)
+	}
+	sort.Slice(ifaces, func(i, j int) bool { return ifaces[i].Name() < ifaces[j].Name() })
+	for _, t := range ifaces {
+		var refs []interface{}
+		for _, node := range nodes {
+			if nt := reflect.TypeOf(node); nt.Implements(t) {
+				refs = append(refs, ref(nt.Elem().Name()))
+			}
+		}
+		s.defs = append(s.defs, member{t.Name(), object{{"oneOf", refs}}})
+	}
+
+	doc := object{
+		{"$schema", "http://json-schema.org/draft-07/schema#"},
+		{"title", "luasyntax parse tree"},
+		{"type", "object"},
+		{"required", []string{"version", "root"}},
+		{"additionalProperties", false},
+		{"properties", object{
+			{"version", object{{"const", Version}}},
+			{"root", s.field(nodeType)},
+		}},
+		{"definitions", s.defs},
+	}
+	b, _ := json.MarshalIndent(doc, "", "\t")
+	return append(b, '\n')
+}
+
+// node adds the definition of a node type.
+func (s *schemaBuilder) node(t reflect.Type) {
+	props := object{{"type", object{{"const", t.Name()}}}}
+	required := []string{"type"}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		props = append(props, member{f.Name, s.field(f.Type)})
+		required = append(required, f.Name)
+	}
+	s.defs = append(s.defs, member{t.Name(), object{
+		{"type", "object"},
+		{"required", required},
+		{"additionalProperties", false},
+		{"properties", props},
+	}})
+}
+
+// field returns the schema of a field of the given type.
+func (s *schemaBuilder) field(t reflect.Type) interface{} {
+	switch {
+	case t == tokenType:
+		return nullable(ref("Token"))
+	case t == fileType:
+		return object{{"type", []string{"string", "null"}}}
+	case t == dlctType:
+		return ref("Dialect")
+	case t.Kind() == reflect.Slice:
+		return object{{"type", "array"}, {"items", s.field(t.Elem())}}
+	case t.Kind() == reflect.Interface:
+		s.ifaces[t] = true
+		return nullable(ref(t.Name()))
+	case t.Kind() == reflect.Ptr:
+		return nullable(ref(t.Elem().Name()))
+	default:
+		return ref(t.Name())
+	}
+}
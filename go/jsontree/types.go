@@ -0,0 +1,177 @@
+package jsontree
+
+import (
+	"github.com/anaminus/luasyntax/go/token"
+	"github.com/anaminus/luasyntax/go/tree"
+)
+
+// tokenNames maps each token type to the name used in the encoding.
+var tokenNames = map[token.Type]string{
+	token.INVALID:      "INVALID",
+	token.EOF:          "EOF",
+	token.SPACE:        "SPACE",
+	token.COMMENT:      "COMMENT",
+	token.LONGCOMMENT:  "LONGCOMMENT",
+	token.BOM:          "BOM",
+	token.SHEBANG:      "SHEBANG",
+	token.NAME:         "NAME",
+	token.NUMBERFLOAT:  "NUMBERFLOAT",
+	token.NUMBERHEX:    "NUMBERHEX",
+	token.NUMBERBIN:    "NUMBERBIN",
+	token.NUMBERI64:    "NUMBERI64",
+	token.NUMBERU64:    "NUMBERU64",
+	token.NUMBERIMAG:   "NUMBERIMAG",
+	token.STRING:       "STRING",
+	token.LONGSTRING:   "LONGSTRING",
+	token.INTERPSTRING: "INTERPSTRING",
+	token.INTERPBEGIN:  "INTERPBEGIN",
+	token.INTERPMID:    "INTERPMID",
+	token.INTERPEND:    "INTERPEND",
+	token.SEMICOLON:    "SEMICOLON",
+	token.ASSIGN:       "ASSIGN",
+	token.COMMA:        "COMMA",
+	token.DOT:          "DOT",
+	token.COLON:        "COLON",
+	token.DBCOLON:      "DBCOLON",
+	token.LBRACK:       "LBRACK",
+	token.RBRACK:       "RBRACK",
+	token.VARARG:       "VARARG",
+	token.LPAREN:       "LPAREN",
+	token.RPAREN:       "RPAREN",
+	token.LBRACE:       "LBRACE",
+	token.RBRACE:       "RBRACE",
+	token.ARROW:        "ARROW",
+	token.QUESTION:     "QUESTION",
+	token.ADDASSIGN:    "ADDASSIGN",
+	token.SUBASSIGN:    "SUBASSIGN",
+	token.MULASSIGN:    "MULASSIGN",
+	token.DIVASSIGN:    "DIVASSIGN",
+	token.IDIVASSIGN:   "IDIVASSIGN",
+	token.MODASSIGN:    "MODASSIGN",
+	token.POWASSIGN:    "POWASSIGN",
+	token.CATASSIGN:    "CATASSIGN",
+	token.PLUS:         "PLUS",
+	token.ASTERISK:     "ASTERISK",
+	token.SLASH:        "SLASH",
+	token.DSLASH:       "DSLASH",
+	token.PERCENT:      "PERCENT",
+	token.CARET:        "CARET",
+	token.CONCAT:       "CONCAT",
+	token.LT:           "LT",
+	token.LEQ:          "LEQ",
+	token.GT:           "GT",
+	token.GEQ:          "GEQ",
+	token.EQ:           "EQ",
+	token.NEQ:          "NEQ",
+	token.AMPERSAND:    "AMPERSAND",
+	token.PIPE:         "PIPE",
+	token.SHL:          "SHL",
+	token.SHR:          "SHR",
+	token.MINUS:        "MINUS",
+	token.TILDE:        "TILDE",
+	token.HASH:         "HASH",
+	token.NOT:          "NOT",
+	token.WHILE:        "WHILE",
+	token.UNTIL:        "UNTIL",
+	token.IF:           "IF",
+	token.ELSEIF:       "ELSEIF",
+	token.IN:           "IN",
+	token.RETURN:       "RETURN",
+	token.AND:          "AND",
+	token.OR:           "OR",
+	token.DO:           "DO",
+	token.END:          "END",
+	token.REPEAT:       "REPEAT",
+	token.THEN:         "THEN",
+	token.ELSE:         "ELSE",
+	token.FOR:          "FOR",
+	token.LOCAL:        "LOCAL",
+	token.FUNCTION:     "FUNCTION",
+	token.BREAK:        "BREAK",
+	token.GOTO:         "GOTO",
+	token.NIL:          "NIL",
+	token.FALSE:        "FALSE",
+	token.TRUE:         "TRUE",
+}
+
+// nodes contains a value of each node type, used to map node types to the
+// names used in the encoding.
+var nodes = []tree.Node{
+	(*tree.File)(nil),
+	(*tree.Block)(nil),
+	(*tree.ExprList)(nil),
+	(*tree.NameList)(nil),
+	(*tree.Attrib)(nil),
+	(*tree.TypeAnnot)(nil),
+	(*tree.BadExpr)(nil),
+	(*tree.NumberExpr)(nil),
+	(*tree.StringExpr)(nil),
+	(*tree.NilExpr)(nil),
+	(*tree.BoolExpr)(nil),
+	(*tree.VarArgExpr)(nil),
+	(*tree.UnopExpr)(nil),
+	(*tree.BinopExpr)(nil),
+	(*tree.ParenExpr)(nil),
+	(*tree.VariableExpr)(nil),
+	(*tree.TableCtor)(nil),
+	(*tree.EntryList)(nil),
+	(*tree.IndexEntry)(nil),
+	(*tree.FieldEntry)(nil),
+	(*tree.ValueEntry)(nil),
+	(*tree.FunctionExpr)(nil),
+	(*tree.FieldExpr)(nil),
+	(*tree.IndexExpr)(nil),
+	(*tree.MethodExpr)(nil),
+	(*tree.CallExpr)(nil),
+	(*tree.IfExpr)(nil),
+	(*tree.ElseIfExprClause)(nil),
+	(*tree.InterpExpr)(nil),
+	(*tree.AssertExpr)(nil),
+	(*tree.ListArgs)(nil),
+	(*tree.TableArg)(nil),
+	(*tree.StringArg)(nil),
+	(*tree.BadStmt)(nil),
+	(*tree.MetaStmt)(nil),
+	(*tree.DoStmt)(nil),
+	(*tree.AssignStmt)(nil),
+	(*tree.CompoundAssignStmt)(nil),
+	(*tree.CallStmt)(nil),
+	(*tree.IfStmt)(nil),
+	(*tree.ElseIfClause)(nil),
+	(*tree.ElseClause)(nil),
+	(*tree.NumericForStmt)(nil),
+	(*tree.GenericForStmt)(nil),
+	(*tree.WhileStmt)(nil),
+	(*tree.RepeatStmt)(nil),
+	(*tree.LocalVarStmt)(nil),
+	(*tree.LocalFunctionStmt)(nil),
+	(*tree.FunctionStmt)(nil),
+	(*tree.FuncNameList)(nil),
+	(*tree.BreakStmt)(nil),
+	(*tree.ContinueStmt)(nil),
+	(*tree.GotoStmt)(nil),
+	(*tree.LabelStmt)(nil),
+	(*tree.TypeStmt)(nil),
+	(*tree.ReturnStmt)(nil),
+	(*tree.TypeList)(nil),
+	(*tree.GenericList)(nil),
+	(*tree.GenericParam)(nil),
+	(*tree.NamedType)(nil),
+	(*tree.TypeParams)(nil),
+	(*tree.LiteralType)(nil),
+	(*tree.TypeofType)(nil),
+	(*tree.TableType)(nil),
+	(*tree.TypeEntryList)(nil),
+	(*tree.TypeIndexEntry)(nil),
+	(*tree.TypeFieldEntry)(nil),
+	(*tree.TypeValueEntry)(nil),
+	(*tree.FunctionType)(nil),
+	(*tree.ParamType)(nil),
+	(*tree.ParenType)(nil),
+	(*tree.PackType)(nil),
+	(*tree.VariadicType)(nil),
+	(*tree.GenericPackType)(nil),
+	(*tree.OptionalType)(nil),
+	(*tree.UnionType)(nil),
+	(*tree.IntersectionType)(nil),
+}
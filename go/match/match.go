@@ -0,0 +1,280 @@
+// The match package implements structural search and replace over parse
+// trees. A pattern is Lua source code that may contain metavariables, as
+// described by the tmpl package, and is matched against the nodes of a tree
+// while ignoring whitespace and comments.
+//
+//	p := match.MustCompile("$x == nil or $x == false")
+//	for _, m := range p.Find(file) {
+//		fmt.Println(file.Info.Position(m.Node.Offset()))
+//	}
+package match
+
+import (
+	"errors"
+	"fmt"
+	"github.com/anaminus/luasyntax/go/build"
+	"github.com/anaminus/luasyntax/go/tmpl"
+	"github.com/anaminus/luasyntax/go/token"
+	"github.com/anaminus/luasyntax/go/tree"
+)
+
+// Kind indicates the kind of node matched by a pattern.
+type Kind int
+
+const (
+	Expr  Kind = iota // A single expression.
+	Stmt              // A single statement.
+	Stmts             // A sequence of consecutive statements within a block.
+)
+
+var kinds = [...]string{
+	Expr:  "expression",
+	Stmt:  "statement",
+	Stmts: "statements",
+}
+
+// String returns a string representation of the kind.
+func (k Kind) String() string {
+	if 0 <= k && int(k) < len(kinds) {
+		return kinds[k]
+	}
+	return "<invalid>"
+}
+
+// Pattern is a compiled pattern.
+type Pattern struct {
+	kind Kind
+	tmpl *tmpl.Template
+	// Number of statements matched by a Stmts pattern.
+	n int
+}
+
+// Config configures how a pattern is compiled. The zero value is a valid
+// configuration.
+type Config struct {
+	// Dialect is the dialect of Lua with which the pattern is parsed.
+	Dialect token.Dialect
+}
+
+// Compile parses src as a pattern. The source is parsed as an expression if
+// possible, then as a statement, and then as a sequence of statements. If
+// none succeed, then the error from parsing a sequence is returned.
+func (c *Config) Compile(src string) (*Pattern, error) {
+	tc := tmpl.Config{Dialect: c.Dialect}
+	if t, err := tc.ParseExpr(src); err == nil {
+		return &Pattern{kind: Expr, tmpl: t}, nil
+	}
+	if t, err := tc.ParseStmt(src); err == nil {
+		return &Pattern{kind: Stmt, tmpl: t}, nil
+	}
+	t, err := tc.ParseBlock(src)
+	if err != nil {
+		return nil, err
+	}
+	block := t.Node().(*tree.Block)
+	if len(block.Items) == 0 {
+		return nil, errors.New("empty pattern")
+	}
+	return &Pattern{kind: Stmts, tmpl: t, n: len(block.Items)}, nil
+}
+
+// Compile parses src as a pattern in Lua 5.1. See Config.Compile for details.
+func Compile(src string) (*Pattern, error) {
+	return (&Config{}).Compile(src)
+}
+
+// MustCompile is like Compile, but panics if the pattern could not be parsed.
+func MustCompile(src string) *Pattern {
+	p, err := Compile(src)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Kind returns the kind of node matched by the pattern.
+func (p *Pattern) Kind() Kind {
+	return p.kind
+}
+
+// Template returns the template of the pattern.
+func (p *Pattern) Template() *tmpl.Template {
+	return p.tmpl
+}
+
+// ParseReplacement parses src as a template with which matches of the pattern
+// are replaced. The template is parsed with the same kind and dialect as the
+// pattern. Returns an error if the template contains a metavariable that does
+// not appear in the pattern.
+func (p *Pattern) ParseReplacement(src string) (*tmpl.Template, error) {
+	tc := tmpl.Config{Dialect: p.tmpl.Dialect()}
+	var t *tmpl.Template
+	var err error
+	switch p.kind {
+	case Expr:
+		t, err = tc.ParseExpr(src)
+	case Stmt:
+		t, err = tc.ParseStmt(src)
+	default:
+		t, err = tc.ParseBlock(src)
+	}
+	if err != nil {
+		return nil, err
+	}
+	vars := map[string]bool{}
+	for _, name := range p.tmpl.Vars() {
+		vars[name] = true
+	}
+	for _, name := range t.Vars() {
+		if !vars[name] {
+			return nil, fmt.Errorf("metavariable $%s does not appear in pattern", name)
+		}
+	}
+	return t, nil
+}
+
+// Match describes a portion of a tree that matches a pattern.
+type Match struct {
+	// Node is the matched node. For a Stmts pattern, Node is a *tree.Block
+	// that is not a part of the tree, and contains the matched statements.
+	Node tree.Node
+	// Bindings holds the node bound to each metavariable of the pattern.
+	Bindings tmpl.Bindings
+}
+
+// matchBlock returns the first sequence of statements within block, starting
+// at index i, that matches a Stmts pattern, along with the index of the
+// sequence. Returns -1 if there is no match.
+func (p *Pattern) matchBlock(block *tree.Block, i int) (Match, int) {
+	for ; i+p.n <= len(block.Items); i++ {
+		seq := &tree.Block{Items: block.Items[i : i+p.n], Seps: block.Seps[i : i+p.n]}
+		if b, ok := p.tmpl.Match(seq); ok {
+			return Match{Node: seq, Bindings: b}, i
+		}
+	}
+	return Match{}, -1
+}
+
+// finder is a Visitor that collects matches.
+type finder struct {
+	p       *Pattern
+	matches []Match
+}
+
+func (f *finder) Visit(node tree.Node) tree.Visitor {
+	if node == nil {
+		return nil
+	}
+	if f.p.kind != Stmts {
+		if b, ok := f.p.tmpl.Match(node); ok {
+			f.matches = append(f.matches, Match{Node: node, Bindings: b})
+		}
+		return f
+	}
+	if block, ok := node.(*tree.Block); ok {
+		for m, i := f.p.matchBlock(block, 0); i >= 0; m, i = f.p.matchBlock(block, i+f.p.n) {
+			f.matches = append(f.matches, m)
+		}
+	}
+	return f
+}
+
+// Find returns every match of the pattern within the tree of node, in lexical
+// order. Matches may be nested within other matches. Matches of a Stmts
+// pattern within the same block do not overlap.
+func (p *Pattern) Find(node tree.Node) []Match {
+	f := finder{p: p}
+	tree.Walk(&f, node)
+	return f.matches
+}
+
+// replaceNode replaces the current node of c with n, returning an error if n
+// cannot be stored in the field that contains the current node.
+func replaceNode(c *tree.Cursor, n tree.Node) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("cannot replace %T with %T", c.Node(), n)
+		}
+	}()
+	c.Replace(n)
+	return nil
+}
+
+// setPrefix sets the prefix of the first token of node, if there is one.
+func setPrefix(node tree.Node, prefix []tree.Prefix) {
+	if tok := node.FirstToken(); tok != nil {
+		tok.Prefix = prefix
+	}
+}
+
+// Replace replaces each match of the pattern within the tree of node with an
+// instance of the repl template, and returns the resulting tree along with the
+// number of replacements. The tree is modified in place. Matches are replaced
+// from the bottom up, so that a match nested within another match is replaced
+// first, and replacements are not matched again.
+//
+// A replacement receives the spacing and comments that precede the match.
+// Expressions are enclosed in parentheses as needed, and adjoined tokens are
+// separated as needed. If node is a File, then the offsets of tokens are
+// updated.
+//
+// Returns an error if a replacement could not be instantiated, or cannot be
+// stored in place of the match. In this case, the tree may be partially
+// modified.
+func (p *Pattern) Replace(node tree.Node, repl *tmpl.Template) (result tree.Node, n int, err error) {
+	result = tree.Apply(node, nil, func(c *tree.Cursor) bool {
+		if p.kind == Stmts {
+			if block, ok := c.Node().(*tree.Block); ok {
+				var k int
+				k, err = p.replaceBlock(block, repl)
+				n += k
+			}
+			return err == nil
+		}
+		b, ok := p.tmpl.Match(c.Node())
+		if !ok {
+			return true
+		}
+		var r tree.Node
+		if r, err = repl.Instantiate(b); err != nil {
+			return false
+		}
+		if expr, ok := r.(tree.Expr); ok {
+			r = build.Parenthesize(c.Parent(), c.Name(), expr)
+		}
+		setPrefix(r, c.Node().FirstToken().Prefix)
+		if err = replaceNode(c, r); err != nil {
+			return false
+		}
+		n++
+		return true
+	})
+	tree.FixAdjoinedTokensDialect(result, p.tmpl.Dialect())
+	if file, ok := result.(*tree.File); ok {
+		tree.FixTokenOffsets(file, 0)
+	}
+	return result, n, err
+}
+
+// replaceBlock replaces each match of a Stmts pattern within block.
+func (p *Pattern) replaceBlock(block *tree.Block, repl *tmpl.Template) (n int, err error) {
+	for m, i := p.matchBlock(block, 0); i >= 0; m, i = p.matchBlock(block, i) {
+		r, err := repl.Instantiate(m.Bindings)
+		if err != nil {
+			return n, err
+		}
+		stmts, seps := r.(*tree.Block).Items, r.(*tree.Block).Seps
+		if len(stmts) > 0 {
+			setPrefix(stmts[0], block.Items[i].FirstToken().Prefix)
+			if last := len(seps) - 1; !seps[last].Type.IsValid() {
+				// Retain the separator following the sequence.
+				seps[last] = block.Seps[i+p.n-1]
+			}
+		}
+		block.Items = append(block.Items[:i], append(stmts, block.Items[i+p.n:]...)...)
+		block.Seps = append(block.Seps[:i], append(seps, block.Seps[i+p.n:]...)...)
+		i += len(stmts)
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,339 @@
+package match
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/anaminus/luasyntax/go/parser"
+	"github.com/anaminus/luasyntax/go/token"
+	"github.com/anaminus/luasyntax/go/tree"
+	"strings"
+	"testing"
+)
+
+func source(node tree.Node) string {
+	var buf bytes.Buffer
+	node.WriteTo(&buf)
+	return buf.String()
+}
+
+const code = `local a = x == nil or x == false
+if f(y) == nil or f(y) == false then
+	print(a)
+end
+local b = g(
+	1,
+	2
+) .. h
+old.api(1,
+	2)
+local c = old.api(3)
+`
+
+func TestKindString(t *testing.T) {
+	tests := []struct {
+		kind Kind
+		want string
+	}{
+		{Expr, "expression"},
+		{Stmt, "statement"},
+		{Stmts, "statements"},
+		{Kind(-1), "<invalid>"},
+		{Kind(3), "<invalid>"},
+	}
+	for _, test := range tests {
+		if got := test.kind.String(); got != test.want {
+			t.Errorf("%d: expected %q, got %q", test.kind, test.want, got)
+		}
+	}
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		src  string
+		kind Kind
+		err  bool
+	}{
+		{"$x == nil", Expr, false},
+		{"f($x)", Expr, false},
+		{"$x = $y", Stmt, false},
+		{"local $v = $e", Stmt, false},
+		{"$s", Expr, false},
+		{"local $v = $e\nif $c then $s end", Stmts, false},
+		{"a() b()", Stmts, false},
+		{"", 0, true},
+		{"-- comment", 0, true},
+		{"$x +", 0, true},
+		{"if $c then", 0, true},
+	}
+	for _, test := range tests {
+		p, err := Compile(test.src)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: expected error", test.src)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", test.src, err)
+			continue
+		}
+		if p.Kind() != test.kind {
+			t.Errorf("%q: expected kind %s, got %s", test.src, test.kind, p.Kind())
+		}
+		if got := source(p.Template().Node()); got != test.src {
+			t.Errorf("%q: expected template source, got %q", test.src, got)
+		}
+	}
+}
+
+func TestCompileDialect(t *testing.T) {
+	const src = "$a // $b"
+	if _, err := Compile(src); err == nil {
+		t.Errorf("%q: expected error with Lua51", src)
+	}
+	p, err := (&Config{Dialect: token.Lua53}).Compile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Template().Dialect() != token.Lua53 {
+		t.Errorf("expected Lua53, got %v", p.Template().Dialect())
+	}
+	if _, err := p.ParseReplacement("$a & $b"); err != nil {
+		t.Errorf("expected replacement parsed as Lua53, got %s", err)
+	}
+}
+
+func TestMustCompilePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+	MustCompile("$x +")
+}
+
+func TestFind(t *testing.T) {
+	f, err := parser.ParseFile("", code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		pattern string
+		// Position and first binding of each match.
+		want []string
+	}{
+		{"$x == nil or $x == false", []string{"1:11 x", "2:4 f(y)"}},
+		{"old.api($a, $b)", []string{"9:1 1"}},
+		{"old.api($a)", []string{"11:11 3"}},
+		{"$f($a, $b)", []string{"5:11 g", "9:1 old.api"}},
+		{"local $v = $e\nif $c then $s end", []string{"1:1 a"}},
+		{"local $v = $e", []string{"1:1 a", "5:1 b", "11:1 c"}},
+		{"print($a)", []string{"3:2 a"}},
+		{"new.api($a)", nil},
+	}
+	for _, test := range tests {
+		p := MustCompile(test.pattern)
+		vars := p.Template().Vars()
+		var got []string
+		for _, m := range p.Find(f) {
+			pos := f.Info.Position(m.Node.Offset())
+			s := fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+			if len(vars) > 0 {
+				s += " " + strings.TrimSpace(source(m.Bindings[vars[0]]))
+			}
+			got = append(got, s)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("%q: expected %q, got %q", test.pattern, test.want, got)
+		}
+	}
+}
+
+func TestFindStmts(t *testing.T) {
+	f, err := parser.ParseFile("", "a() b() a() b() b() a() b()")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := MustCompile("$x() b()")
+	ms := p.Find(f)
+	if len(ms) != 3 {
+		t.Fatalf("expected 3 matches, got %d", len(ms))
+	}
+	for i, m := range ms {
+		block, ok := m.Node.(*tree.Block)
+		if !ok {
+			t.Errorf("%d: expected *tree.Block, got %T", i, m.Node)
+			continue
+		}
+		if n := len(block.Items); n != 2 {
+			t.Errorf("%d: expected 2 statements, got %d", i, n)
+		}
+	}
+	if n := len(f.Body.Items); n != 7 {
+		t.Errorf("expected tree to be unmodified, got %d statements", n)
+	}
+}
+
+func TestParseReplacement(t *testing.T) {
+	tests := []struct {
+		pattern string
+		repl    string
+		err     string
+	}{
+		{"$x == nil or $x == false", "not $x", ""},
+		{"$x == nil or $x == false", "not $y", "metavariable $y does not appear in pattern"},
+		{"$x == nil or $x == false", "nil", ""},
+		{"local $v = $e", "local $v <const> = $e", ":1:10: '<eof>' expected"},
+		{"local $v = $e", "$v = $e", ""},
+		{"a() b()", "c()", ""},
+		{"a() b()", "", ""},
+	}
+	for _, test := range tests {
+		p := MustCompile(test.pattern)
+		_, err := p.ParseReplacement(test.repl)
+		var got string
+		if err != nil {
+			got = err.Error()
+		}
+		if got != test.err {
+			t.Errorf("%q, %q: expected error %q, got %q", test.pattern, test.repl, test.err, got)
+		}
+	}
+}
+
+func TestReplace(t *testing.T) {
+	tests := []struct {
+		src     string
+		pattern string
+		repl    string
+		want    string
+		n       int
+	}{
+		{
+			"local a = x == nil or x == false\n",
+			"$x == nil or $x == false", "not $x",
+			"local a = not x\n", 1,
+		},
+		{
+			"old.api(1, 2)\nlocal c = old.api(3)\n",
+			"old.api($a)", "new.api($a, nil)",
+			"old.api(1, 2)\nlocal c = new.api(3, nil)\n", 1,
+		},
+		{
+			"old.api(1,\n\t2)\n",
+			"old.api($a, $b)", "new.api($b, $a)",
+			"new.api(2, 1)\n", 1,
+		},
+		{
+			"local b = g(\n\t1,\n\t2\n) .. h\n",
+			"g($a, $b) .. $c", "$a + $b",
+			"local b = 1 + 2\n", 1,
+		},
+		{
+			"x = a * b\n",
+			"$a * $b", "$a + $b",
+			"x = a + b\n", 1,
+		},
+		{
+			"x = (a * b) * c\n",
+			"$a * $b", "$a - $b",
+			"x = (a - b) - c\n", 2,
+		},
+		{
+			"x = -a\n",
+			"-$a", "$a * 2",
+			"x = a * 2\n", 1,
+		},
+		{
+			"x = -y * 2\n",
+			"-$a", "$a + 1",
+			"x = (y + 1) * 2\n", 1,
+		},
+		{
+			"-- header\nlocal x = f()\nreturn x\n",
+			"local $v = $e\nreturn $v", "return $e",
+			"-- header\nreturn f()\n", 1,
+		},
+		{
+			"a() b() a() b() b()",
+			"a() b()", "c()",
+			"c() c() b()", 2,
+		},
+		{
+			"a() b() c()",
+			"a() b()", "",
+			" c()", 1,
+		},
+		{
+			"print(x)\n",
+			"print($a)", "print($a)",
+			"print(x)\n", 1,
+		},
+		{
+			"local x = 1\n",
+			"y", "z",
+			"local x = 1\n", 0,
+		},
+	}
+	for _, test := range tests {
+		f, err := parser.ParseFile("", test.src)
+		if err != nil {
+			t.Errorf("%q: %s", test.src, err)
+			continue
+		}
+		p := MustCompile(test.pattern)
+		r, err := p.ParseReplacement(test.repl)
+		if err != nil {
+			t.Errorf("%q: %s", test.repl, err)
+			continue
+		}
+		_, n, err := p.Replace(f, r)
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", test.src, err)
+			continue
+		}
+		if n != test.n {
+			t.Errorf("%q: expected %d replacements, got %d", test.src, test.n, n)
+		}
+		got := source(f)
+		if got != test.want {
+			t.Errorf("%q: expected %q, got %q", test.src, test.want, got)
+		}
+		if f.EOFToken.Offset != len(got) {
+			t.Errorf("%q: expected EOF offset %d, got %d", test.src, len(got), f.EOFToken.Offset)
+		}
+	}
+}
+
+func TestReplaceErrors(t *testing.T) {
+	tests := []struct {
+		src     string
+		pattern string
+		repl    string
+		err     string
+	}{
+		{"f()\n", "f()", "x", "cannot replace *tree.CallExpr with *tree.VariableExpr"},
+		{"local x = f()\n", "local $v = $e", "local $e = $v", "metavariable $e: name expected"},
+	}
+	for _, test := range tests {
+		f, err := parser.ParseFile("", test.src)
+		if err != nil {
+			t.Errorf("%q: %s", test.src, err)
+			continue
+		}
+		p := MustCompile(test.pattern)
+		r, err := p.ParseReplacement(test.repl)
+		if err != nil {
+			t.Errorf("%q: %s", test.repl, err)
+			continue
+		}
+		_, _, err = p.Replace(f, r)
+		var got string
+		if err != nil {
+			got = err.Error()
+		}
+		if got != test.err {
+			t.Errorf("%q: expected error %q, got %q", test.src, test.err, got)
+		}
+	}
+}
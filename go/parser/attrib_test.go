@@ -0,0 +1,57 @@
+package parser
+
+import (
+	"github.com/anaminus/luasyntax/go/token"
+	"github.com/anaminus/luasyntax/go/tree"
+	"strings"
+	"testing"
+)
+
+func TestAttribSyntax(t *testing.T) {
+	testDialects(t, []dialectTest{
+		{token.Lua53, "local x <const> = 1", false},
+		{token.Lua54, "local x <const> = 1", true},
+		{token.Lua54, "local x <close> = f()", true},
+		{token.Lua54, "local x <const>, y, z < close > = 5, 6", true},
+		{token.Lua54, "local x <foo> = 1", false},
+		{token.Lua54, "local x <close>, y <close> = 1", false},
+		{token.Lua54, "local x <const>", true},
+		{token.Lua54, "local x < const = 1", false},
+		{token.Lua54, "local function f <const> () end", false},
+		{token.Lua54, "for i <const> = 1, 2 do end", false},
+	})
+}
+
+func TestAttribNames(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string // Attribute of each name, separated by commas.
+	}{
+		{"local a = 1", ""},
+		{"local a <const> = 1", "const"},
+		{"local a, b <close>, c = 1", ",close,"},
+		{"local a <const>, b <const>", "const,const"},
+	}
+	config := Config{Dialect: token.Lua54}
+	for _, test := range tests {
+		stmt, err := config.ParseStmt("", test.src)
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", test.src, err)
+			continue
+		}
+		var attribs []string
+		for _, a := range stmt.(*tree.LocalVarStmt).Names.Attribs {
+			if a == nil {
+				attribs = append(attribs, "")
+			} else {
+				attribs = append(attribs, string(a.NameToken.Bytes))
+			}
+		}
+		if got := strings.Join(attribs, ","); got != test.want {
+			t.Errorf("%q: expected attributes %q, got %q", test.src, test.want, got)
+		}
+		if !stmt.IsValid() {
+			t.Errorf("%q: expected valid statement", test.src)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"github.com/anaminus/luasyntax/go/token"
+	"strings"
+	"testing"
+)
+
+func TestDialectNumbers(t *testing.T) {
+	testDialects(t, []dialectTest{
+		{token.Lua51, "x = 0x1F", true},
+		{token.Lua51, "x = 0x1.8p3", false},
+		{token.Lua52, "x = 0x1.8p3", true},
+		{token.Lua52, "x = 0x.8P-3", true},
+		{token.Lua52, "x = 0x1e+5", true},
+		{token.Lua52, "x = 1e", false},
+		{token.Lua53, "x = 1.5e+10", true},
+		{token.Lua53, "x = .5", true},
+		{token.Lua53, "x = 5.", true},
+		{token.Lua53, "x = 1..2", false},
+		{token.Lua53, "x = 0b101", false},
+		{token.Lua53, "x = 3abc", false},
+		{token.Luau, "x = 0b101", true},
+		{token.Luau, "x = 0B1_0", true},
+		{token.Luau, "x = 1_000_000", true},
+		{token.Luau, "x = 0x1.8", false},
+		{token.LuaJIT, "x = 0b101", true},
+		{token.LuaJIT, "x = 1_000", false},
+	})
+}
+
+func TestDialectEscapes(t *testing.T) {
+	tests := []struct {
+		dialect token.Dialect
+		src     string
+		err     string
+	}{
+		{token.Lua51, `x = "\q"`, ""},
+		{token.Lua52, `x = "\q"`, "invalid escape"},
+		{token.Lua51, `x = "\x41"`, ""},
+		{token.Lua52, `x = "\x41"`, ""},
+		{token.Lua52, `x = "\x4"`, "hexadecimal digit"},
+		{token.Lua52, "x = \"a\\z  \n  b\"", ""},
+		{token.Lua52, `x = "\u{41}"`, "invalid escape"},
+		{token.Lua53, `x = "\u{41}"`, ""},
+		{token.Lua53, `x = "\u{110000}"`, "too large"},
+		{token.Lua54, `x = "\u{7FFFFFFF}"`, ""},
+		{token.Lua54, `x = "\u{80000000}"`, "too large"},
+		{token.Lua54, `x = "\u{FFFFFFFFFFFFFFFFFFFF}"`, "too large"},
+		{token.Lua53, `x = "\u41"`, "missing '{'"},
+		{token.Lua53, `x = "\u{41"`, "missing '}'"},
+		{token.Lua53, `x = "\256"`, "too large"},
+		{token.Lua53, `x = "\255\0\12"`, ""},
+		{token.Luau, `x = "\u{41}\z  "`, ""},
+		{token.Lua51, "x = \"a\\\nb\"", ""},
+	}
+	for _, test := range tests {
+		_, err := (&Config{Dialect: test.dialect}).ParseFile("", test.src)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s %q: unexpected error %s", test.dialect, test.src, err)
+		case test.err != "" && err == nil:
+			t.Errorf("%s %q: expected error %q", test.dialect, test.src, test.err)
+		case test.err != "" && !strings.Contains(err.Error(), test.err):
+			t.Errorf("%s %q: expected error %q, got %q", test.dialect, test.src, test.err, err)
+		}
+	}
+}
+
+func TestDialectBreak(t *testing.T) {
+	testDialects(t, []dialectTest{
+		{token.Lua51, "while x do break end", true},
+		{token.Lua51, "while x do break f() end", false},
+		{token.Lua52, "while x do break f() end", true},
+		{token.Lua54, "while x do break break end", true},
+	})
+}
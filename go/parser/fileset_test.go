@@ -0,0 +1,58 @@
+package parser
+
+import (
+	"github.com/anaminus/luasyntax/go/token"
+	"testing"
+)
+
+func TestFileSet(t *testing.T) {
+	fset := token.NewFileSet()
+	config := Config{FileSet: fset}
+	a, err := config.ParseFile("a.lua", "x = 1\ny = 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := config.ParseFile("b.lua", "local z\nz = 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		p    token.Pos
+		want string
+	}{
+		{a.Info.Pos(0), "a.lua:1:1"},
+		{a.Info.Pos(11), "a.lua:2:6"},
+		{b.Info.Pos(8), "b.lua:2:1"},
+		{b.Info.Pos(13), "b.lua:2:6"},
+	}
+	for _, test := range tests {
+		if got := fset.Position(test.p).String(); got != test.want {
+			t.Errorf("%d: expected %s, got %s", test.p, test.want, got)
+		}
+	}
+	if a.Info.Pos(11) >= b.Info.Pos(0) {
+		t.Error("expected positions of a.lua to precede positions of b.lua")
+	}
+
+	// A file that cannot be parsed is still registered.
+	if _, err := config.ParseFile("c.lua", "x ="); err == nil {
+		t.Error("expected error")
+	}
+	n := 0
+	fset.Iterate(func(*token.File) bool {
+		n++
+		return true
+	})
+	if n != 3 {
+		t.Errorf("expected 3 files, got %d", n)
+	}
+
+	// Without a set, each file has the same base.
+	f, err := ParseFile("d.lua", "x = 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base := f.Info.Base(); base != 1 {
+		t.Errorf("expected base 1, got %d", base)
+	}
+}
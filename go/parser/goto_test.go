@@ -0,0 +1,94 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/anaminus/luasyntax/go/token"
+	"github.com/anaminus/luasyntax/go/tree"
+	"testing"
+)
+
+// source returns the source code written by node.
+func source(node tree.Node) string {
+	var buf bytes.Buffer
+	node.WriteTo(&buf)
+	return buf.String()
+}
+
+// dialectTest describes whether a source parses successfully in a dialect.
+type dialectTest struct {
+	dialect token.Dialect
+	src     string
+	ok      bool
+}
+
+// testDialects checks that each source parses as expected, and that a parsed
+// file writes back the original source.
+func testDialects(t *testing.T, tests []dialectTest) {
+	t.Helper()
+	for _, test := range tests {
+		config := Config{Dialect: test.dialect}
+		f, err := config.ParseFile("", test.src)
+		if (err == nil) != test.ok {
+			if test.ok {
+				t.Errorf("%s %q: unexpected error %s", test.dialect, test.src, err)
+			} else {
+				t.Errorf("%s %q: expected error", test.dialect, test.src)
+			}
+			continue
+		}
+		if test.ok {
+			if got := source(f); got != test.src {
+				t.Errorf("%s %q: expected round trip, got %q", test.dialect, test.src, got)
+			}
+		}
+	}
+}
+
+func TestGoto(t *testing.T) {
+	testDialects(t, []dialectTest{
+		{token.Lua51, "local goto = 1\ngoto = 2", true},
+		{token.Lua51, "goto x", false},
+		{token.Lua51, "::x::", false},
+		{token.Lua52, "goto continue\n::continue::", true},
+		{token.Lua52, "do goto a end ::a:: ;", true},
+		{token.Lua52, "for i = 1, 2 do\n  goto next\n  ::next::\nend", true},
+		{token.Lua52, ":: a ::", true},
+		{token.Lua52, "goto", false},
+		{token.Lua52, "::a", false},
+		{token.Lua52, "local goto = 1", false},
+		{token.Lua53, "::a:: goto a", true},
+		{token.Lua54, "::a:: goto a", true},
+		{token.LuaJIT, "::a:: goto a", true},
+	})
+}
+
+func TestLabelStmt(t *testing.T) {
+	tests := []struct {
+		src  string
+		typ  string
+		name string
+	}{
+		{"::a::", "*tree.LabelStmt", "a"},
+		{":: b ::", "*tree.LabelStmt", "b"},
+		{"goto c", "*tree.GotoStmt", "c"},
+	}
+	config := Config{Dialect: token.Lua52}
+	for _, test := range tests {
+		stmt, err := config.ParseStmt("", test.src)
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", test.src, err)
+			continue
+		}
+		var name string
+		switch s := stmt.(type) {
+		case *tree.LabelStmt:
+			name = string(s.NameToken.Bytes)
+		case *tree.GotoStmt:
+			name = string(s.NameToken.Bytes)
+		}
+		if typ := fmt.Sprintf("%T", stmt); typ != test.typ || name != test.name {
+			t.Errorf("%q: expected %s named %q, got %s named %q", test.src, test.typ, test.name, typ, name)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package parser
+
+import (
+	"github.com/anaminus/luasyntax/go/token"
+	"github.com/anaminus/luasyntax/go/tree"
+	"testing"
+)
+
+func TestJITNumbers(t *testing.T) {
+	tests := []struct {
+		src string
+		typ token.Type
+	}{
+		{"123LL", token.NUMBERI64},
+		{"123ll", token.NUMBERI64},
+		{"123ULL", token.NUMBERU64},
+		{"123uLL", token.NUMBERU64},
+		{"0x10LL", token.NUMBERI64},
+		{"0x10ull", token.NUMBERU64},
+		{"0b101LL", token.NUMBERI64},
+		{"12i", token.NUMBERIMAG},
+		{"1.5e2I", token.NUMBERIMAG},
+		{".5i", token.NUMBERIMAG},
+		{"0x10i", token.NUMBERIMAG},
+		{"0b11", token.NUMBERBIN},
+		{"1.5", token.NUMBERFLOAT},
+		{"0x1F", token.NUMBERHEX},
+	}
+	config := Config{Dialect: token.LuaJIT}
+	for _, test := range tests {
+		expr, err := config.ParseExpr("", test.src)
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", test.src, err)
+			continue
+		}
+		n, ok := expr.(*tree.NumberExpr)
+		if !ok {
+			t.Errorf("%q: expected *tree.NumberExpr, got %T", test.src, expr)
+			continue
+		}
+		if n.NumberToken.Type != test.typ {
+			t.Errorf("%q: expected %s, got %s", test.src, test.typ, n.NumberToken.Type)
+		}
+	}
+}
+
+func TestJITNumberSyntax(t *testing.T) {
+	testDialects(t, []dialectTest{
+		{token.LuaJIT, "x = 1LL + 2ULL * 3i", true},
+		{token.LuaJIT, "x = a..1LL", true},
+		{token.LuaJIT, "x = 1.5LL", false},
+		{token.LuaJIT, "x = 1e3ULL", false},
+		{token.LuaJIT, "x = 1LLU", false},
+		{token.LuaJIT, "x = 1L", false},
+		{token.LuaJIT, "x = 1U", false},
+		{token.LuaJIT, "x = 1ii", false},
+		{token.LuaJIT, "x = 1LLi", false},
+		{token.Lua51, "x = 1LL", false},
+		{token.Lua51, "x = 1i", false},
+		{token.Lua53, "x = 1ULL", false},
+	})
+}
@@ -0,0 +1,102 @@
+package parser
+
+import (
+	"fmt"
+	"github.com/anaminus/luasyntax/go/token"
+	"github.com/anaminus/luasyntax/go/tree"
+	"testing"
+)
+
+var luauTests = []dialectTest{
+	{token.Luau, "local x: number = 5", true},
+	{token.Luau, "local x: number?, y: string | nil = 1, 'a'", true},
+	{token.Luau, "local function f<T>(a: T, ...: number): (T, ...number) return a end", true},
+	{token.Luau, "function m.f(self: Foo, b: {[string]: number}): () -> () end", true},
+	{token.Luau, "type Point = {x: number, y: number}", true},
+	{token.Luau, "export type List<T = number> = {T}", true},
+	{token.Luau, "type F = <T>(T, x: string) -> T...", true},
+	{token.Luau, "type G<T...> = (T...) -> ()", true},
+	{token.Luau, "type U = | 'a' | 'b'", true},
+	{token.Luau, "type I = A & B & (C)", true},
+	{token.Luau, "type M = mod.Type<number, string>", true},
+	{token.Luau, "type E = Foo<>", true},
+	{token.Luau, "type T = typeof(x)", true},
+	{token.Luau, "type T = ((number) -> string)?", true},
+	{token.Luau, "type T = {read: number; [number]: boolean}", true},
+	{token.Luau, "x += 1 y -= 2 z *= 3 w /= 4 v //= 5 u %= 6 t ^= 7 s ..= 'a'", true},
+	{token.Luau, "for i = 1, 10 do if i == 5 then continue end end", true},
+	{token.Luau, "for k: string, v: number in pairs(t) do end", true},
+	{token.Luau, "for i: number = 1, 2 do end", true},
+	{token.Luau, "local y = if a then b elseif c then d else e", true},
+	{token.Luau, "local s = `hello {name}, you are {age + 1} years {`nested {x}`}`", true},
+	{token.Luau, "local s = `plain \\{ text`", true},
+	{token.Luau, "local t = `{ {1} }`", true},
+	{token.Luau, "local v = x :: number", true},
+	{token.Luau, "local v = (x :: any) :: {number}", true},
+	{token.Luau, "local continue = 1 continue = 2 type = 3 export = 4", true},
+	{token.Luau, "local a = x < y and z > w", true},
+	{token.Luau, "local f = function<T>(x: T): T return x end", true},
+	{token.Luau, "local y = if a then b", false},
+	{token.Luau, "x, y += 1", false},
+	{token.Luau, "type = = 1", false},
+	{token.Luau, "x = a | b", false},
+	{token.Luau, "local s = `a {b`", false},
+	{token.Lua51, "x += 1", false},
+	{token.Lua51, "local x: number = 1", false},
+	{token.Lua51, "local s = `a`", false},
+	{token.Lua51, "continue = 1 local continue", true},
+	{token.Lua53, "x = a | b & c :: d", false},
+}
+
+func TestLuau(t *testing.T) {
+	testDialects(t, luauTests)
+	for _, test := range luauTests {
+		if !test.ok {
+			continue
+		}
+		f, _ := (&Config{Dialect: test.dialect}).ParseFile("", test.src)
+		if !f.IsValid() {
+			t.Errorf("%s %q: expected valid tree", test.dialect, test.src)
+		}
+		// Spacing can be removed and restored without changing the meaning.
+		tree.Walk(stripper{}, f)
+		tree.FixAdjoinedTokensDialect(f, test.dialect)
+		g, err := (&Config{Dialect: test.dialect}).ParseFile("", source(f))
+		if err != nil {
+			t.Errorf("%s %q: unexpected error %s", test.dialect, source(f), err)
+			continue
+		}
+		if !tree.Equal(f, g, tree.IgnoreSpace|tree.IgnoreOffsets) {
+			t.Errorf("%s %q: expected equal tree after reparsing", test.dialect, source(f))
+		}
+	}
+}
+
+func TestLuauStmts(t *testing.T) {
+	tests := []struct {
+		src string
+		typ string
+	}{
+		{"continue", "*tree.ContinueStmt"},
+		{"continue()", "*tree.CallStmt"},
+		{"continue = 1", "*tree.AssignStmt"},
+		{"x += 1", "*tree.CompoundAssignStmt"},
+		{"type T = number", "*tree.TypeStmt"},
+		{"export type T = number", "*tree.TypeStmt"},
+		{"type(x)", "*tree.CallStmt"},
+		{"export = 1", "*tree.AssignStmt"},
+	}
+	config := Config{Dialect: token.Luau}
+	for _, test := range tests {
+		src := "while x do " + test.src + " end"
+		stmt, err := config.ParseStmt("", src)
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", src, err)
+			continue
+		}
+		body := stmt.(*tree.WhileStmt).Body
+		if typ := fmt.Sprintf("%T", body.Items[0]); typ != test.typ {
+			t.Errorf("%q: expected %s, got %s", test.src, test.typ, typ)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package parser
+
+import (
+	"github.com/anaminus/luasyntax/go/token"
+	"github.com/anaminus/luasyntax/go/tree"
+	"strings"
+	"testing"
+)
+
+// grouped returns the source of expr with each operation enclosed in
+// parentheses, and without spacing.
+func grouped(expr tree.Expr) string {
+	switch e := expr.(type) {
+	case *tree.BinopExpr:
+		return "(" + grouped(e.Left) + " " + string(e.BinopToken.Bytes) + " " + grouped(e.Right) + ")"
+	case *tree.UnopExpr:
+		if e.UnopToken.Type == token.NOT {
+			return "(not " + grouped(e.Operand) + ")"
+		}
+		return "(" + string(e.UnopToken.Bytes) + grouped(e.Operand) + ")"
+	}
+	return strings.TrimSpace(source(expr))
+}
+
+func TestPrecedence(t *testing.T) {
+	tests := []struct {
+		dialect token.Dialect
+		src     string
+		want    string
+	}{
+		{token.Lua51, "1 + 2 * 3", "(1 + (2 * 3))"},
+		{token.Lua51, "a or b and c", "(a or (b and c))"},
+		{token.Lua51, "2 ^ -3 ^ 2", "(2 ^ (-(3 ^ 2)))"},
+		{token.Lua51, "-a .. b .. c", "((-a) .. (b .. c))"},
+		{token.Lua51, "not a == b", "((not a) == b)"},
+		{token.Lua53, "1 | 2 ~ 3 & 4 << 5 .. 6 + 7 // 8", "(1 | (2 ~ (3 & (4 << (5 .. (6 + (7 // 8)))))))"},
+		{token.Lua53, "~a ~ b", "((~a) ~ b)"},
+		{token.Lua53, "a < b | c", "(a < (b | c))"},
+		{token.Lua53, "a >> 1 ~= b", "((a >> 1) ~= b)"},
+		{token.Lua53, "a // b // c", "((a // b) // c)"},
+		{token.Lua53, "a & b | c ~ d", "((a & b) | (c ~ d))"},
+		{token.Lua54, "~~a << 1", "((~(~a)) << 1)"},
+	}
+	for _, test := range tests {
+		expr, err := (&Config{Dialect: test.dialect}).ParseExpr("", test.src)
+		if err != nil {
+			t.Errorf("%s %q: unexpected error %s", test.dialect, test.src, err)
+			continue
+		}
+		if got := grouped(expr); got != test.want {
+			t.Errorf("%s %q: expected %s, got %s", test.dialect, test.src, test.want, got)
+		}
+	}
+}
+
+func TestLua53Operators(t *testing.T) {
+	testDialects(t, []dialectTest{
+		{token.Lua51, "x = a // b", false},
+		{token.Lua51, "x = a & b", false},
+		{token.Lua51, "x = ~a", false},
+		{token.Lua52, "x = a << b", false},
+		{token.Lua53, "x = a // b", true},
+		{token.Lua53, "x = a & b | c ~ d", true},
+		{token.Lua53, "x = a << b >> c", true},
+		{token.Lua53, "x = ~a ~= b", true},
+		{token.Lua53, "x = a ~", false},
+		{token.Lua54, "x = a//b", true},
+	})
+}
+
+// stripper removes the prefixes of every token.
+type stripper struct{}
+
+func (s stripper) Visit(tree.Node) tree.Visitor { return s }
+
+func (s stripper) VisitToken(_ tree.Node, _ int, tok *tree.Token) {
+	tok.Prefix = nil
+}
+
+func TestFixAdjoinedOperators(t *testing.T) {
+	tests := []struct {
+		dialect token.Dialect
+		src     string
+		want    string
+	}{
+		{token.Lua51, "x = a / b / c", "x=a/b/c"},
+		{token.Lua51, "x = a - -b", "x=a- -b"},
+		{token.Lua51, "x = a < b", "x=a<b"},
+		{token.Lua53, "x = a / (b // c)", "x=a/(b//c)"},
+		{token.Lua53, "x = 1 << 2 < 3", "x=1<<2<3"},
+		{token.Lua53, "x = a ~ ~b", "x=a~~b"},
+		{token.Lua53, "x = a ~ (b == c)", "x=a~(b==c)"},
+		{token.Lua53, "x = a < ~b", "x=a<~b"},
+		{token.Lua53, "x = a ~ (b) == c", "x=a~(b)==c"},
+		{token.Lua53, "x = a .. .5", "x=a.. .5"},
+		{token.Lua53, "x = a and .5", "x=a and.5"},
+		{token.Lua52, "goto a ::b:: x = 1", "goto a::b::x=1"},
+	}
+	for _, test := range tests {
+		config := Config{Dialect: test.dialect}
+		f, err := config.ParseFile("", test.src)
+		if err != nil {
+			t.Errorf("%s %q: unexpected error %s", test.dialect, test.src, err)
+			continue
+		}
+		tree.Walk(stripper{}, f)
+		tree.FixAdjoinedTokensDialect(f, test.dialect)
+		got := source(f)
+		if got != test.want {
+			t.Errorf("%s %q: expected %q, got %q", test.dialect, test.src, test.want, got)
+		}
+		g, err := config.ParseFile("", got)
+		if err != nil {
+			t.Errorf("%s %q: unexpected error %s", test.dialect, got, err)
+			continue
+		}
+		if a, b := grouped(assignValue(f)), grouped(assignValue(g)); a != b {
+			t.Errorf("%s %q: expected %s after reparsing, got %s", test.dialect, got, a, b)
+		}
+	}
+}
+
+// assignValue returns the first value of the last assignment in f.
+func assignValue(f *tree.File) tree.Expr {
+	for i := len(f.Body.Items) - 1; i >= 0; i-- {
+		if s, ok := f.Body.Items[i].(*tree.AssignStmt); ok {
+			return s.Right.Items[0]
+		}
+	}
+	return nil
+}
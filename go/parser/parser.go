@@ -21,29 +21,81 @@ type tokenstate struct {
 	pre []tree.Prefix // Accumulated prefix tokens.
 }
 
+// A Mode value is a set of flags that control the behavior of the parser.
+type Mode uint
+
+const (
+	// RecoverErrors causes the parser to continue after encountering a syntax
+	// error. Statements and expressions that could not be parsed are replaced
+	// with tree.BadStmt and tree.BadExpr nodes, which hold the tokens that
+	// were skipped. Block terminators that are missing are left as INVALID
+	// tokens.
+	RecoverErrors Mode = 1 << iota
+	// AllErrors causes every error to be reported, rather than only the first
+	// error of each line.
+	AllErrors
+	// Template causes the source to be parsed as a template, in which a `$`
+	// followed by a name is a metavariable. A metavariable may appear wherever
+	// a name may appear, and is represented in the tree as a NAME token that
+	// includes the `$`. A statement consisting of only a metavariable is
+	// represented by a tree.MetaStmt.
+	Template
+)
+
+// Config configures the behavior of the parser. The zero value is a valid
+// configuration.
+type Config struct {
+	// Mode is a set of flags that control the behavior of the parser.
+	Mode Mode
+	// Dialect is the dialect of Lua to parse.
+	Dialect token.Dialect
+	// ErrorLimit is the maximum number of errors to collect. The parser stops
+	// once the limit is reached. If zero or less, there is no limit.
+	ErrorLimit int
+	// FileSet is the set in which parsed files are registered. If nil, then
+	// each file is independent.
+	FileSet *token.FileSet
+}
+
 // parser holds the parser's state while processing a source file. It must be
 // initialized with init before using.
 type parser struct {
 	file    *token.File
-	err     error //scanner.Error
+	errors  scanner.ErrorList
 	scanner scanner.Scanner
+	mode    Mode
+	dialect token.Dialect
+	limit   int // Maximum number of errors.
 
 	tokenstate // Current token state.
 
 	look *tokenstate // Store state for single-token lookaheads.
+
+	// trail holds every token that has been consumed since the start of the
+	// outermost statement. Only used when recovering from errors.
+	trail []tree.Token
 }
 
 // init prepares the parser to parse a source. The info sets the file to use for
-// positional information. The src is the text to be parsed. The mode configures
-// how the parser behaves.
-func (p *parser) init(info *token.File, src []byte) {
+// positional information. The src is the text to be parsed. The config
+// configures how the parser behaves.
+func (p *parser) init(info *token.File, src []byte, config *Config) {
 	p.file = info
-	p.scanner.Init(p.file, src, func(pos token.Position, msg string) {
-		p.err = scanner.Error{Position: pos, Message: msg}
-	})
+	p.mode = config.Mode
+	p.dialect = config.Dialect
+	p.limit = config.ErrorLimit
+	if p.mode&Template != 0 {
+		p.scanner.Mode = scanner.ScanMetaVars
+	}
+	p.scanner.InitDialect(p.file, src, p.addError, p.dialect)
 	p.next()
 }
 
+// recovering returns whether the parser is recovering from errors.
+func (p *parser) recovering() bool {
+	return p.mode&RecoverErrors != 0
+}
+
 // next advances to the next token.
 func (p *parser) next() {
 	if p.look != nil {
@@ -61,14 +113,19 @@ func (p *parser) next() {
 		p.pre = append(p.pre, tree.Prefix{Type: p.tok, Bytes: p.lit})
 		p.off, p.tok, p.lit = p.scanner.Scan()
 	}
+	if p.tok == token.METAVAR {
+		// A metavariable is otherwise treated as a name.
+		p.tok = token.NAME
+	}
 }
 
 // lookahead looks at the next token without consuming current state. The
 // lookahead state is stored in p.look, and is consumed on the next call to
 // p.next().
 //
-// This is used exactly once in the parser: within a table constructor, to tell
-// the difference between a field entry and a variable entry.
+// This is used within a table constructor, to tell the difference between a
+// field entry and a variable entry, and within types, to tell the difference
+// between similar forms that begin with a name.
 func (p *parser) lookahead() {
 	// Save current state.
 	prev := p.tokenstate
@@ -84,13 +141,36 @@ func (p *parser) lookahead() {
 // bailout is used when panicking to indicate an early termination.
 type bailout struct{}
 
-// errors stores the offset and message in p.err, then causes the parser to
-// terminate.
-func (p *parser) error(off int, msg string) {
-	p.err = scanner.Error{
-		Position: p.file.Position(off),
-		Message:  msg,
+// giveup is used when panicking to indicate that the parser must terminate
+// entirely, even when recovering from errors.
+type giveup struct{}
+
+// addError adds an error to the list of errors. Unless all errors are
+// reported, an error on the same line as the previous error is discarded. If
+// the error limit is reached, then the parser is terminated.
+func (p *parser) addError(pos token.Position, msg string) {
+	if p.mode&AllErrors == 0 {
+		if n := len(p.errors); n > 0 && p.errors[n-1].Position.Line == pos.Line {
+			return
+		}
+	}
+	p.errors.Add(pos, msg)
+	if p.limit > 0 && len(p.errors) >= p.limit {
+		panic(giveup{})
 	}
+}
+
+// report adds an error with the given offset and message to the list of
+// errors, without terminating the parser.
+func (p *parser) report(off int, msg string) {
+	p.addError(p.file.Position(off), msg)
+}
+
+// error adds an error with the given offset and message to the list of errors,
+// then causes the parser to terminate. When recovering from errors, the
+// termination is caught by the enclosing statement.
+func (p *parser) error(off int, msg string) {
+	p.report(off, msg)
 	panic(bailout{})
 }
 
@@ -103,12 +183,16 @@ func (p *parser) expect(tok token.Type) {
 
 // token creates a token node from the current state.
 func (p *parser) token() tree.Token {
-	return tree.Token{
+	tok := tree.Token{
 		Type:   p.tok,
 		Prefix: p.pre,
 		Offset: p.off,
 		Bytes:  p.lit,
 	}
+	if p.recovering() {
+		p.trail = append(p.trail, tok)
+	}
+	return tok
 }
 
 // tokenNext creates a token node from the current state, then advances to the
@@ -126,6 +210,17 @@ func (p *parser) expectToken(t token.Type) tree.Token {
 	return p.tokenNext()
 }
 
+// expectClosing is like expectToken, but is used for tokens that terminate a
+// block. When recovering from errors, a missing token is reported, and an
+// INVALID token is returned in its place without advancing.
+func (p *parser) expectClosing(t token.Type) tree.Token {
+	if p.tok != t && p.recovering() {
+		p.report(p.off, "'"+t.String()+"' expected")
+		return tree.Token{}
+	}
+	return p.expectToken(t)
+}
+
 // isBlockFollow returns whether the current state ends a block.
 func (p *parser) isBlockFollow() bool {
 	switch p.tok {
@@ -139,10 +234,63 @@ func (p *parser) isBlockFollow() bool {
 	return false
 }
 
+// isStmtStart returns whether the current state begins a statement that can be
+// identified by its first token.
+func (p *parser) isStmtStart() bool {
+	switch p.tok {
+	case token.DO,
+		token.WHILE,
+		token.REPEAT,
+		token.IF,
+		token.FOR,
+		token.FUNCTION,
+		token.LOCAL,
+		token.RETURN,
+		token.BREAK,
+		token.GOTO:
+		return true
+	case token.DBCOLON:
+		// Otherwise, begins a type assertion.
+		return p.dialect.Has(token.Goto)
+	}
+	return false
+}
+
+// isExprFollow returns whether the current state is a token that may follow an
+// expression, rather than be a part of it.
+func (p *parser) isExprFollow() bool {
+	switch p.tok {
+	case token.RPAREN,
+		token.RBRACE,
+		token.RBRACK,
+		token.COMMA,
+		token.SEMICOLON,
+		token.ASSIGN,
+		token.THEN,
+		token.DO:
+		return true
+	}
+	return p.isBlockFollow() || p.isStmtStart()
+}
+
+// isBinop returns whether the current state is a binary operator within the
+// dialect.
+func (p *parser) isBinop() bool {
+	if !p.tok.IsBinary() {
+		return false
+	}
+	if p.tok.Feature()&token.Bitwise != 0 {
+		// Type operators share tokens with bitwise operators.
+		return p.dialect.Has(token.Bitwise)
+	}
+	return true
+}
+
 // parseNumber creates a number node from the current state.
 func (p *parser) parseNumber() (num *tree.NumberExpr) {
 	switch p.tok {
-	case token.NUMBERFLOAT, token.NUMBERHEX:
+	case token.NUMBERFLOAT, token.NUMBERHEX, token.NUMBERBIN,
+		token.NUMBERI64, token.NUMBERU64, token.NUMBERIMAG:
 		num = &tree.NumberExpr{NumberToken: p.token()}
 	default:
 		p.error(p.off, "'"+token.NUMBERFLOAT.String()+"' expected")
@@ -166,7 +314,8 @@ func (p *parser) parseString() (str *tree.StringExpr) {
 // parseSimpleExpr creates a simple expression node from the current state.
 func (p *parser) parseSimpleExpr() (expr tree.Expr) {
 	switch p.tok {
-	case token.NUMBERFLOAT, token.NUMBERHEX:
+	case token.NUMBERFLOAT, token.NUMBERHEX, token.NUMBERBIN,
+		token.NUMBERI64, token.NUMBERU64, token.NUMBERIMAG:
 		expr = p.parseNumber()
 	case token.STRING, token.LONGSTRING:
 		expr = p.parseString()
@@ -180,9 +329,62 @@ func (p *parser) parseSimpleExpr() (expr tree.Expr) {
 		expr = p.parseTableCtor()
 	case token.FUNCTION:
 		expr, _ = p.parseFunction(funcExpr)
+	case token.INTERPSTRING, token.INTERPBEGIN:
+		expr = p.parseInterpExpr()
+	case token.IF:
+		if !p.dialect.Has(token.IfExpr) {
+			expr = p.parsePrimaryExpr()
+			break
+		}
+		expr = p.parseIfExpr()
 	default:
 		expr = p.parsePrimaryExpr()
 	}
+	if p.tok == token.DBCOLON && p.dialect.Has(token.Types) {
+		e := &tree.AssertExpr{Value: expr}
+		e.AssertToken = p.tokenNext()
+		e.Type = p.parseType()
+		expr = e
+	}
+	return expr
+}
+
+// parseIfExpr creates an `if` expression node.
+func (p *parser) parseIfExpr() tree.Expr {
+	expr := &tree.IfExpr{}
+	expr.IfToken = p.expectToken(token.IF)
+	expr.Cond = p.parseExpr()
+	expr.ThenToken = p.expectToken(token.THEN)
+	expr.Value = p.parseExpr()
+	for p.tok == token.ELSEIF {
+		clause := tree.ElseIfExprClause{}
+		clause.ElseIfToken = p.expectToken(token.ELSEIF)
+		clause.Cond = p.parseExpr()
+		clause.ThenToken = p.expectToken(token.THEN)
+		clause.Value = p.parseExpr()
+		expr.ElseIf = append(expr.ElseIf, clause)
+	}
+	expr.ElseToken = p.expectToken(token.ELSE)
+	expr.Else = p.parseExpr()
+	return expr
+}
+
+// parseInterpExpr creates an interpolated string node.
+func (p *parser) parseInterpExpr() tree.Expr {
+	expr := &tree.InterpExpr{}
+	if p.tok == token.INTERPSTRING {
+		expr.Segments = append(expr.Segments, p.tokenNext())
+		return expr
+	}
+	expr.Segments = append(expr.Segments, p.expectToken(token.INTERPBEGIN))
+	for {
+		expr.Exprs = append(expr.Exprs, p.parseExpr())
+		if p.tok != token.INTERPMID {
+			break
+		}
+		expr.Segments = append(expr.Segments, p.tokenNext())
+	}
+	expr.Segments = append(expr.Segments, p.expectToken(token.INTERPEND))
 	return expr
 }
 
@@ -200,7 +402,7 @@ func (p *parser) parseSubexpr(limit int) (expr tree.Expr) {
 		}
 	}
 
-	for p.tok.IsBinary() && p.tok.Precedence()[0] > limit {
+	for p.isBinop() && p.tok.Precedence()[0] > limit {
 		binopToken := p.tokenNext()
 		expr = &tree.BinopExpr{
 			Left:       expr,
@@ -227,21 +429,251 @@ func (p *parser) parseExprList() *tree.ExprList {
 	return list
 }
 
-// parseBlockBody creates a block terminated by a specified token.
-func (p *parser) parseBlockBody(term token.Type) tree.Block {
-	block := p.parseBlock()
-	if p.tok != term {
-		p.error(p.off, term.String()+" expected")
+// parseTypeAnnot creates a type annotation node if the dialect has types and
+// the current state begins an annotation. Returns nil otherwise.
+func (p *parser) parseTypeAnnot() *tree.TypeAnnot {
+	if p.tok != token.COLON || !p.dialect.Has(token.Types) {
+		return nil
+	}
+	annot := &tree.TypeAnnot{}
+	annot.ColonToken = p.tokenNext()
+	annot.Type = p.parseType()
+	return annot
+}
+
+// parseType creates a type node, including union and intersection types.
+func (p *parser) parseType() tree.Type {
+	var lead tree.Token
+	if p.tok == token.PIPE || p.tok == token.AMPERSAND {
+		lead = p.tokenNext()
+	}
+	typ := p.parseOptionalType()
+	op := lead.Type
+	if !op.IsValid() {
+		op = p.tok
+	}
+	switch op {
+	case token.PIPE:
+		u := &tree.UnionType{LeadToken: lead, Items: []tree.Type{typ}}
+		for p.tok == token.PIPE {
+			u.Seps = append(u.Seps, p.tokenNext())
+			u.Items = append(u.Items, p.parseOptionalType())
+		}
+		return u
+	case token.AMPERSAND:
+		t := &tree.IntersectionType{LeadToken: lead, Items: []tree.Type{typ}}
+		for p.tok == token.AMPERSAND {
+			t.Seps = append(t.Seps, p.tokenNext())
+			t.Items = append(t.Items, p.parseOptionalType())
+		}
+		return t
+	}
+	return typ
+}
+
+// parseOptionalType creates a type node, followed by any number of optional
+// markers.
+func (p *parser) parseOptionalType() tree.Type {
+	typ := p.parseSimpleType()
+	for p.tok == token.QUESTION {
+		typ = &tree.OptionalType{Value: typ, QuestionToken: p.tokenNext()}
+	}
+	return typ
+}
+
+// parseSimpleType creates a type node that is not composed with an operator.
+func (p *parser) parseSimpleType() tree.Type {
+	switch p.tok {
+	case token.NIL, token.TRUE, token.FALSE, token.STRING, token.LONGSTRING:
+		return &tree.LiteralType{LiteralToken: p.tokenNext()}
+	case token.NAME:
+		p.lookahead()
+		switch p.look.tok {
+		case token.LPAREN:
+			if string(p.lit) == "typeof" {
+				typ := &tree.TypeofType{}
+				typ.TypeofToken = p.tokenNext()
+				typ.LParenToken = p.expectToken(token.LPAREN)
+				typ.Value = p.parseExpr()
+				typ.RParenToken = p.expectToken(token.RPAREN)
+				return typ
+			}
+		case token.VARARG:
+			typ := &tree.GenericPackType{}
+			typ.NameToken = p.tokenNext()
+			typ.VarArgToken = p.tokenNext()
+			return typ
+		}
+		return p.parseNamedType()
+	case token.LBRACE:
+		return p.parseTableType()
+	case token.LPAREN, token.LT:
+		return p.parseFunctionType()
+	case token.VARARG:
+		typ := &tree.VariadicType{}
+		typ.VarArgToken = p.tokenNext()
+		typ.Value = p.parseOptionalType()
+		return typ
+	}
+	p.error(p.off, "type expected")
+	return nil
+}
+
+// parseNamedType creates a named type node.
+func (p *parser) parseNamedType() tree.Type {
+	typ := &tree.NamedType{}
+	typ.NameToken = p.expectToken(token.NAME)
+	if p.tok == token.DOT {
+		typ.ModuleToken = typ.NameToken
+		typ.DotToken = p.tokenNext()
+		typ.NameToken = p.expectToken(token.NAME)
+	}
+	if p.tok == token.LT {
+		typ.Params = &tree.TypeParams{}
+		typ.Params.LAngleToken = p.tokenNext()
+		if p.tok != token.GT {
+			typ.Params.Types = p.parseTypeList()
+		}
+		typ.Params.RAngleToken = p.expectToken(token.GT)
+	}
+	return typ
+}
+
+// parseTypeList creates a list of types.
+func (p *parser) parseTypeList() *tree.TypeList {
+	list := &tree.TypeList{Items: []tree.Type{p.parseType()}}
+	for p.tok == token.COMMA {
+		list.Seps = append(list.Seps, p.tokenNext())
+		list.Items = append(list.Items, p.parseType())
+	}
+	return list
+}
+
+// parseGenericList creates a list of generic type parameters. If defaults is
+// true, then parameters may have default types.
+func (p *parser) parseGenericList(defaults bool) *tree.GenericList {
+	list := &tree.GenericList{}
+	list.LAngleToken = p.expectToken(token.LT)
+	for {
+		param := tree.GenericParam{}
+		param.NameToken = p.expectToken(token.NAME)
+		if p.tok == token.VARARG {
+			param.VarArgToken = p.tokenNext()
+		}
+		if defaults && p.tok == token.ASSIGN {
+			param.AssignToken = p.tokenNext()
+			param.Default = p.parseType()
+		}
+		list.Items = append(list.Items, param)
+		if p.tok != token.COMMA {
+			break
+		}
+		list.Seps = append(list.Seps, p.tokenNext())
+	}
+	list.RAngleToken = p.expectToken(token.GT)
+	return list
+}
+
+// parseFunctionType creates a node for a type that begins with parentheses,
+// which is either a function type, a parenthesized type, or a type pack.
+func (p *parser) parseFunctionType() tree.Type {
+	var generics *tree.GenericList
+	if p.tok == token.LT {
+		generics = p.parseGenericList(false)
+	}
+	lparen := p.expectToken(token.LPAREN)
+	var params *tree.TypeList
+	named := false
+	if p.tok != token.RPAREN {
+		params = &tree.TypeList{}
+		for {
+			var item tree.Type
+			if p.tok == token.NAME {
+				if p.lookahead(); p.look.tok == token.COLON {
+					param := &tree.ParamType{}
+					param.NameToken = p.tokenNext()
+					param.ColonToken = p.tokenNext()
+					param.Type = p.parseType()
+					item = param
+					named = true
+				}
+			}
+			if item == nil {
+				item = p.parseType()
+			}
+			params.Items = append(params.Items, item)
+			if p.tok != token.COMMA {
+				break
+			}
+			params.Seps = append(params.Seps, p.tokenNext())
+		}
+	}
+	rparen := p.expectToken(token.RPAREN)
+
+	if p.tok == token.ARROW || generics != nil || named {
+		typ := &tree.FunctionType{}
+		typ.Generics = generics
+		typ.LParenToken = lparen
+		typ.Params = params
+		typ.RParenToken = rparen
+		typ.ArrowToken = p.expectToken(token.ARROW)
+		typ.Return = p.parseType()
+		return typ
+	}
+	if params != nil && len(params.Items) == 1 {
+		switch params.Items[0].(type) {
+		case *tree.VariadicType, *tree.GenericPackType:
+		default:
+			return &tree.ParenType{LParenToken: lparen, Value: params.Items[0], RParenToken: rparen}
+		}
+	}
+	return &tree.PackType{LParenToken: lparen, Types: params, RParenToken: rparen}
+}
+
+// parseTableType creates a table type node.
+func (p *parser) parseTableType() tree.Type {
+	typ := &tree.TableType{}
+	typ.LBraceToken = p.expectToken(token.LBRACE)
+	for p.tok != token.RBRACE {
+		var entry tree.TypeEntry
+		switch p.tok {
+		case token.LBRACK:
+			e := &tree.TypeIndexEntry{}
+			e.LBrackToken = p.tokenNext()
+			e.Key = p.parseType()
+			e.RBrackToken = p.expectToken(token.RBRACK)
+			e.ColonToken = p.expectToken(token.COLON)
+			e.Value = p.parseType()
+			entry = e
+		case token.NAME:
+			if p.lookahead(); p.look.tok == token.COLON {
+				e := &tree.TypeFieldEntry{}
+				e.NameToken = p.tokenNext()
+				e.ColonToken = p.tokenNext()
+				e.Value = p.parseType()
+				entry = e
+				break
+			}
+			fallthrough
+		default:
+			entry = &tree.TypeValueEntry{Value: p.parseType()}
+		}
+		typ.Entries.Items = append(typ.Entries.Items, entry)
+		if p.tok != token.COMMA && p.tok != token.SEMICOLON {
+			break
+		}
+		typ.Entries.Seps = append(typ.Entries.Seps, p.tokenNext())
 	}
-	return block
+	typ.RBraceToken = p.expectToken(token.RBRACE)
+	return typ
 }
 
 // parseDoStmt creates a `do` statement node.
 func (p *parser) parseDoStmt() tree.Stmt {
 	stmt := &tree.DoStmt{}
 	stmt.DoToken = p.expectToken(token.DO)
-	stmt.Body = p.parseBlockBody(token.END)
-	stmt.EndToken = p.expectToken(token.END)
+	stmt.Body = p.parseBlock(token.END)
+	stmt.EndToken = p.expectClosing(token.END)
 	return stmt
 }
 
@@ -251,8 +683,8 @@ func (p *parser) parseWhileStmt() tree.Stmt {
 	stmt.WhileToken = p.expectToken(token.WHILE)
 	stmt.Cond = p.parseExpr()
 	stmt.DoToken = p.expectToken(token.DO)
-	stmt.Body = p.parseBlockBody(token.END)
-	stmt.EndToken = p.expectToken(token.END)
+	stmt.Body = p.parseBlock(token.END)
+	stmt.EndToken = p.expectClosing(token.END)
 	return stmt
 }
 
@@ -260,8 +692,13 @@ func (p *parser) parseWhileStmt() tree.Stmt {
 func (p *parser) parseRepeatStmt() tree.Stmt {
 	stmt := &tree.RepeatStmt{}
 	stmt.RepeatToken = p.expectToken(token.REPEAT)
-	stmt.Body = p.parseBlockBody(token.UNTIL)
-	stmt.UntilToken = p.expectToken(token.UNTIL)
+	stmt.Body = p.parseBlock(token.UNTIL)
+	stmt.UntilToken = p.expectClosing(token.UNTIL)
+	if !stmt.UntilToken.Type.IsValid() {
+		// Already reported missing UNTIL.
+		stmt.Cond = &tree.BadExpr{}
+		return stmt
+	}
 	stmt.Cond = p.parseExpr()
 	return stmt
 }
@@ -272,21 +709,21 @@ func (p *parser) parseIfStmt() tree.Stmt {
 	stmt.IfToken = p.expectToken(token.IF)
 	stmt.Cond = p.parseExpr()
 	stmt.ThenToken = p.expectToken(token.THEN)
-	stmt.Body = p.parseBlock()
+	stmt.Body = p.parseBlock(token.END)
 	for p.tok == token.ELSEIF {
 		clause := tree.ElseIfClause{}
 		clause.ElseIfToken = p.expectToken(token.ELSEIF)
 		clause.Cond = p.parseExpr()
 		clause.ThenToken = p.expectToken(token.THEN)
-		clause.Body = p.parseBlock()
+		clause.Body = p.parseBlock(token.END)
 		stmt.ElseIf = append(stmt.ElseIf, clause)
 	}
 	if p.tok == token.ELSE {
 		stmt.Else = &tree.ElseClause{}
 		stmt.Else.ElseToken = p.expectToken(token.ELSE)
-		stmt.Else.Body = p.parseBlock()
+		stmt.Else.Body = p.parseBlock(token.END)
 	}
-	stmt.EndToken = p.expectToken(token.END)
+	stmt.EndToken = p.expectClosing(token.END)
 	return stmt
 }
 
@@ -294,11 +731,13 @@ func (p *parser) parseIfStmt() tree.Stmt {
 func (p *parser) parseForStmt() (stmt tree.Stmt) {
 	forToken := p.expectToken(token.FOR)
 	name := p.expectToken(token.NAME)
+	nameType := p.parseTypeAnnot()
 	switch p.tok {
 	case token.ASSIGN:
 		st := &tree.NumericForStmt{}
 		st.ForToken = forToken
 		st.NameToken = name
+		st.NameType = nameType
 		st.AssignToken = p.expectToken(token.ASSIGN)
 		st.Min = p.parseExpr()
 		st.MaxSepToken = p.expectToken(token.COMMA)
@@ -308,22 +747,27 @@ func (p *parser) parseForStmt() (stmt tree.Stmt) {
 			st.Step = p.parseExpr()
 		}
 		st.DoToken = p.expectToken(token.DO)
-		st.Body = p.parseBlockBody(token.END)
-		st.EndToken = p.expectToken(token.END)
+		st.Body = p.parseBlock(token.END)
+		st.EndToken = p.expectClosing(token.END)
 		stmt = st
 	case token.COMMA, token.IN:
 		st := &tree.GenericForStmt{}
 		st.ForToken = forToken
 		st.Names.Items = append(st.Names.Items, name)
+		if p.dialect.Has(token.Types) {
+			st.Names.Types = append(st.Names.Types, nameType)
+		}
 		for p.tok == token.COMMA {
 			st.Names.Seps = append(st.Names.Seps, p.tokenNext())
 			st.Names.Items = append(st.Names.Items, p.expectToken(token.NAME))
+			p.parseNameType(&st.Names)
 		}
+		trimTypes(&st.Names)
 		st.InToken = p.expectToken(token.IN)
 		st.Iterator = *p.parseExprList()
 		st.DoToken = p.expectToken(token.DO)
-		st.Body = p.parseBlockBody(token.END)
-		st.EndToken = p.expectToken(token.END)
+		st.Body = p.parseBlock(token.END)
+		st.EndToken = p.expectClosing(token.END)
 		stmt = st
 	default:
 		p.error(p.off, "'=' or 'in' expected")
@@ -355,9 +799,13 @@ func (p *parser) parseFunction(typ uint8) (expr *tree.FunctionExpr, names tree.F
 			}
 		}
 	}
+	if p.tok == token.LT && p.dialect.Has(token.Types) {
+		expr.Generics = p.parseGenericList(false)
+	}
 	expr.LParenToken = p.expectToken(token.LPAREN)
 	if p.tok == token.NAME {
 		expr.Params = &tree.NameList{Items: []tree.Token{p.expectToken(token.NAME)}}
+		p.parseNameType(expr.Params)
 		for p.tok == token.COMMA {
 			sepToken := p.tokenNext()
 			if p.tok == token.VARARG {
@@ -367,13 +815,19 @@ func (p *parser) parseFunction(typ uint8) (expr *tree.FunctionExpr, names tree.F
 			}
 			expr.Params.Seps = append(expr.Params.Seps, sepToken)
 			expr.Params.Items = append(expr.Params.Items, p.expectToken(token.NAME))
+			p.parseNameType(expr.Params)
 		}
+		trimTypes(expr.Params)
 	} else if p.tok == token.VARARG {
 		expr.VarArgToken = p.tokenNext()
 	}
+	if expr.VarArgToken.Type.IsValid() {
+		expr.VarArgType = p.parseTypeAnnot()
+	}
 	expr.RParenToken = p.expectToken(token.RPAREN)
-	expr.Body = p.parseBlockBody(token.END)
-	expr.EndToken = p.expectToken(token.END)
+	expr.ReturnType = p.parseTypeAnnot()
+	expr.Body = p.parseBlock(token.END)
+	expr.EndToken = p.expectClosing(token.END)
 	return expr, names
 }
 
@@ -391,10 +845,16 @@ func (p *parser) parseLocalStmt() tree.Stmt {
 	stmt := &tree.LocalVarStmt{}
 	stmt.LocalToken = localToken
 	stmt.Names.Items = append(stmt.Names.Items, p.expectToken(token.NAME))
+	p.parseAttrib(&stmt.Names)
+	p.parseNameType(&stmt.Names)
 	for p.tok == token.COMMA {
 		stmt.Names.Seps = append(stmt.Names.Seps, p.tokenNext())
 		stmt.Names.Items = append(stmt.Names.Items, p.expectToken(token.NAME))
+		p.parseAttrib(&stmt.Names)
+		p.parseNameType(&stmt.Names)
 	}
+	p.checkAttribs(&stmt.Names)
+	trimTypes(&stmt.Names)
 	if p.tok == token.ASSIGN {
 		stmt.AssignToken = p.tokenNext()
 		stmt.Values = p.parseExprList()
@@ -402,6 +862,68 @@ func (p *parser) parseLocalStmt() tree.Stmt {
 	return stmt
 }
 
+// parseAttrib creates an attribute node for the last name in a list, if the
+// dialect has attributes. A nil attribute is added when none is present.
+func (p *parser) parseAttrib(list *tree.NameList) {
+	if !p.dialect.Has(token.Attribs) {
+		return
+	}
+	var attrib *tree.Attrib
+	if p.tok == token.LT {
+		attrib = &tree.Attrib{}
+		attrib.LAngleToken = p.tokenNext()
+		attrib.NameToken = p.expectToken(token.NAME)
+		attrib.RAngleToken = p.expectToken(token.GT)
+	}
+	list.Attribs = append(list.Attribs, attrib)
+}
+
+// checkAttribs verifies the attributes of a list of names. Attributes are
+// removed from the list if none are present.
+func (p *parser) checkAttribs(list *tree.NameList) {
+	present := false
+	closed := false
+	for _, attrib := range list.Attribs {
+		if attrib == nil {
+			continue
+		}
+		present = true
+		switch name := string(attrib.NameToken.Bytes); name {
+		case "const":
+		case "close":
+			if closed {
+				p.report(attrib.NameToken.Offset, "multiple to-be-closed variables in local list")
+			}
+			closed = true
+		default:
+			p.report(attrib.NameToken.Offset, "unknown attribute '"+name+"'")
+		}
+	}
+	if !present {
+		list.Attribs = nil
+	}
+}
+
+// parseNameType creates a type annotation node for the last name in a list, if
+// the dialect has types. A nil annotation is added when none is present.
+func (p *parser) parseNameType(list *tree.NameList) {
+	if !p.dialect.Has(token.Types) {
+		return
+	}
+	list.Types = append(list.Types, p.parseTypeAnnot())
+}
+
+// trimTypes removes the type annotations from a list of names if none are
+// present.
+func trimTypes(list *tree.NameList) {
+	for _, typ := range list.Types {
+		if typ != nil {
+			return
+		}
+	}
+	list.Types = nil
+}
+
 // parseFunctionStmt creates a `function` statement node.
 func (p *parser) parseFunctionStmt() tree.Stmt {
 	expr, names := p.parseFunction(funcStmt)
@@ -429,6 +951,83 @@ func (p *parser) parseBreakStmt() tree.Stmt {
 	return stmt
 }
 
+// parseGotoStmt creates a `goto` statement node.
+func (p *parser) parseGotoStmt() tree.Stmt {
+	stmt := &tree.GotoStmt{}
+	stmt.GotoToken = p.expectToken(token.GOTO)
+	stmt.NameToken = p.expectToken(token.NAME)
+	return stmt
+}
+
+// parseLabelStmt creates a label statement node.
+func (p *parser) parseLabelStmt() tree.Stmt {
+	stmt := &tree.LabelStmt{}
+	stmt.LColonToken = p.expectToken(token.DBCOLON)
+	stmt.NameToken = p.expectToken(token.NAME)
+	stmt.RColonToken = p.expectToken(token.DBCOLON)
+	return stmt
+}
+
+// parseContinueStmt creates a `continue` statement node from the name
+// that begins the statement.
+func (p *parser) parseContinueStmt(name tree.Token) tree.Stmt {
+	return &tree.ContinueStmt{ContinueToken: name}
+}
+
+// parseTypeStmt creates a `type` statement node. The export argument is the
+// `export` name preceding the statement, or INVALID, and typ is the `type`
+// name that begins the statement.
+func (p *parser) parseTypeStmt(export, typ tree.Token) tree.Stmt {
+	stmt := &tree.TypeStmt{}
+	stmt.ExportToken = export
+	stmt.TypeToken = typ
+	stmt.NameToken = p.expectToken(token.NAME)
+	if p.tok == token.LT {
+		stmt.Generics = p.parseGenericList(true)
+	}
+	stmt.AssignToken = p.expectToken(token.ASSIGN)
+	stmt.Type = p.parseType()
+	return stmt
+}
+
+// parseContextualStmt creates a statement node that begins with a word that
+// is not reserved, which has already been parsed as expr. Returns nil if expr
+// does not begin such a statement.
+func (p *parser) parseContextualStmt(expr tree.Expr) tree.Stmt {
+	v, ok := expr.(*tree.VariableExpr)
+	if !ok {
+		return nil
+	}
+	switch string(v.NameToken.Bytes) {
+	case "continue":
+		if p.dialect.Has(token.Continue) {
+			return p.parseContinueStmt(v.NameToken)
+		}
+	case "type":
+		if p.dialect.Has(token.Types) && p.tok == token.NAME {
+			return p.parseTypeStmt(tree.Token{}, v.NameToken)
+		}
+	case "export":
+		if p.dialect.Has(token.Types) && p.tok == token.NAME && string(p.lit) == "type" {
+			return p.parseTypeStmt(v.NameToken, p.tokenNext())
+		}
+	}
+	return nil
+}
+
+// parseMetaVarStmt creates a MetaStmt if expr is a lone metavariable within a
+// template. Returns nil otherwise.
+func (p *parser) parseMetaVarStmt(expr tree.Expr) tree.Stmt {
+	if p.mode&Template == 0 {
+		return nil
+	}
+	v, ok := expr.(*tree.VariableExpr)
+	if !ok || !bytes.HasPrefix(v.NameToken.Bytes, []byte("$")) {
+		return nil
+	}
+	return &tree.MetaStmt{NameToken: v.NameToken}
+}
+
 // parsePrefixExpr creates an expression node that begins a primary expression.
 func (p *parser) parsePrefixExpr() (expr tree.Expr) {
 	switch p.tok {
@@ -443,6 +1042,18 @@ func (p *parser) parsePrefixExpr() (expr tree.Expr) {
 		e.NameToken = p.expectToken(token.NAME)
 		expr = e
 	default:
+		if p.recovering() {
+			if p.tok != token.INVALID {
+				// INVALID tokens have already been reported by the scanner.
+				p.report(p.off, "unexpected symbol")
+			}
+			e := &tree.BadExpr{}
+			if !p.isExprFollow() {
+				// Skip the offending token.
+				e.Tokens = append(e.Tokens, p.tokenNext())
+			}
+			return e
+		}
 		p.error(p.off, "unexpected symbol")
 	}
 	return expr
@@ -565,9 +1176,28 @@ loop:
 // parseExprStmt creates an expression statement node.
 func (p *parser) parseExprStmt() tree.Stmt {
 	expr := p.parsePrimaryExpr()
+	if _, ok := expr.(*tree.BadExpr); ok {
+		// The error has already been reported; let the statement be skipped
+		// instead of reporting a missing assignment.
+		panic(bailout{})
+	}
 	if call, ok := expr.(tree.Call); ok {
 		return &tree.CallStmt{Call: call}
 	}
+	if p.tok.IsCompoundAssign() {
+		stmt := &tree.CompoundAssignStmt{Left: expr}
+		stmt.OpToken = p.tokenNext()
+		stmt.Right = p.parseExpr()
+		return stmt
+	}
+	if p.tok != token.COMMA && p.tok != token.ASSIGN {
+		if stmt := p.parseMetaVarStmt(expr); stmt != nil {
+			return stmt
+		}
+		if stmt := p.parseContextualStmt(expr); stmt != nil {
+			return stmt
+		}
+	}
 
 	stmt := &tree.AssignStmt{Left: tree.ExprList{Items: []tree.Expr{expr}}}
 	for p.tok == token.COMMA {
@@ -605,16 +1235,71 @@ func (p *parser) parseStmt() (stmt tree.Stmt, last bool) {
 	case token.RETURN:
 		return p.parseReturnStmt(), true
 	case token.BREAK:
-		return p.parseBreakStmt(), true
+		return p.parseBreakStmt(), !p.dialect.Has(token.LooseBreak)
+	case token.GOTO:
+		return p.parseGotoStmt(), false
+	case token.DBCOLON:
+		if p.dialect.Has(token.Goto) {
+			return p.parseLabelStmt(), false
+		}
 	}
-	return p.parseExprStmt(), false
+	stmt = p.parseExprStmt()
+	_, last = stmt.(*tree.ContinueStmt)
+	return stmt, last
 }
 
-// parseBlock creates a block node.
-func (p *parser) parseBlock() (block tree.Block) {
-	for last := false; !last && !p.isBlockFollow(); {
+// parseStmtOrBad creates a statement node. When recovering from errors, a
+// statement that could not be parsed is replaced with a BadStmt.
+func (p *parser) parseStmtOrBad() (stmt tree.Stmt, last bool) {
+	if !p.recovering() {
+		return p.parseStmt()
+	}
+	mark := len(p.trail)
+	defer func() {
+		if e := recover(); e != nil {
+			if _, ok := e.(bailout); !ok {
+				panic(e)
+			}
+			stmt, last = p.parseBadStmt(mark), false
+		}
+		if mark == 0 {
+			// Outermost statement; consumed tokens are no longer needed.
+			p.trail = p.trail[:0]
+		}
+	}()
+	return p.parseStmt()
+}
+
+// parseBadStmt creates a BadStmt node from each token consumed since mark,
+// then skips tokens until the start of the next statement or the end of the
+// block.
+func (p *parser) parseBadStmt(mark int) *tree.BadStmt {
+	for p.tok != token.EOF {
+		if len(p.trail) > mark && (p.tok == token.SEMICOLON || p.isStmtStart() || p.isBlockFollow()) {
+			break
+		}
+		// Skip at least one token to ensure progress.
+		p.tokenNext()
+	}
+	stmt := &tree.BadStmt{Tokens: make([]tree.Token, len(p.trail)-mark)}
+	copy(stmt.Tokens, p.trail[mark:])
+	return stmt
+}
+
+// parseBlock creates a block node. The term argument is the token expected to
+// terminate the block.
+func (p *parser) parseBlock(term token.Type) (block tree.Block) {
+	for last := false; !p.isBlockFollow(); {
+		if last {
+			if !p.recovering() {
+				break
+			}
+			// Report statements that follow the last statement, but continue
+			// parsing them.
+			p.report(p.off, "'"+term.String()+"' expected")
+		}
 		var stmt tree.Stmt
-		stmt, last = p.parseStmt()
+		stmt, last = p.parseStmtOrBad()
 		block.Items = append(block.Items, stmt)
 		var semi tree.Token
 		if p.tok == token.SEMICOLON {
@@ -627,11 +1312,20 @@ func (p *parser) parseBlock() (block tree.Block) {
 
 // parseFile creates a file node from the current source.
 func (p *parser) parseFile() *tree.File {
-	return &tree.File{
-		Info:     p.file,
-		Body:     p.parseBlock(),
-		EOFToken: p.tokenNext(),
+	file := &tree.File{Info: p.file, Dialect: p.dialect}
+	file.Body = p.parseBlock(token.EOF)
+	for p.recovering() && p.tok != token.EOF {
+		// Skip block terminators that do not close anything.
+		p.report(p.off, "'"+token.EOF.String()+"' expected")
+		file.Body.Items = append(file.Body.Items, p.parseBadStmt(len(p.trail)))
+		file.Body.Seps = append(file.Body.Seps, tree.Token{})
+		p.trail = p.trail[:0]
+		body := p.parseBlock(token.EOF)
+		file.Body.Items = append(file.Body.Items, body.Items...)
+		file.Body.Seps = append(file.Body.Seps, body.Seps...)
 	}
+	file.EOFToken = p.expectToken(token.EOF)
+	return file
 }
 
 // readSource retrieves the bytes from several types of values.
@@ -665,29 +1359,125 @@ func readSource(filename string, src interface{}) ([]byte, error) {
 // The src argument may be a string, []byte, *bytes.Buffer, or io.Reader. In
 // these cases, the filename is used only when recording positional information.
 // If src is nil, the source is read from the file specified by filename.
+//
+// The source is parsed as Lua 5.1, and parsing stops at the first syntax
+// error, which is returned as a scanner.Error. Config.ParseFile may be used to
+// parse another dialect, to recover from errors, or to collect multiple errors.
 func ParseFile(filename string, src interface{}) (f *tree.File, err error) {
+	var config Config
+	f, err = config.ParseFile(filename, src)
+	return f, firstError(err)
+}
+
+// firstError returns the first error of err if it is a scanner.ErrorList.
+// Otherwise, err is returned.
+func firstError(err error) error {
+	if list, ok := err.(scanner.ErrorList); ok && len(list) > 0 {
+		return *list[0]
+	}
+	return err
+}
+
+// ParseFile is like the ParseFile function, but uses the configuration of c.
+// If errors occurred, then err will be a scanner.ErrorList, sorted by
+// position. When recovering from errors, f is a complete tree in which the
+// erroneous portions of the source are represented by placeholder nodes.
+func (c *Config) ParseFile(filename string, src interface{}) (f *tree.File, err error) {
+	info, err := c.parse(filename, src, func(p *parser) {
+		f = p.parseFile()
+	})
+	if f == nil && info != nil {
+		f = &tree.File{Info: info, Dialect: c.Dialect}
+	}
+	return f, err
+}
+
+// ParseExpr parses the source code of a single Lua expression. The arguments
+// and errors are the same as for ParseFile. Spacing and comments following
+// the expression are discarded.
+func ParseExpr(filename string, src interface{}) (expr tree.Expr, err error) {
+	var config Config
+	expr, err = config.ParseExpr(filename, src)
+	return expr, firstError(err)
+}
+
+// ParseExpr is like the ParseExpr function, but uses the configuration of c.
+func (c *Config) ParseExpr(filename string, src interface{}) (expr tree.Expr, err error) {
+	_, err = c.parse(filename, src, func(p *parser) {
+		expr = p.parseExpr()
+		p.expect(token.EOF)
+	})
+	return expr, err
+}
+
+// ParseStmt parses the source code of a single Lua statement, which may be
+// followed by a semicolon. The arguments and errors are the same as for
+// ParseFile. Spacing and comments following the statement are discarded.
+func ParseStmt(filename string, src interface{}) (stmt tree.Stmt, err error) {
+	var config Config
+	stmt, err = config.ParseStmt(filename, src)
+	return stmt, firstError(err)
+}
+
+// ParseStmt is like the ParseStmt function, but uses the configuration of c.
+func (c *Config) ParseStmt(filename string, src interface{}) (stmt tree.Stmt, err error) {
+	_, err = c.parse(filename, src, func(p *parser) {
+		stmt, _ = p.parseStmtOrBad()
+		if p.tok == token.SEMICOLON {
+			p.next()
+		}
+		p.expect(token.EOF)
+	})
+	return stmt, err
+}
+
+// ParseBlock parses the source code of a Lua block. The arguments and errors
+// are the same as for ParseFile. Unlike ParseFile, the result excludes the EOF
+// token, along with any spacing and comments that follow the last statement.
+func ParseBlock(filename string, src interface{}) (block *tree.Block, err error) {
+	var config Config
+	block, err = config.ParseBlock(filename, src)
+	return block, firstError(err)
+}
+
+// ParseBlock is like the ParseBlock function, but uses the configuration of c.
+func (c *Config) ParseBlock(filename string, src interface{}) (block *tree.Block, err error) {
+	_, err = c.parse(filename, src, func(p *parser) {
+		block = &p.parseFile().Body
+	})
+	return block, err
+}
+
+// parse reads the source, then calls fn with a parser initialized with the
+// source. Returns the file information of the source, and the errors that
+// occurred while parsing. The info is nil if the source could not be read.
+func (c *Config) parse(filename string, src interface{}, fn func(p *parser)) (info *token.File, err error) {
 	text, err := readSource(filename, src)
 	if err != nil {
 		return nil, err
 	}
 
-	info := token.NewFile(filename)
+	if c.FileSet != nil {
+		info = c.FileSet.AddFile(filename, -1, len(text))
+	} else {
+		info = token.NewFile(filename)
+	}
+	info.SetContent(text)
 	var p parser
 	defer func() {
 		if e := recover(); e != nil {
-			if _, ok := e.(bailout); !ok {
+			switch e.(type) {
+			case bailout, giveup:
+			default:
 				panic(e)
 			}
 		}
 
-		if f == nil {
-			f = &tree.File{Info: info}
-		}
-
-		err = p.err
+		p.errors.Sort()
+		err = p.errors.Err()
 	}()
 
-	p.init(info, text)
-	f = p.parseFile()
-	return f, err
+	p.init(info, text, c)
+	fn(&p)
+	return info, err
 }
@@ -0,0 +1,148 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/anaminus/luasyntax/go/scanner"
+	"github.com/anaminus/luasyntax/go/tree"
+	"testing"
+)
+
+// errorStrings returns each error of err formatted as "line:column: message".
+func errorStrings(err error) []string {
+	list, _ := err.(scanner.ErrorList)
+	s := make([]string, len(list))
+	for i, e := range list {
+		s[i] = fmt.Sprintf("%d:%d: %s", e.Position.Line, e.Position.Column, e.Message)
+	}
+	return s
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+var recoverTests = []struct {
+	src  string
+	errs []string
+}{
+	{"local x = 1\nprint(x)\n", []string{}},
+	{"local x = 1\nfunction f()\n  local y = 2\n", []string{"4:1: 'end' expected"}},
+	{"if x then\n y(\nend\nprint(1)", []string{"3:1: unexpected symbol"}},
+	{"x = @ + 1\nprint(2)", []string{"1:5: unexpected symbol"}},
+	{"do end until x\nend\nfoo()", []string{"1:8: '<eof>' expected", "2:1: '<eof>' expected"}},
+	{"return 1 x()", []string{"1:10: '<eof>' expected"}},
+	{"local t = {a = 1, b = }\nprint(t)", []string{"1:23: unexpected symbol"}},
+	{"local s = [[abc", []string{"1:11: unfinished long string near '<eof>'"}},
+	{"f(a b)", []string{"1:5: ')' expected"}},
+	{"for i = 1 do end", []string{"1:11: ',' expected"}},
+	{"x = 'abc\ny = 2", []string{"1:5: unfinished string (EOL)"}},
+}
+
+func TestRecoverErrors(t *testing.T) {
+	config := Config{Mode: RecoverErrors}
+	for _, test := range recoverTests {
+		f, err := config.ParseFile("", test.src)
+		if f == nil {
+			t.Errorf("%q: expected tree", test.src)
+			continue
+		}
+		if errs := errorStrings(err); !equalStrings(errs, test.errs) {
+			t.Errorf("%q: expected errors %q, got %q", test.src, test.errs, errs)
+		}
+		var buf bytes.Buffer
+		if _, err := f.WriteTo(&buf); err != nil {
+			t.Errorf("%q: %s", test.src, err)
+		}
+		if buf.String() != test.src {
+			t.Errorf("%q: expected round trip, got %q", test.src, buf.String())
+		}
+	}
+}
+
+// badCounter counts the BadExpr and BadStmt nodes within a tree.
+type badCounter struct {
+	exprs, stmts int
+}
+
+func (c *badCounter) Visit(node tree.Node) tree.Visitor {
+	switch node.(type) {
+	case *tree.BadExpr:
+		c.exprs++
+	case *tree.BadStmt:
+		c.stmts++
+	}
+	return c
+}
+
+func TestRecoverBadNodes(t *testing.T) {
+	tests := []struct {
+		src          string
+		exprs, stmts int
+	}{
+		{"x = @ + 1\nprint(2)", 1, 0},
+		{"return 1 x()", 0, 0},
+		{"do end until x\nend\nfoo()", 0, 2},
+		{"local t = {a = 1, b = }\nprint(t)", 1, 0},
+		{"local x = 1\nprint(x)\n", 0, 0},
+	}
+	for _, test := range tests {
+		f, _ := (&Config{Mode: RecoverErrors}).ParseFile("", test.src)
+		var c badCounter
+		tree.Walk(&c, f)
+		if c.exprs != test.exprs || c.stmts != test.stmts {
+			t.Errorf("%q: expected %d BadExpr and %d BadStmt, got %d and %d",
+				test.src, test.exprs, test.stmts, c.exprs, c.stmts)
+		}
+	}
+}
+
+func TestParseFileFirstError(t *testing.T) {
+	for _, test := range recoverTests {
+		_, err := ParseFile("", test.src)
+		if len(test.errs) == 0 {
+			if err != nil {
+				t.Errorf("%q: unexpected error %s", test.src, err)
+			}
+			continue
+		}
+		e, ok := err.(scanner.Error)
+		if !ok {
+			t.Errorf("%q: expected scanner.Error, got %T", test.src, err)
+			continue
+		}
+		got := fmt.Sprintf("%d:%d: %s", e.Position.Line, e.Position.Column, e.Message)
+		if got != test.errs[0] {
+			t.Errorf("%q: expected error %q, got %q", test.src, test.errs[0], got)
+		}
+	}
+}
+
+func TestErrorLimit(t *testing.T) {
+	tests := []struct {
+		config Config
+		src    string
+		n      int
+	}{
+		{Config{Mode: RecoverErrors}, "x = @\ny = @\nz = @\nw = @\n", 4},
+		{Config{Mode: RecoverErrors, ErrorLimit: 2}, "x = @\ny = @\nz = @\nw = @\n", 2},
+		{Config{Mode: RecoverErrors, ErrorLimit: -1}, "x = @\ny = @\nz = @\nw = @\n", 4},
+		{Config{Mode: RecoverErrors}, "x = @ @ @", 1},
+		{Config{Mode: RecoverErrors | AllErrors}, "x = @ @ @", 3},
+		{Config{}, "x = @\ny = @\n", 1},
+	}
+	for _, test := range tests {
+		_, err := test.config.ParseFile("", test.src)
+		if n := len(errorStrings(err)); n != test.n {
+			t.Errorf("%q with %+v: expected %d errors, got %d", test.src, test.config, test.n, n)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"fmt"
+	"github.com/anaminus/luasyntax/go/scanner"
+	"github.com/anaminus/luasyntax/go/tree"
+	"testing"
+)
+
+func TestParseSnippets(t *testing.T) {
+	tests := []struct {
+		kind   string
+		config Config
+		src    string
+		typ    string
+		ok     bool
+	}{
+		{"expr", Config{}, "a + b -- c", "*tree.BinopExpr", true},
+		{"expr", Config{}, "  f(x)\n", "*tree.CallExpr", true},
+		{"expr", Config{}, "a + b c", "", false},
+		{"expr", Config{}, "", "", false},
+		{"expr", Config{}, "$x", "", false},
+		{"expr", Config{Mode: Template}, "$x + 1", "*tree.BinopExpr", true},
+		{"stmt", Config{}, "local x = 1;", "*tree.LocalVarStmt", true},
+		{"stmt", Config{}, "f()", "*tree.CallStmt", true},
+		{"stmt", Config{}, "x = 1 y = 2", "", false},
+		{"stmt", Config{}, "x", "", false},
+		{"stmt", Config{Mode: Template}, "$x", "*tree.MetaStmt", true},
+		{"stmt", Config{Mode: Template}, "$x()", "*tree.CallStmt", true},
+		{"stmt", Config{Mode: Template}, "local $x = $y.z:$m()", "*tree.LocalVarStmt", true},
+		{"stmt", Config{Mode: Template}, "$", "", false},
+		{"stmt", Config{Mode: Template}, "$1", "", false},
+		{"block", Config{}, "x = 1 y = 2", "*tree.Block", true},
+		{"block", Config{}, "", "*tree.Block", true},
+		{"block", Config{}, "x = 1 end", "", false},
+		{"block", Config{Mode: Template}, "$a\n$b", "*tree.Block", true},
+	}
+	for _, test := range tests {
+		var node tree.Node
+		var err error
+		switch test.kind {
+		case "expr":
+			node, err = test.config.ParseExpr("", test.src)
+		case "stmt":
+			node, err = test.config.ParseStmt("", test.src)
+		case "block":
+			node, err = test.config.ParseBlock("", test.src)
+		}
+		if test.ok != (err == nil) {
+			t.Errorf("%s %q: expected ok=%t, got error %v", test.kind, test.src, test.ok, err)
+			continue
+		}
+		if !test.ok {
+			continue
+		}
+		if typ := fmt.Sprintf("%T", node); typ != test.typ {
+			t.Errorf("%s %q: expected %s, got %s", test.kind, test.src, test.typ, typ)
+		}
+	}
+}
+
+func TestParseSnippetFunctions(t *testing.T) {
+	if expr, err := ParseExpr("", "1 + 2"); err != nil {
+		t.Errorf("ParseExpr: unexpected error %s", err)
+	} else if got := source(expr); got != "1 + 2" {
+		t.Errorf("ParseExpr: expected %q, got %q", "1 + 2", got)
+	}
+	if stmt, err := ParseStmt("", "x = 1 -- c"); err != nil {
+		t.Errorf("ParseStmt: unexpected error %s", err)
+	} else if got := source(stmt); got != "x = 1" {
+		t.Errorf("ParseStmt: expected %q, got %q", "x = 1", got)
+	}
+	if block, err := ParseBlock("", "x = 1\ny = 2\n"); err != nil {
+		t.Errorf("ParseBlock: unexpected error %s", err)
+	} else if got := source(block); got != "x = 1\ny = 2" {
+		t.Errorf("ParseBlock: expected %q, got %q", "x = 1\ny = 2", got)
+	}
+
+	// As with ParseFile, only the first error is returned.
+	if _, err := ParseExpr("", "a + @ + @"); err == nil {
+		t.Error("ParseExpr: expected error")
+	} else if _, ok := err.(scanner.Error); !ok {
+		t.Errorf("ParseExpr: expected scanner.Error, got %T", err)
+	}
+}
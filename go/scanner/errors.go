@@ -2,6 +2,9 @@ package scanner
 
 import (
 	"github.com/anaminus/luasyntax/go/token"
+	"io"
+	"sort"
+	"strconv"
 )
 
 // An Error indicates an error within a file.
@@ -19,3 +22,100 @@ func (e Error) Error() string {
 	}
 	return e.Message
 }
+
+// ErrorList is a list of Errors.
+type ErrorList []*Error
+
+// Add appends an Error with the given position and message to the list.
+func (l *ErrorList) Add(pos token.Position, msg string) {
+	*l = append(*l, &Error{Position: pos, Message: msg})
+}
+
+// Reset empties the list.
+func (l *ErrorList) Reset() {
+	*l = (*l)[:0]
+}
+
+// Len returns the number of errors in the list.
+func (l ErrorList) Len() int {
+	return len(l)
+}
+
+// Swap implements the sort.Interface interface.
+func (l ErrorList) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
+}
+
+// Less implements the sort.Interface interface. Errors are ordered by
+// filename, then line, then column, then message.
+func (l ErrorList) Less(i, j int) bool {
+	a := &l[i].Position
+	b := &l[j].Position
+	if a.Filename != b.Filename {
+		return a.Filename < b.Filename
+	}
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	if a.Column != b.Column {
+		return a.Column < b.Column
+	}
+	return l[i].Message < l[j].Message
+}
+
+// Sort sorts the list by position. Errors with the same position are sorted
+// by message.
+func (l ErrorList) Sort() {
+	sort.Sort(l)
+}
+
+// RemoveMultiples sorts the list, then removes all but the first error for
+// each line of each file.
+func (l *ErrorList) RemoveMultiples() {
+	sort.Sort(l)
+	var last token.Position
+	i := 0
+	for _, e := range *l {
+		if e.Position.Filename != last.Filename || e.Position.Line != last.Line {
+			last = e.Position
+			(*l)[i] = e
+			i++
+		}
+	}
+	*l = (*l)[:i]
+}
+
+// Error implements the error interface. The result includes the first error,
+// and the number of remaining errors.
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	case 2:
+		return l[0].Error() + " (and 1 more error)"
+	}
+	return l[0].Error() + " (and " + strconv.Itoa(len(l)-1) + " more errors)"
+}
+
+// Err returns an error equivalent to the list, or nil if the list is empty.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// PrintError writes each error in err to w, one per line. If err is an
+// ErrorList, then each error in the list is written. Otherwise, the error is
+// written directly.
+func PrintError(w io.Writer, err error) {
+	if list, ok := err.(ErrorList); ok {
+		for _, e := range list {
+			io.WriteString(w, e.Error()+"\n")
+		}
+	} else if err != nil {
+		io.WriteString(w, err.Error()+"\n")
+	}
+}
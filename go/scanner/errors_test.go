@@ -0,0 +1,143 @@
+package scanner
+
+import (
+	"bytes"
+	"errors"
+	"github.com/anaminus/luasyntax/go/token"
+	"testing"
+)
+
+func pos(filename string, line, column int) token.Position {
+	return token.Position{Filename: filename, Line: line, Column: column}
+}
+
+// errorStrings returns each error of l formatted as a string.
+func errorStrings(l ErrorList) []string {
+	s := make([]string, len(l))
+	for i, e := range l {
+		s[i] = e.Error()
+	}
+	return s
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestErrorListSort(t *testing.T) {
+	tests := []struct {
+		in   ErrorList
+		want []string
+	}{
+		{nil, []string{}},
+		{
+			ErrorList{
+				{pos("b.lua", 1, 1), "x"},
+				{pos("a.lua", 2, 1), "x"},
+				{pos("a.lua", 1, 5), "y"},
+				{pos("a.lua", 1, 5), "x"},
+				{pos("a.lua", 1, 2), "z"},
+			},
+			[]string{
+				"a.lua:1:2: z",
+				"a.lua:1:5: x",
+				"a.lua:1:5: y",
+				"a.lua:2:1: x",
+				"b.lua:1:1: x",
+			},
+		},
+	}
+	for _, test := range tests {
+		test.in.Sort()
+		if got := errorStrings(test.in); !equalStrings(got, test.want) {
+			t.Errorf("expected %q, got %q", test.want, got)
+		}
+	}
+}
+
+func TestErrorListRemoveMultiples(t *testing.T) {
+	tests := []struct {
+		in   ErrorList
+		want []string
+	}{
+		{nil, []string{}},
+		{
+			ErrorList{
+				{pos("a.lua", 1, 9), "b"},
+				{pos("a.lua", 2, 1), "c"},
+				{pos("a.lua", 1, 3), "a"},
+				{pos("b.lua", 1, 3), "d"},
+				{pos("a.lua", 2, 4), "e"},
+			},
+			[]string{
+				"a.lua:1:3: a",
+				"a.lua:2:1: c",
+				"b.lua:1:3: d",
+			},
+		},
+	}
+	for _, test := range tests {
+		test.in.RemoveMultiples()
+		if got := errorStrings(test.in); !equalStrings(got, test.want) {
+			t.Errorf("expected %q, got %q", test.want, got)
+		}
+	}
+}
+
+func TestErrorListError(t *testing.T) {
+	tests := []struct {
+		in   ErrorList
+		want string
+	}{
+		{ErrorList{}, "no errors"},
+		{ErrorList{{pos("a.lua", 1, 2), "x"}}, "a.lua:1:2: x"},
+		{ErrorList{{pos("a.lua", 1, 2), "x"}, {pos("a.lua", 3, 4), "y"}}, "a.lua:1:2: x (and 1 more error)"},
+		{ErrorList{{pos("", 0, 0), "x"}, {}, {}}, "x (and 2 more errors)"},
+	}
+	for _, test := range tests {
+		if got := test.in.Error(); got != test.want {
+			t.Errorf("expected %q, got %q", test.want, got)
+		}
+	}
+}
+
+func TestErrorListErr(t *testing.T) {
+	var l ErrorList
+	if err := l.Err(); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+	l.Add(pos("a.lua", 1, 1), "x")
+	if err := l.Err(); err == nil {
+		t.Error("expected error")
+	}
+	l.Reset()
+	if l.Len() != 0 {
+		t.Errorf("expected empty list, got %d errors", l.Len())
+	}
+}
+
+func TestPrintError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{errors.New("x"), "x\n"},
+		{ErrorList{{pos("a.lua", 1, 2), "x"}, {pos("a.lua", 3, 4), "y"}}, "a.lua:1:2: x\na.lua:3:4: y\n"},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		PrintError(&buf, test.err)
+		if got := buf.String(); got != test.want {
+			t.Errorf("expected %q, got %q", test.want, got)
+		}
+	}
+}
@@ -12,6 +12,26 @@ func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
+func isHexDigit(ch rune) bool {
+	return '0' <= ch && ch <= '9' || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+func isBinDigit(ch rune) bool {
+	return ch == '0' || ch == '1'
+}
+
+func digitVal(ch rune) rune {
+	switch {
+	case '0' <= ch && ch <= '9':
+		return ch - '0'
+	case 'a' <= ch && ch <= 'f':
+		return ch - 'a' + 10
+	case 'A' <= ch && ch <= 'F':
+		return ch - 'A' + 10
+	}
+	return 16
+}
+
 func isLetter(ch rune) bool {
 	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
 }
@@ -25,20 +45,38 @@ func isSpace(ch rune) bool {
 // token and an error message.
 type ErrorHandler func(pos token.Position, msg string)
 
+// A Mode value is a set of flags that control the behavior of the scanner.
+type Mode uint
+
+const (
+	// ScanMetaVars causes a `$` followed by a name to be scanned as a
+	// token.METAVAR, which is used as a placeholder within templates.
+	ScanMetaVars Mode = 1 << iota
+)
+
 // Scanner holds the scanner's state while processing a source file. It must be
 // initialized with Init before using.
 type Scanner struct {
-	file *token.File
-	src  []byte
-	err  ErrorHandler
+	file    *token.File
+	src     []byte
+	err     ErrorHandler
+	dialect token.Dialect
 
 	ch         rune // Current character.
 	offset     int  // Offset of current character.
 	rdOffset   int  // Offset of next character to read.
 	lineOffset int  // Offset of the current line.
 
+	// interp holds, for each interpolated string being scanned, the number of
+	// unclosed braces within the current expression of the string.
+	interp []int
+
 	// ErrorCount is the number of errors encountered by the scanner.
 	ErrorCount int
+
+	// Mode is a set of flags that control the behavior of the scanner. It is
+	// not modified by Init.
+	Mode Mode
 }
 
 // next scans the next character, updating ch and offset, and tracking any new
@@ -64,16 +102,25 @@ func (s *Scanner) next() {
 
 // Init prepares the scanner to tokenize a given source. The file argument sets
 // the file to use for position information, and src sets the source to
-// tokenize. The option err argument is used to handle errors.
+// tokenize. The option err argument is used to handle errors. The source is
+// tokenized as Lua 5.1.
 func (s *Scanner) Init(file *token.File, src []byte, err ErrorHandler) {
+	s.InitDialect(file, src, err, token.Lua51)
+}
+
+// InitDialect is like Init, but the dialect argument determines which tokens
+// are recognized.
+func (s *Scanner) InitDialect(file *token.File, src []byte, err ErrorHandler, dialect token.Dialect) {
 	s.file = file
 	s.src = src
 	s.err = err
+	s.dialect = dialect
 
 	s.ch = ' '
 	s.offset = 0
 	s.rdOffset = 0
 	s.lineOffset = 0
+	s.interp = s.interp[:0]
 
 	s.next()
 }
@@ -86,6 +133,18 @@ func (s *Scanner) error(off int, msg string) {
 	s.ErrorCount++
 }
 
+// bom is the UTF-8 byte order mark.
+var bom = []byte{0xEF, 0xBB, 0xBF}
+
+// atStart returns whether off is at the start of the source, not including a
+// byte order mark.
+func (s *Scanner) atStart(off int) bool {
+	if bytes.HasPrefix(s.src, bom) {
+		return off == len(bom)
+	}
+	return off == 0
+}
+
 // scanSpace scans for a sequence of space characters.
 func (s *Scanner) scanSpace() {
 	for isSpace(s.ch) {
@@ -102,23 +161,210 @@ func (s *Scanner) scanName() []byte {
 	return s.src[off:s.offset]
 }
 
-// scanNumber scans for a number. The result may not evaluate to a valid number.
-func (s *Scanner) scanNumber() token.Type {
-	off := s.offset
-	for isDigit(s.ch) || s.ch == '.' {
+// scanNumber scans for a number, which begins at off. Any characters that
+// could continue the number are consumed, and then the result is checked
+// against the number forms allowed by the dialect.
+func (s *Scanner) scanNumber(off int) token.Type {
+	expo := [2]rune{'e', 'E'}
+	if s.ch == '0' && s.offset == off {
 		s.next()
+		if s.ch == 'x' || s.ch == 'X' {
+			expo = [2]rune{'p', 'P'}
+		}
+	}
+	for {
+		if s.ch == expo[0] || s.ch == expo[1] {
+			s.next()
+			if s.ch == '+' || s.ch == '-' {
+				s.next()
+			}
+		} else if isLetter(s.ch) || isDigit(s.ch) || s.ch == '.' {
+			s.next()
+		} else {
+			break
+		}
+	}
+	lit := s.src[off:s.offset]
+	tok, ok := checkNumber(lit, s.dialect)
+	if !ok {
+		s.error(off, "malformed number near '"+string(lit)+"'")
 	}
-	if s.ch == 'e' || s.ch == 'E' {
+	return tok
+}
+
+// checkNumber returns the type of number represented by lit, and whether lit
+// is a well-formed number within dialect d.
+func checkNumber(lit []byte, d token.Dialect) (t token.Type, ok bool) {
+	i := 0
+	// digits scans a sequence of digits, returning the number of digits
+	// scanned.
+	digits := func(isDigit func(rune) bool) (n int) {
+		for ; i < len(lit); i++ {
+			if lit[i] == '_' && n > 0 && d.Has(token.DigitSeparator) {
+				continue
+			}
+			if !isDigit(rune(lit[i])) {
+				break
+			}
+			n++
+		}
+		return n
+	}
+	// exponent scans an optional exponent, returning false if the exponent is
+	// malformed.
+	exponent := func(e byte) bool {
+		if i >= len(lit) || lit[i]|0x20 != e {
+			return true
+		}
+		i++
+		if i < len(lit) && (lit[i] == '+' || lit[i] == '-') {
+			i++
+		}
+		return digits(isDigit) > 0
+	}
+
+	t = token.NUMBERFLOAT
+	integral := true // Whether the number has no fraction or exponent.
+	switch {
+	case len(lit) >= 2 && lit[0] == '0' && lit[1]|0x20 == 'x':
+		t = token.NUMBERHEX
+		i = 2
+		n := digits(isHexDigit)
+		ok = n > 0
+		if d.Has(token.HexFloat) {
+			j := i
+			if i < len(lit) && lit[i] == '.' {
+				i++
+				n += digits(isHexDigit)
+			}
+			ok = n > 0 && exponent('p')
+			integral = i == j
+		}
+	case len(lit) >= 2 && lit[0] == '0' && lit[1]|0x20 == 'b' && d.Has(token.BinaryNumber):
+		t = token.NUMBERBIN
+		i = 2
+		ok = digits(isBinDigit) > 0
+	default:
+		n := digits(isDigit)
+		j := i
+		if i < len(lit) && lit[i] == '.' {
+			i++
+			n += digits(isDigit)
+		}
+		ok = n > 0 && exponent('e')
+		integral = i == j
+	}
+	if ok && i < len(lit) && d.Has(token.NumberSuffix) {
+		t, ok = checkNumberSuffix(lit[i:], t, integral)
+		i = len(lit)
+	}
+	return t, ok && i == len(lit)
+}
+
+// checkNumberSuffix returns the type of number indicated by suffix, which
+// follows a number of type t, and whether the suffix is well-formed. The
+// integral argument is whether the number has no fraction or exponent.
+func checkNumberSuffix(suffix []byte, t token.Type, integral bool) (token.Type, bool) {
+	switch string(bytes.ToLower(suffix)) {
+	case "i":
+		return token.NUMBERIMAG, true
+	case "ll":
+		return token.NUMBERI64, integral
+	case "ull":
+		return token.NUMBERU64, integral
+	}
+	return t, false
+}
+
+// scanEscape scans an escape sequence within a quoted string, beginning after
+// the `\` character at off.
+func (s *Scanner) scanEscape(off int) {
+	switch s.ch {
+	case 'a', 'b', 'f', 'n', 'r', 't', 'v', '\\', '"', '\'':
+		s.next()
+		return
+	case '\n', '\r':
+		c := s.ch
+		s.next()
+		if (s.ch == '\n' || s.ch == '\r') && s.ch != c {
+			s.next()
+		}
+		return
+	case eof:
+		// handled by caller
+		return
+	case 'x':
+		if !s.dialect.Has(token.HexEscape) {
+			break
+		}
+		s.next()
+		for i := 0; i < 2; i++ {
+			if !isHexDigit(s.ch) {
+				s.error(off, "hexadecimal digit expected")
+				return
+			}
+			s.next()
+		}
+		return
+	case 'z':
+		if !s.dialect.Has(token.SkipEscape) {
+			break
+		}
+		s.next()
+		s.scanSpace()
+		return
+	case 'u':
+		if !s.dialect.Has(token.UnicodeEscape) {
+			break
+		}
 		s.next()
-		if s.ch == '+' || s.ch == '-' {
+		if s.ch != '{' {
+			s.error(off, "missing '{' in \\u{xxxx}")
+			return
+		}
+		s.next()
+		if !isHexDigit(s.ch) {
+			s.error(off, "hexadecimal digit expected")
+			return
+		}
+		max := int64(0x10FFFF)
+		if s.dialect.Has(token.LongUTF8) {
+			max = 0x7FFFFFFF
+		}
+		var r int64
+		for isHexDigit(s.ch) {
+			if r <= max {
+				r = r<<4 | int64(digitVal(s.ch))
+			}
 			s.next()
 		}
+		if r > max {
+			s.error(off, "UTF-8 value too large")
+		}
+		if s.ch != '}' {
+			s.error(off, "missing '}' in \\u{xxxx}")
+			return
+		}
+		s.next()
+		return
+	default:
+		if isDigit(s.ch) {
+			var c rune
+			for i := 0; i < 3 && isDigit(s.ch); i++ {
+				c = 10*c + (s.ch - '0')
+				s.next()
+			}
+			if c > 255 { // UCHAR_MAX
+				s.error(off, "decimal escape too large")
+			}
+			return
+		}
 	}
-	s.scanName()
-	if bytes.HasPrefix(s.src[off:s.offset], []byte{'0', 'x'}) {
-		return token.NUMBERHEX
+	if s.dialect.Has(token.StrictEscape) {
+		s.error(off, "invalid escape sequence")
 	}
-	return token.NUMBERFLOAT
+	// The character is escaped as itself.
+	s.next()
 }
 
 // scanString scans for a quoted string.
@@ -134,39 +380,54 @@ func (s *Scanner) scanString(off int) {
 			s.error(off, "unfinished string (EOL)")
 			return
 		case '\\':
+			esc := s.offset
 			s.next()
-			switch s.ch {
-			case '\n', '\r':
-				c := s.ch
+			s.scanEscape(esc)
+			continue
+		}
+		s.next()
+	}
+	s.next()
+}
+
+// scanInterp scans a segment of an interpolated string, following a '`' or '}'
+// character. The segment ends at a '`' character, which terminates the string,
+// or a '{' character, which begins an expression. The tail argument indicates
+// whether the segment follows an expression.
+func (s *Scanner) scanInterp(off int, tail bool) token.Type {
+	for {
+		switch s.ch {
+		case eof:
+			s.error(off, "unfinished string (EOF)")
+			return token.INTERPEND
+		case '\n', '\r':
+			s.error(off, "unfinished string (EOL)")
+			return token.INTERPEND
+		case '\\':
+			esc := s.offset
+			s.next()
+			if s.ch == '{' || s.ch == '`' {
 				s.next()
-				if (s.ch == '\n' || s.ch == '\r') && s.ch != c {
-					s.next()
-				}
-				continue
-			case eof:
-				// handled in next loop
-				continue
-			default:
-				if isDigit(s.ch) {
-					var c rune
-					for i := 0; ; {
-						c = 10*c + (s.ch - '0')
-						s.next()
-						i++
-						if i >= 3 || !isDigit(s.ch) {
-							break
-						}
-						if c > 255 { // UCHAR_MAX
-							s.error(off, "escape sequence too large")
-						}
-					}
-					continue
-				}
+			} else {
+				s.scanEscape(esc)
+			}
+			continue
+		case '`':
+			s.next()
+			if tail {
+				return token.INTERPEND
 			}
+			return token.INTERPSTRING
+		case '{':
+			s.next()
+			s.interp = append(s.interp, 0)
+			if tail {
+				return token.INTERPMID
+			}
+			return token.INTERPBEGIN
 		}
 		s.next()
 	}
-	s.next()
 }
 
 // scanLongString scans for a long, bracket-enclosed string.
@@ -191,6 +452,7 @@ loop:
 			} else {
 				s.error(off, "unfinished long string near '<eof>'")
 			}
+			return
 		}
 		if s.ch == ']' {
 			s.next()
@@ -223,21 +485,47 @@ func (s *Scanner) scanComment(off int) token.Type {
 	return token.COMMENT
 }
 
+// scanAssign returns the compound assignment type if the current character is
+// '=' and the dialect has compound assignment, consuming the character.
+// Otherwise, the operator type is returned.
+func (s *Scanner) scanAssign(op, assign token.Type) token.Type {
+	if s.ch == '=' && assign.InDialect(s.dialect) {
+		s.next()
+		return assign
+	}
+	return op
+}
+
 // Scan scans the next token and returns its offset, type, and the bytes
 // represented by the token. The end of the source is indicated by token.EOF as
 // the type.
 //
+// A UTF-8 byte order mark at the start of the source is returned as token.BOM,
+// and a following line that begins with '#' is returned as token.SHEBANG.
+//
 // Scan adds line information to the token.File specified by Init.
 func (s *Scanner) Scan() (off int, tok token.Type, lit []byte) {
 	off = s.offset
 	switch ch := s.ch; {
+	case off == 0 && bytes.HasPrefix(s.src, bom):
+		for range bom {
+			s.next()
+		}
+		tok = token.BOM
+	case ch == '#' && s.atStart(off):
+		// As with luaL_loadfile, the first line is skipped if it begins with
+		// '#'.
+		for s.ch != '\n' && s.ch != '\r' && s.ch != eof {
+			s.next()
+		}
+		tok = token.SHEBANG
 	case isSpace(ch):
 		s.scanSpace()
 		tok = token.SPACE
 	case isLetter(ch):
-		tok = token.Lookup(string(s.scanName()))
+		tok = token.LookupDialect(string(s.scanName()), s.dialect)
 	case isDigit(ch):
-		tok = s.scanNumber()
+		tok = s.scanNumber(off)
 	case ch == '"', ch == '\'':
 		s.scanString(off)
 		tok = token.STRING
@@ -247,29 +535,49 @@ func (s *Scanner) Scan() (off int, tok token.Type, lit []byte) {
 		case '-':
 			if s.ch == '-' {
 				tok = s.scanComment(off)
+			} else if s.ch == '>' && token.ARROW.InDialect(s.dialect) {
+				s.next()
+				tok = token.ARROW
 			} else {
-				tok = token.MINUS
+				tok = s.scanAssign(token.MINUS, token.SUBASSIGN)
 			}
 		case '+':
-			tok = token.PLUS
+			tok = s.scanAssign(token.PLUS, token.ADDASSIGN)
 		case '*':
-			tok = token.ASTERISK
+			tok = s.scanAssign(token.ASTERISK, token.MULASSIGN)
 		case '/':
-			tok = token.SLASH
+			if s.ch == '/' && token.DSLASH.InDialect(s.dialect) {
+				s.next()
+				tok = s.scanAssign(token.DSLASH, token.IDIVASSIGN)
+			} else {
+				tok = s.scanAssign(token.SLASH, token.DIVASSIGN)
+			}
+		case '&':
+			if token.AMPERSAND.InDialect(s.dialect) {
+				tok = token.AMPERSAND
+			} else {
+				s.error(off, "unexpected symbol")
+			}
+		case '|':
+			if token.PIPE.InDialect(s.dialect) {
+				tok = token.PIPE
+			} else {
+				s.error(off, "unexpected symbol")
+			}
 		case '%':
-			tok = token.PERCENT
+			tok = s.scanAssign(token.PERCENT, token.MODASSIGN)
 		case '^':
-			tok = token.CARET
+			tok = s.scanAssign(token.CARET, token.POWASSIGN)
 		case '.':
 			if isDigit(s.ch) {
-				tok = s.scanNumber()
+				tok = s.scanNumber(off)
 			} else if s.ch == '.' {
 				s.next()
 				if s.ch == '.' {
 					s.next()
 					tok = token.VARARG
 				} else {
-					tok = token.CONCAT
+					tok = s.scanAssign(token.CONCAT, token.CATASSIGN)
 				}
 			} else {
 				tok = token.DOT
@@ -278,6 +586,9 @@ func (s *Scanner) Scan() (off int, tok token.Type, lit []byte) {
 			if s.ch == '=' {
 				s.next()
 				tok = token.LEQ
+			} else if s.ch == '<' && token.SHL.InDialect(s.dialect) {
+				s.next()
+				tok = token.SHL
 			} else {
 				tok = token.LT
 			}
@@ -285,6 +596,9 @@ func (s *Scanner) Scan() (off int, tok token.Type, lit []byte) {
 			if s.ch == '=' {
 				s.next()
 				tok = token.GEQ
+			} else if s.ch == '>' && token.SHR.InDialect(s.dialect) {
+				s.next()
+				tok = token.SHR
 			} else {
 				tok = token.GT
 			}
@@ -299,15 +613,22 @@ func (s *Scanner) Scan() (off int, tok token.Type, lit []byte) {
 			if s.ch == '=' {
 				s.next()
 				tok = token.NEQ
+			} else if token.TILDE.InDialect(s.dialect) {
+				tok = token.TILDE
 			} else {
-				s.error(s.offset, "unexpected symbol")
+				s.error(off, "unexpected symbol")
 			}
 		case ';':
 			tok = token.SEMICOLON
 		case ',':
 			tok = token.COMMA
 		case ':':
-			tok = token.COLON
+			if s.ch == ':' && token.DBCOLON.InDialect(s.dialect) {
+				s.next()
+				tok = token.DBCOLON
+			} else {
+				tok = token.COLON
+			}
 		case '[':
 			if s.ch == '[' || s.ch == '=' {
 				s.scanLongString(off, token.LONGSTRING)
@@ -322,14 +643,47 @@ func (s *Scanner) Scan() (off int, tok token.Type, lit []byte) {
 		case ')':
 			tok = token.RPAREN
 		case '{':
+			if n := len(s.interp); n > 0 {
+				s.interp[n-1]++
+			}
 			tok = token.LBRACE
 		case '}':
+			if n := len(s.interp); n > 0 {
+				if s.interp[n-1] == 0 {
+					// Closes the expression of an interpolated string.
+					s.interp = s.interp[:n-1]
+					tok = s.scanInterp(off, true)
+					break
+				}
+				s.interp[n-1]--
+			}
 			tok = token.RBRACE
+		case '`':
+			if token.INTERPSTRING.InDialect(s.dialect) {
+				tok = s.scanInterp(off, false)
+			} else {
+				s.error(off, "unexpected symbol")
+			}
+		case '?':
+			if token.QUESTION.InDialect(s.dialect) {
+				tok = token.QUESTION
+			} else {
+				s.error(off, "unexpected symbol")
+			}
 		case '#':
 			tok = token.HASH
+		case '$':
+			if s.Mode&ScanMetaVars != 0 && isLetter(s.ch) {
+				s.scanName()
+				tok = token.METAVAR
+			} else {
+				s.error(off, "unexpected symbol")
+				tok = token.INVALID
+			}
 		case eof:
 			tok = token.EOF
 		default:
+			s.error(off, "unexpected symbol")
 			tok = token.INVALID
 		}
 	}
@@ -0,0 +1,91 @@
+package scanner
+
+import (
+	"github.com/anaminus/luasyntax/go/token"
+	"strings"
+	"testing"
+)
+
+// scanAll returns the type of each token scanned from src, excluding spaces,
+// and the number of errors.
+func scanAll(src string, init func(s *Scanner, file *token.File, src []byte)) (types []string, errs int) {
+	var s Scanner
+	file := token.NewFile("")
+	init(&s, file, []byte(src))
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.SPACE {
+			continue
+		}
+		if tok == token.NAME {
+			types = append(types, string(lit))
+		} else {
+			types = append(types, tok.String())
+		}
+	}
+	return types, s.ErrorCount
+}
+
+func TestScanDialect(t *testing.T) {
+	tests := []struct {
+		dialect token.Dialect
+		src     string
+		want    string
+	}{
+		{token.Lua51, "goto a", "goto a"},
+		{token.Lua52, "goto a", "goto a"},
+		{token.Lua51, "a // b", "a / / b"},
+		{token.Lua53, "a // b", "a // b"},
+		{token.Lua53, "a ~ b << c", "a ~ b << c"},
+		{token.Lua51, "::a::", ": : a : :"},
+		{token.Lua52, "::a::", ":: a ::"},
+		{token.Luau, "a += 1", "a += <number>"},
+		{token.Lua54, "a += 1", "a + = <number>"},
+	}
+	for _, test := range tests {
+		types, _ := scanAll(test.src, func(s *Scanner, file *token.File, src []byte) {
+			s.InitDialect(file, src, nil, test.dialect)
+		})
+		if got := strings.Join(types, " "); got != test.want {
+			t.Errorf("%s %q: expected %q, got %q", test.dialect, test.src, test.want, got)
+		}
+		if test.dialect != token.Lua51 {
+			continue
+		}
+		types, _ = scanAll(test.src, func(s *Scanner, file *token.File, src []byte) {
+			s.Init(file, src, nil)
+		})
+		if got := strings.Join(types, " "); got != test.want {
+			t.Errorf("%q: expected %q, got %q", test.src, test.want, got)
+		}
+	}
+}
+
+func TestScanHeader(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"\xEF\xBB\xBF#!/usr/bin/env lua\nx", "<bom> <shebang> x"},
+		{"#!/usr/bin/env lua\r\nx", "<shebang> x"},
+		{"\xEF\xBB\xBFx", "<bom> x"},
+		{"#", "<shebang>"},
+		{"# x\n#x", "<shebang> # x"},
+		{"x #y", "x # y"},
+		{" #x", "# x"},
+	}
+	for _, test := range tests {
+		types, errs := scanAll(test.src, func(s *Scanner, file *token.File, src []byte) {
+			s.Init(file, src, nil)
+		})
+		if got := strings.Join(types, " "); got != test.want {
+			t.Errorf("%q: expected %q, got %q", test.src, test.want, got)
+		}
+		if errs != 0 {
+			t.Errorf("%q: unexpected %d errors", test.src, errs)
+		}
+	}
+}
@@ -0,0 +1,416 @@
+// The tmpl package implements templates, which are snippets of Lua source code
+// containing placeholders. A template may be instantiated to produce a tree in
+// which the placeholders are replaced with concrete nodes, or matched against a
+// tree to determine the nodes that correspond to each placeholder.
+//
+// A placeholder, or metavariable, is a `$` followed by a name, such as `$x`. A
+// metavariable may appear wherever a name may appear. When it appears as an
+// expression, it corresponds to any expression. When it appears as an entire
+// statement, it corresponds to any statement. Otherwise, it corresponds to a
+// name, which is represented by a *tree.VariableExpr.
+//
+//	t := tmpl.MustParseStmt("local $name = require($path)")
+//	stmt, err := t.Instantiate(tmpl.Bindings{
+//		"name": build.Name("json"),
+//		"path": build.Str("json"),
+//	})
+package tmpl
+
+import (
+	"bytes"
+	"errors"
+	"github.com/anaminus/luasyntax/go/build"
+	"github.com/anaminus/luasyntax/go/parser"
+	"github.com/anaminus/luasyntax/go/token"
+	"github.com/anaminus/luasyntax/go/tree"
+	"reflect"
+)
+
+// Bindings maps the name of a metavariable, excluding the `$`, to the node
+// that corresponds to it.
+type Bindings map[string]tree.Node
+
+// Template is a parse tree that contains metavariables.
+type Template struct {
+	node    tree.Node
+	dialect token.Dialect
+	vars    []string
+}
+
+// Config configures how a template is parsed. The zero value is a valid
+// configuration.
+type Config struct {
+	// Dialect is the dialect of Lua with which the template is parsed.
+	Dialect token.Dialect
+}
+
+// metaVarName returns the name of the metavariable represented by tok, and
+// whether tok is a metavariable.
+func metaVarName(tok *tree.Token) (name string, ok bool) {
+	if tok.Type != token.NAME || !bytes.HasPrefix(tok.Bytes, []byte("$")) {
+		return "", false
+	}
+	return string(tok.Bytes[1:]), true
+}
+
+// metaVarNode returns the name of the metavariable represented by node, and
+// whether node is a metavariable expression or statement.
+func metaVarNode(node tree.Node) (name string, ok bool) {
+	switch n := node.(type) {
+	case *tree.VariableExpr:
+		if n != nil {
+			return metaVarName(&n.NameToken)
+		}
+	case *tree.MetaStmt:
+		if n != nil {
+			return metaVarName(&n.NameToken)
+		}
+	}
+	return "", false
+}
+
+// varCollector collects the names of metavariables in order of appearance.
+type varCollector struct {
+	seen map[string]bool
+	vars []string
+}
+
+func (v *varCollector) Visit(tree.Node) tree.Visitor { return v }
+
+func (v *varCollector) VisitToken(_ tree.Node, _ int, tok *tree.Token) {
+	if name, ok := metaVarName(tok); ok && !v.seen[name] {
+		v.seen[name] = true
+		v.vars = append(v.vars, name)
+	}
+}
+
+// newTemplate creates a template from a parsed tree.
+func (c *Config) newTemplate(node tree.Node, err error) (*Template, error) {
+	if err != nil {
+		return nil, err
+	}
+	v := varCollector{seen: map[string]bool{}}
+	tree.Walk(&v, node)
+	return &Template{node: node, dialect: c.Dialect, vars: v.vars}, nil
+}
+
+// parserConfig returns the configuration used to parse templates.
+func (c *Config) parserConfig() *parser.Config {
+	return &parser.Config{Mode: parser.Template, Dialect: c.Dialect}
+}
+
+// ParseExpr parses a template of a single expression.
+func (c *Config) ParseExpr(src string) (*Template, error) {
+	return c.newTemplate(c.parserConfig().ParseExpr("", src))
+}
+
+// ParseStmt parses a template of a single statement.
+func (c *Config) ParseStmt(src string) (*Template, error) {
+	return c.newTemplate(c.parserConfig().ParseStmt("", src))
+}
+
+// ParseBlock parses a template of a block of statements.
+func (c *Config) ParseBlock(src string) (*Template, error) {
+	return c.newTemplate(c.parserConfig().ParseBlock("", src))
+}
+
+// ParseExpr parses a template of a single expression as Lua 5.1.
+func ParseExpr(src string) (*Template, error) {
+	return (&Config{}).ParseExpr(src)
+}
+
+// ParseStmt parses a template of a single statement as Lua 5.1.
+func ParseStmt(src string) (*Template, error) {
+	return (&Config{}).ParseStmt(src)
+}
+
+// ParseBlock parses a template of a block of statements as Lua 5.1.
+func ParseBlock(src string) (*Template, error) {
+	return (&Config{}).ParseBlock(src)
+}
+
+// MustParseExpr is like ParseExpr, but panics if the template could not be
+// parsed.
+func MustParseExpr(src string) *Template {
+	t, err := ParseExpr(src)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// MustParseStmt is like ParseStmt, but panics if the template could not be
+// parsed.
+func MustParseStmt(src string) *Template {
+	t, err := ParseStmt(src)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// MustParseBlock is like ParseBlock, but panics if the template could not be
+// parsed.
+func MustParseBlock(src string) *Template {
+	t, err := ParseBlock(src)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Node returns the parsed tree of the template. The tree must not be modified.
+func (t *Template) Node() tree.Node {
+	return t.node
+}
+
+// Dialect returns the dialect with which the template was parsed.
+func (t *Template) Dialect() token.Dialect {
+	return t.dialect
+}
+
+// Vars returns the names of the metavariables in the template, excluding the
+// `$`, in order of first appearance.
+func (t *Template) Vars() []string {
+	return append([]string(nil), t.vars...)
+}
+
+// Instantiate returns a copy of the tree of the template, with each
+// metavariable replaced by a copy of its bound node. A metavariable that
+// appears as an expression must be bound to a tree.Expr. A metavariable that
+// appears as a statement must be bound to a tree.Stmt, or a *tree.Block whose
+// statements are inserted in place of the metavariable. Any other
+// metavariable must be bound to a *tree.VariableExpr, whose name is used.
+//
+// A replacing node receives the spacing and comments that precede the
+// metavariable, unless the node is itself preceded by comments. Expressions
+// are enclosed in parentheses as needed to retain their meaning, and adjoined
+// tokens are separated as needed. The offsets of the tokens of the result are
+// relative to the start of the result.
+//
+// Returns an error if a metavariable is not bound, or is bound to a node of
+// the wrong kind.
+func (t *Template) Instantiate(b Bindings) (node tree.Node, err error) {
+	node = tree.Clone(t.node)
+	node = tree.Apply(node, func(c *tree.Cursor) bool {
+		if err != nil {
+			return false
+		}
+		name, ok := metaVarNode(c.Node())
+		if !ok {
+			return true
+		}
+		err = replace(c, name, b[name])
+		return false
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	r := nameReplacer{b: b}
+	tree.Walk(&r, node)
+	if r.err != nil {
+		return nil, r.err
+	}
+	tree.FixAdjoinedTokensDialect(node, t.dialect)
+	tree.FixTokenOffsets(node, 0)
+	return node, nil
+}
+
+// metaVarError returns an error for the metavariable name.
+func metaVarError(name, msg string) error {
+	return errors.New("metavariable $" + name + ": " + msg)
+}
+
+// replace replaces the metavariable at c with a copy of bound.
+func replace(c *tree.Cursor, name string, bound tree.Node) error {
+	if bound == nil || reflect.ValueOf(bound).IsNil() {
+		return metaVarError(name, "not bound")
+	}
+	prefix := c.Node().FirstToken().Prefix
+	switch c.Node().(type) {
+	case *tree.VariableExpr:
+		expr, ok := tree.Clone(bound).(tree.Expr)
+		if !ok {
+			return metaVarError(name, "expression expected")
+		}
+		expr = build.Parenthesize(c.Parent(), c.Name(), expr)
+		setPrefix(expr, prefix)
+		c.Replace(expr)
+	case *tree.MetaStmt:
+		switch n := tree.Clone(bound).(type) {
+		case tree.Stmt:
+			setPrefix(n, prefix)
+			c.Replace(n)
+		case *tree.Block:
+			if len(n.Items) > 0 {
+				setPrefix(n.Items[0], prefix)
+			}
+			if _, ok := c.Parent().(*tree.Block); !ok {
+				c.Replace(n)
+				break
+			}
+			for _, stmt := range n.Items {
+				c.InsertBefore(stmt)
+			}
+			c.Delete()
+		default:
+			return metaVarError(name, "statement expected")
+		}
+	}
+	return nil
+}
+
+// setPrefix sets the prefix of the first token of node, if there is one, and
+// if the current prefix does not contain comments.
+func setPrefix(node tree.Node, prefix []tree.Prefix) {
+	tok := node.FirstToken()
+	if tok == nil {
+		return
+	}
+	for _, p := range tok.Prefix {
+		if p.Type.IsComment() {
+			return
+		}
+	}
+	tok.Prefix = prefix
+}
+
+// nameReplacer replaces the bytes of each metavariable token with the name of
+// its bound variable.
+type nameReplacer struct {
+	b   Bindings
+	err error
+}
+
+func (r *nameReplacer) Visit(tree.Node) tree.Visitor { return r }
+
+func (r *nameReplacer) VisitToken(_ tree.Node, _ int, tok *tree.Token) {
+	name, ok := metaVarName(tok)
+	if !ok || r.err != nil {
+		return
+	}
+	switch v := r.b[name].(type) {
+	case nil:
+		r.err = metaVarError(name, "not bound")
+	case *tree.VariableExpr:
+		tok.Bytes = append([]byte(nil), v.NameToken.Bytes...)
+	default:
+		r.err = metaVarError(name, "name expected")
+	}
+}
+
+// Match reports whether the tree of node has the same structure as the
+// template, and returns the node bound to each metavariable. Whitespace,
+// comments, and offsets are ignored, as are the separators of blocks and
+// table constructors. Each occurrence of a metavariable must correspond to an
+// equal node. A metavariable that corresponds to a name is bound to a new
+// *tree.VariableExpr holding the name; other bound nodes are a part of the
+// tree of node.
+func (t *Template) Match(node tree.Node) (b Bindings, ok bool) {
+	m := matcher{b: Bindings{}}
+	p := t.node
+	if !m.value(reflect.ValueOf(&p).Elem(), reflect.ValueOf(&node).Elem()) {
+		return nil, false
+	}
+	return m.b, true
+}
+
+// matcher compares a template tree with another tree, accumulating bindings.
+type matcher struct {
+	b Bindings
+}
+
+var (
+	nodeType  = reflect.TypeOf((*tree.Node)(nil)).Elem()
+	tokenType = reflect.TypeOf(tree.Token{})
+)
+
+// equalMode is the mode used to compare nodes bound to the same metavariable.
+const equalMode = tree.IgnoreSpace | tree.IgnoreComments | tree.IgnoreOffsets
+
+// bind binds node to a metavariable, returning whether the binding is
+// consistent with any existing binding.
+func (m *matcher) bind(name string, node tree.Node) bool {
+	if prev, ok := m.b[name]; ok {
+		return tree.Equal(prev, node, equalMode)
+	}
+	m.b[name] = node
+	return true
+}
+
+// value compares a template value p with a value n of the same type.
+func (m *matcher) value(p, n reflect.Value) bool {
+	switch p.Kind() {
+	case reflect.Interface:
+		if p.IsNil() || n.IsNil() {
+			return p.IsNil() == n.IsNil()
+		}
+		if name, ok := metaVarNode(p.Interface().(tree.Node)); ok {
+			node := n.Interface().(tree.Node)
+			switch p.Interface().(type) {
+			case *tree.VariableExpr:
+				_, ok = node.(tree.Expr)
+			case *tree.MetaStmt:
+				_, ok = node.(tree.Stmt)
+			}
+			return ok && m.bind(name, node)
+		}
+		if p.Elem().Type() != n.Elem().Type() {
+			return false
+		}
+		return m.value(p.Elem(), n.Elem())
+	case reflect.Ptr:
+		if !p.Type().Implements(nodeType) {
+			// Not a part of the tree.
+			return true
+		}
+		if p.IsNil() || n.IsNil() {
+			return p.IsNil() == n.IsNil()
+		}
+		return m.value(p.Elem(), n.Elem())
+	case reflect.Struct:
+		switch p.Type() {
+		case tokenType:
+			return m.token(p.Addr().Interface().(*tree.Token), n.Addr().Interface().(*tree.Token))
+		case reflect.TypeOf(tree.Block{}), reflect.TypeOf(tree.EntryList{}), reflect.TypeOf(tree.TypeEntryList{}):
+			// Separators are insignificant.
+			return m.value(p.FieldByName("Items"), n.FieldByName("Items"))
+		case reflect.TypeOf(tree.File{}):
+			return m.value(p.FieldByName("Body"), n.FieldByName("Body"))
+		}
+		for i := 0; i < p.NumField(); i++ {
+			if !m.value(p.Field(i), n.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice:
+		if p.Len() != n.Len() {
+			return false
+		}
+		for i := 0; i < p.Len(); i++ {
+			if !m.value(p.Index(i), n.Index(i)) {
+				return false
+			}
+		}
+		return true
+	default:
+		return p.Interface() == n.Interface()
+	}
+}
+
+// token compares a template token p with a token n.
+func (m *matcher) token(p, n *tree.Token) bool {
+	if name, ok := metaVarName(p); ok {
+		if n.Type != token.NAME {
+			return false
+		}
+		if prev, ok := m.b[name]; ok {
+			v, ok := prev.(*tree.VariableExpr)
+			return ok && bytes.Equal(v.NameToken.Bytes, n.Bytes)
+		}
+		m.b[name] = &tree.VariableExpr{NameToken: *n}
+		return true
+	}
+	return p.Type == n.Type && bytes.Equal(p.Bytes, n.Bytes)
+}
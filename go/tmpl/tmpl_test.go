@@ -0,0 +1,354 @@
+package tmpl
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/anaminus/luasyntax/go/build"
+	"github.com/anaminus/luasyntax/go/parser"
+	"github.com/anaminus/luasyntax/go/token"
+	"github.com/anaminus/luasyntax/go/tree"
+	"strings"
+	"testing"
+)
+
+func source(node tree.Node) string {
+	var buf bytes.Buffer
+	node.WriteTo(&buf)
+	return buf.String()
+}
+
+// validator reports each invalid node within a tree.
+type validator struct{ t *testing.T }
+
+func (v validator) Visit(node tree.Node) tree.Visitor {
+	if node != nil && !node.IsValid() {
+		v.t.Errorf("invalid %T", node)
+	}
+	return v
+}
+
+func expr(t *testing.T, src string) tree.Expr {
+	e, err := parser.ParseExpr("", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func stmt(t *testing.T, src string) tree.Stmt {
+	s, err := parser.ParseStmt("", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func block(t *testing.T, src string) *tree.Block {
+	b, err := parser.ParseBlock("", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		parse func(string) (*Template, error)
+		src   string
+		vars  []string
+		node  string
+	}{
+		{ParseExpr, "$a + $b * $a", []string{"a", "b"}, "*tree.BinopExpr"},
+		{ParseExpr, "f($x).$y", []string{"x", "y"}, "*tree.FieldExpr"},
+		{ParseStmt, "local $name = require($path)", []string{"name", "path"}, "*tree.LocalVarStmt"},
+		{ParseStmt, "$s", []string{"s"}, "*tree.MetaStmt"},
+		{ParseStmt, "x = 1", nil, "*tree.AssignStmt"},
+		{ParseBlock, "$s\nreturn $x", []string{"s", "x"}, "*tree.Block"},
+	}
+	for _, test := range tests {
+		tm, err := test.parse(test.src)
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", test.src, err)
+			continue
+		}
+		if got := tm.Vars(); strings.Join(got, ",") != strings.Join(test.vars, ",") {
+			t.Errorf("%q: expected vars %q, got %q", test.src, test.vars, got)
+		}
+		if got := fmt.Sprintf("%T", tm.Node()); got != test.node {
+			t.Errorf("%q: expected %s, got %s", test.src, test.node, got)
+		}
+		if got := source(tm.Node()); got != test.src {
+			t.Errorf("%q: expected round trip, got %q", test.src, got)
+		}
+		if tm.Dialect() != token.Lua51 {
+			t.Errorf("%q: expected Lua51, got %v", test.src, tm.Dialect())
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		parse func(string) (*Template, error)
+		src   string
+	}{
+		{ParseExpr, "$a +"},
+		{ParseStmt, "local = $x"},
+		{ParseBlock, "if $c then"},
+	}
+	for _, test := range tests {
+		if tm, err := test.parse(test.src); err == nil {
+			t.Errorf("%q: expected error, got %v", test.src, tm)
+		}
+	}
+}
+
+func TestMustParsePanics(t *testing.T) {
+	tests := []struct {
+		parse func(string) *Template
+		src   string
+	}{
+		{MustParseExpr, "$a +"},
+		{MustParseStmt, "local = $x"},
+		{MustParseBlock, "if $c then"},
+	}
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%q: expected panic", test.src)
+				}
+			}()
+			test.parse(test.src)
+		}()
+	}
+}
+
+func TestConfigDialect(t *testing.T) {
+	config := Config{Dialect: token.Lua54}
+	tm, err := config.ParseStmt("local $x <const> = $y // 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tm.Dialect() != token.Lua54 {
+		t.Errorf("expected Lua54, got %v", tm.Dialect())
+	}
+	if _, err := ParseStmt("local $x <const> = $y // 2"); err == nil {
+		t.Error("expected error with Lua51")
+	}
+}
+
+func TestInstantiate(t *testing.T) {
+	tests := []struct {
+		tmpl *Template
+		b    func(t *testing.T) Bindings
+		want string
+	}{
+		{
+			MustParseStmt("local $name = require($path)"),
+			func(t *testing.T) Bindings {
+				return Bindings{"name": build.Name("json"), "path": build.Str("json")}
+			},
+			`local json = require("json")`,
+		},
+		{
+			MustParseExpr("$a * -$b .. $c.field"),
+			func(t *testing.T) Bindings {
+				return Bindings{"a": expr(t, "x + y"), "b": expr(t, "-z"), "c": expr(t, "'s'")}
+			},
+			"(x + y) * - -z .. ('s').field",
+		},
+		{
+			MustParseExpr("$a + $a"),
+			func(t *testing.T) Bindings {
+				return Bindings{"a": expr(t, "f(1)")}
+			},
+			"f(1) + f(1)",
+		},
+		{
+			MustParseBlock("if $cond then\n\t$body\nend"),
+			func(t *testing.T) Bindings {
+				return Bindings{"cond": expr(t, "x"), "body": block(t, "a()\nb()")}
+			},
+			"if x then\n\ta()\nb()\nend",
+		},
+		{
+			MustParseBlock("$s\nreturn $x"),
+			func(t *testing.T) Bindings {
+				return Bindings{"s": stmt(t, "print(1)"), "x": expr(t, "2")}
+			},
+			"print(1)\nreturn 2",
+		},
+		{
+			MustParseStmt("$s"),
+			func(t *testing.T) Bindings {
+				return Bindings{"s": stmt(t, "do end")}
+			},
+			"do end",
+		},
+	}
+	for _, test := range tests {
+		node, err := test.tmpl.Instantiate(test.b(t))
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", source(test.tmpl.Node()), err)
+			continue
+		}
+		if got := source(node); got != test.want {
+			t.Errorf("%q: expected %q, got %q", source(test.tmpl.Node()), test.want, got)
+		}
+		tree.Walk(validator{t}, node)
+	}
+}
+
+func TestInstantiateDoesNotModify(t *testing.T) {
+	tm := MustParseExpr("$a + 1")
+	a := expr(t, "x")
+	if _, err := tm.Instantiate(Bindings{"a": a}); err != nil {
+		t.Fatal(err)
+	}
+	if got := source(tm.Node()); got != "$a + 1" {
+		t.Errorf("template modified: %q", got)
+	}
+	if got := source(a); got != "x" {
+		t.Errorf("binding modified: %q", got)
+	}
+}
+
+func TestInstantiateErrors(t *testing.T) {
+	tests := []struct {
+		tmpl *Template
+		b    Bindings
+		want string
+	}{
+		{
+			MustParseStmt("local $name = require($path)"),
+			Bindings{"name": build.Name("x")},
+			"metavariable $path: not bound",
+		},
+		{
+			MustParseStmt("local $name = require($path)"),
+			Bindings{"name": build.Str("x"), "path": build.Str("json")},
+			"metavariable $name: name expected",
+		},
+		{
+			MustParseExpr("$a + 1"),
+			Bindings{"a": build.Local([]string{"x"})},
+			"metavariable $a: expression expected",
+		},
+		{
+			MustParseStmt("$s"),
+			Bindings{"s": build.Nil()},
+			"metavariable $s: statement expected",
+		},
+		{
+			MustParseExpr("$a + 1"),
+			Bindings{"a": (*tree.NilExpr)(nil)},
+			"metavariable $a: not bound",
+		},
+	}
+	for _, test := range tests {
+		_, err := test.tmpl.Instantiate(test.b)
+		if err == nil {
+			t.Errorf("%q: expected error", source(test.tmpl.Node()))
+			continue
+		}
+		if got := err.Error(); got != test.want {
+			t.Errorf("%q: expected error %q, got %q", source(test.tmpl.Node()), test.want, got)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		tmpl  *Template
+		parse func(t *testing.T, src string) tree.Node
+		src   string
+		ok    bool
+		b     map[string]string
+	}{
+		{
+			MustParseStmt("local $name = require($path)"),
+			parseStmt, "local  foo = require ( 'foo' ) -- x", true,
+			map[string]string{"name": "foo", "path": "'foo'"},
+		},
+		{
+			MustParseStmt("local $name = require($path)"),
+			parseStmt, "local foo = load('foo')", false, nil,
+		},
+		{
+			MustParseExpr("$x == nil or $x == false"),
+			parseExpr, "a.b == nil or a . b == false", true,
+			map[string]string{"x": "a.b"},
+		},
+		{
+			MustParseExpr("$x == nil or $x == false"),
+			parseExpr, "a == nil or b == false", false, nil,
+		},
+		{
+			MustParseExpr("$x == nil or $x == false"),
+			parseExpr, "a == nil and a == false", false, nil,
+		},
+		{
+			MustParseExpr("$x == nil or $x == false"),
+			parseExpr, "f(x) == nil or f(x)==false", true,
+			map[string]string{"x": "f(x)"},
+		},
+		{
+			MustParseBlock("$s; return $x"),
+			parseBlock, "print(1) return 2", true,
+			map[string]string{"s": "print(1)", "x": "2"},
+		},
+		{
+			MustParseStmt("function $f($a) return $a end"),
+			parseStmt, "function g(q) return q end", true,
+			map[string]string{"f": "g", "a": "q"},
+		},
+		{
+			MustParseStmt("function $f($a) return $a end"),
+			parseStmt, "function g(q) return r end", false, nil,
+		},
+		{
+			MustParseExpr("{$a, $b}"),
+			parseExpr, "{1; 2,}", true,
+			map[string]string{"a": "1", "b": "2"},
+		},
+	}
+	for _, test := range tests {
+		node := test.parse(t, test.src)
+		b, ok := test.tmpl.Match(node)
+		if ok != test.ok {
+			t.Errorf("%q: expected match %t, got %t", test.src, test.ok, ok)
+			continue
+		}
+		if len(b) != len(test.b) {
+			t.Errorf("%q: expected %d bindings, got %d", test.src, len(test.b), len(b))
+		}
+		for name, want := range test.b {
+			if b[name] == nil {
+				t.Errorf("%q: $%s not bound", test.src, name)
+				continue
+			}
+			if got := strings.TrimSpace(source(b[name])); got != want {
+				t.Errorf("%q: expected $%s to be %q, got %q", test.src, name, want, got)
+			}
+		}
+	}
+}
+
+func TestMatchInstantiate(t *testing.T) {
+	tm := MustParseStmt("local $name = require($path)")
+	b, ok := tm.Match(stmt(t, "local foo = require('foo')"))
+	if !ok {
+		t.Fatal("expected match")
+	}
+	node, err := tm.Instantiate(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := source(node); got != "local foo = require('foo')" {
+		t.Errorf("unexpected result %q", got)
+	}
+}
+
+func parseExpr(t *testing.T, src string) tree.Node  { return expr(t, src) }
+func parseStmt(t *testing.T, src string) tree.Node  { return stmt(t, src) }
+func parseBlock(t *testing.T, src string) tree.Node { return block(t, src) }
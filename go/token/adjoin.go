@@ -2,7 +2,16 @@ package token
 
 // AdjoinSeparator returns the character that would allow a token of type 'left'
 // to precede a token of type 'right', if placed as a SPACE token between the
-// two. Currently, this is '\n' for COMMENTs, and a space for everything else.
+// two, when scanned as Lua 5.1. See AdjoinSeparatorDialect for details.
+func (left Type) AdjoinSeparator(right Type) rune {
+	return left.AdjoinSeparatorDialect(right, Lua51)
+}
+
+// AdjoinSeparatorDialect returns the character that would allow a token of
+// type 'left' to precede a token of type 'right', if placed as a SPACE token
+// between the two. Currently, this is '\n' for COMMENTs, and a space for
+// everything else. The dialect d determines which combinations of characters
+// would be scanned as a single token.
 //
 // Returns -1 if the two tokens are allowed to be adjacent. Note that this is
 // returned by tokens that will never be adjacent while being syntactically
@@ -10,7 +19,7 @@ package token
 //
 // Returns -2 if the allowed adjacency of the two tokens depends on the content
 // of the tokens.
-func (left Type) AdjoinSeparator(right Type) rune {
+func (left Type) AdjoinSeparatorDialect(right Type, d Dialect) rune {
 	// Tokens must be separated by a space (at minimum).
 	const space = ' '
 	// Tokens must be separated by a newline.
@@ -31,6 +40,12 @@ func (left Type) AdjoinSeparator(right Type) rune {
 		// Once again, no character can make these correct, so they're allowed.
 		// Undefined types also need to be detected.
 		return okay
+	case left == SHEBANG:
+		if right > comm_start {
+			// Must return newline so that right token isn't skipped along
+			// with the line.
+			return newline
+		}
 	case left == COMMENT:
 		if right > comm_start {
 			// Must return newline so that right token isn't turned into a
@@ -53,20 +68,84 @@ func (left Type) AdjoinSeparator(right Type) rune {
 			right > key_start:
 			return space
 		}
-	case left == ASSIGN,
-		left == LT,
-		left == GT:
+	case left == ASSIGN:
+		switch {
+		case right == ASSIGN,
+			right == EQ:
+			return space
+		}
+	case left == LT:
 		switch {
 		case right == ASSIGN,
 			right == EQ:
 			return space
+		case right == LT,
+			right == LEQ,
+			right == SHL:
+			if d.Has(Bitwise) {
+				return space
+			}
+		}
+	case left == GT:
+		switch {
+		case right == ASSIGN,
+			right == EQ:
+			return space
+		case right == GT,
+			right == GEQ,
+			right == SHR:
+			if d.Has(Bitwise) {
+				return space
+			}
+		}
+	case left == SLASH:
+		switch {
+		case right == SLASH,
+			right == DSLASH,
+			right == DIVASSIGN,
+			right == IDIVASSIGN:
+			if d.Has(IntDiv) {
+				return space
+			}
+		case right == ASSIGN,
+			right == EQ:
+			if d.Has(CompoundAssign) {
+				return space
+			}
+		}
+	case left == PLUS,
+		left == ASTERISK,
+		left == DSLASH,
+		left == PERCENT,
+		left == CARET:
+		switch {
+		case right == ASSIGN,
+			right == EQ:
+			if d.Has(CompoundAssign) {
+				return space
+			}
+		}
+	case left == TILDE:
+		switch {
+		case right == ASSIGN,
+			right == EQ:
+			return space
+		}
+	case left == COLON:
+		switch {
+		case right == COLON,
+			right == DBCOLON:
+			if d.Has(Goto) || d.Has(Types) {
+				return space
+			}
 		}
 	case left == DOT:
 		switch {
 		case right.IsNumber(),
 			right == DOT,
 			right == VARARG,
-			right == CONCAT:
+			right == CONCAT,
+			right == CATASSIGN:
 			return space
 		}
 	case left == LBRACK:
@@ -77,11 +156,16 @@ func (left Type) AdjoinSeparator(right Type) rune {
 		}
 	case left == CONCAT:
 		switch {
-		case right == NUMBERFLOAT:
+		case right.IsNumber():
 			// Insert space only if number begins with '.' character.
 			return cond
 		case right == DOT:
 			return space
+		case right == ASSIGN,
+			right == EQ:
+			if d.Has(CompoundAssign) {
+				return space
+			}
 		}
 	case left == EQ,
 		left == NEQ:
@@ -91,7 +175,25 @@ func (left Type) AdjoinSeparator(right Type) rune {
 	case left == MINUS:
 		switch {
 		case right.IsComment(),
-			right == MINUS:
+			right == MINUS,
+			right == SUBASSIGN,
+			right == ARROW:
+			return space
+		case right == GT,
+			right == GEQ:
+			if d.Has(Types) {
+				return space
+			}
+		case right == ASSIGN,
+			right == EQ:
+			if d.Has(CompoundAssign) {
+				return space
+			}
+		}
+	case left == INTERPBEGIN,
+		left == INTERPMID:
+		if right == LBRACE {
+			// A doubled brace is not permitted within an interpolated string.
 			return space
 		}
 	case left > key_start:
@@ -99,7 +201,7 @@ func (left Type) AdjoinSeparator(right Type) rune {
 		case right == NAME,
 			right > key_start:
 			return space
-		case right == NUMBERFLOAT:
+		case right.IsNumber():
 			if left < ekey_end {
 				// Insert space only if number does not begin with '.'
 				// character.
@@ -0,0 +1,73 @@
+package token
+
+import (
+	"testing"
+)
+
+func TestAdjoinSeparator(t *testing.T) {
+	tests := []struct {
+		left, right Type
+		dialect     Dialect
+		want        rune
+	}{
+		{NAME, NAME, Lua51, ' '},
+		{NAME, LPAREN, Lua51, -1},
+		{MINUS, MINUS, Lua51, ' '},
+		{COMMENT, NAME, Lua51, '\n'},
+		{CONCAT, NUMBERFLOAT, Lua51, -2},
+		{ASSIGN, ASSIGN, Lua51, ' '},
+		{SLASH, SLASH, Lua51, -1},
+		{SLASH, SLASH, Lua53, ' '},
+		{SLASH, DSLASH, Lua53, ' '},
+		{LT, LT, Lua53, ' '},
+		{LT, SHL, Lua53, ' '},
+		{GT, GT, Lua53, ' '},
+		{GT, SHR, Lua53, ' '},
+		{TILDE, ASSIGN, Lua53, ' '},
+		{TILDE, EQ, Lua53, ' '},
+		{TILDE, TILDE, Lua53, -1},
+		{AMPERSAND, PIPE, Lua53, -1},
+	}
+	for _, test := range tests {
+		if got := test.left.AdjoinSeparatorDialect(test.right, test.dialect); got != test.want {
+			t.Errorf("%s %s in %s: expected %q, got %q", test.left, test.right, test.dialect, test.want, got)
+		}
+		if test.dialect == Lua51 {
+			if got := test.left.AdjoinSeparator(test.right); got != test.want {
+				t.Errorf("%s %s: expected %q, got %q", test.left, test.right, test.want, got)
+			}
+		}
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	// Each operator binds more tightly than the next.
+	order := [][]Type{
+		{OR},
+		{AND},
+		{LT, GT, LEQ, GEQ, NEQ, EQ},
+		{PIPE},
+		{TILDE},
+		{AMPERSAND},
+		{SHL, SHR},
+		{CONCAT},
+		{PLUS, MINUS},
+		{ASTERISK, SLASH, DSLASH, PERCENT},
+	}
+	prev := 0
+	for _, group := range order {
+		p := group[0].Precedence()
+		for _, op := range group {
+			if op.Precedence() != p {
+				t.Errorf("%s: expected precedence %v, got %v", op, p, op.Precedence())
+			}
+		}
+		if p[0] <= prev {
+			t.Errorf("%s: expected precedence greater than %d, got %v", group[0], prev, p)
+		}
+		prev = p[0]
+	}
+	if UnaryPrecedence <= prev || CARET.Precedence()[1] <= UnaryPrecedence {
+		t.Errorf("unexpected unary precedence %d", UnaryPrecedence)
+	}
+}
@@ -0,0 +1,162 @@
+package token
+
+// Dialect indicates a version or variant of the Lua language. The zero value
+// is Lua51.
+type Dialect uint8
+
+const (
+	Lua51  Dialect = iota // Lua 5.1
+	Lua52                 // Lua 5.2
+	Lua53                 // Lua 5.3
+	Lua54                 // Lua 5.4
+	LuaJIT                // LuaJIT 2.1
+	Luau                  // Luau
+	dialect_end
+)
+
+var dialects = [...]string{
+	Lua51:  "Lua 5.1",
+	Lua52:  "Lua 5.2",
+	Lua53:  "Lua 5.3",
+	Lua54:  "Lua 5.4",
+	LuaJIT: "LuaJIT",
+	Luau:   "Luau",
+}
+
+// String returns a string representation of the dialect.
+func (d Dialect) String() string {
+	if d < dialect_end {
+		return dialects[d]
+	}
+	return "<invalid>"
+}
+
+// IsValid returns whether the dialect is valid.
+func (d Dialect) IsValid() bool {
+	return d < dialect_end
+}
+
+// Feature is a set of flags indicating syntax or semantics that differ between
+// dialects.
+type Feature uint32
+
+const (
+	// Goto indicates the `goto` keyword, and the `::` operator used to declare
+	// labels.
+	Goto Feature = 1 << iota
+	// Env indicates that free names are resolved through the _ENV upvalue
+	// rather than a global table.
+	Env
+	// LooseBreak indicates that a `break` statement is not required to be the
+	// last statement of a block.
+	LooseBreak
+	// IntDiv indicates the `//` floor division operator.
+	IntDiv
+	// Bitwise indicates the `&`, `|`, `~`, `<<` and `>>` bitwise operators.
+	Bitwise
+	// Attribs indicates `<const>` and `<close>` attributes on local variables.
+	Attribs
+	// HexFloat indicates hexadecimal numbers with a fractional part or a
+	// binary exponent, such as `0x1.8p3`.
+	HexFloat
+	// BinaryNumber indicates binary numbers, such as `0b101`.
+	BinaryNumber
+	// DigitSeparator indicates that the digits of a number may be separated by
+	// `_` characters, such as `1_000`.
+	DigitSeparator
+	// HexEscape indicates the `\xXX` string escape sequence.
+	HexEscape
+	// SkipEscape indicates the `\z` string escape sequence, which skips
+	// subsequent whitespace.
+	SkipEscape
+	// UnicodeEscape indicates the `\u{XXX}` string escape sequence.
+	UnicodeEscape
+	// LongUTF8 indicates that the `\u{XXX}` escape sequence may encode values
+	// up to 2^31 rather than being limited to 10FFFF.
+	LongUTF8
+	// StrictEscape indicates that an unrecognized string escape sequence is an
+	// error, rather than producing the escaped character.
+	StrictEscape
+	// Types indicates type annotations and `type` declarations, along with
+	// the `::` type assertion operator.
+	Types
+	// CompoundAssign indicates compound assignment operators, such as `+=`.
+	CompoundAssign
+	// Continue indicates the `continue` statement. Because `continue` is not
+	// a reserved word, it is recognized only where it cannot be a name.
+	Continue
+	// IfExpr indicates `if .. then .. else` expressions.
+	IfExpr
+	// Interp indicates backtick-quoted interpolated strings.
+	Interp
+	// Integers indicates an integer subtype of numbers. A number without a
+	// fractional part or exponent is an integer, and hexadecimal integers wrap
+	// around on overflow.
+	Integers
+	// NumberSuffix indicates the `LL` and `ULL` suffixes of 64-bit integer
+	// numbers, and the `i` suffix of imaginary numbers.
+	NumberSuffix
+)
+
+const (
+	lua52 = Goto | Env | LooseBreak | HexFloat | HexEscape | SkipEscape | StrictEscape
+	lua53 = lua52 | IntDiv | Bitwise | UnicodeEscape | Integers
+	lua54 = lua53 | Attribs | LongUTF8
+
+	luajit = Goto | HexFloat | BinaryNumber | HexEscape | SkipEscape | UnicodeEscape | StrictEscape |
+		NumberSuffix
+	luau = IntDiv | BinaryNumber | DigitSeparator | HexEscape | SkipEscape | UnicodeEscape | StrictEscape |
+		Types | CompoundAssign | Continue | IfExpr | Interp
+)
+
+var features = [...]Feature{
+	Lua51:  0,
+	Lua52:  lua52,
+	Lua53:  lua53,
+	Lua54:  lua54,
+	LuaJIT: luajit,
+	Luau:   luau,
+}
+
+// Has returns whether the dialect has all of the given features.
+func (d Dialect) Has(f Feature) bool {
+	if d >= dialect_end {
+		return false
+	}
+	return features[d]&f == f
+}
+
+// Feature returns the features that allow a token type to be produced by the
+// scanner, any one of which is sufficient. Returns 0 if the type is available
+// in every dialect.
+func (t Type) Feature() Feature {
+	switch t {
+	case GOTO:
+		return Goto
+	case DBCOLON:
+		return Goto | Types
+	case DSLASH:
+		return IntDiv
+	case TILDE, SHL, SHR:
+		return Bitwise
+	case AMPERSAND, PIPE:
+		return Bitwise | Types
+	case ARROW, QUESTION:
+		return Types
+	case INTERPSTRING, INTERPBEGIN, INTERPMID, INTERPEND:
+		return Interp
+	}
+	if t.IsCompoundAssign() {
+		return CompoundAssign
+	}
+	return 0
+}
+
+// InDialect returns whether the type is available within the given dialect.
+func (t Type) InDialect(d Dialect) bool {
+	f := t.Feature()
+	if f == 0 {
+		return d.IsValid()
+	}
+	return d.IsValid() && features[d]&f != 0
+}
@@ -0,0 +1,106 @@
+package token
+
+import (
+	"testing"
+)
+
+func TestDialectHas(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		feature Feature
+		want    bool
+	}{
+		{Lua51, Goto, false},
+		{Lua52, Goto | Env, true},
+		{Lua52, IntDiv, false},
+		{Lua53, IntDiv | Bitwise | Integers, true},
+		{Lua53, Attribs, false},
+		{Lua54, Attribs | LongUTF8, true},
+		{LuaJIT, Goto | NumberSuffix, true},
+		{LuaJIT, Env, false},
+		{Luau, Types | CompoundAssign | Continue | IfExpr | Interp, true},
+		{Luau, Goto, false},
+		{Luau, Types | Goto, false},
+		{dialect_end, 0, false},
+	}
+	for _, test := range tests {
+		if got := test.dialect.Has(test.feature); got != test.want {
+			t.Errorf("%s has %b: expected %t, got %t", test.dialect, test.feature, test.want, got)
+		}
+	}
+}
+
+func TestDialectString(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		want    string
+		valid   bool
+	}{
+		{Lua51, "Lua 5.1", true},
+		{Lua54, "Lua 5.4", true},
+		{LuaJIT, "LuaJIT", true},
+		{Luau, "Luau", true},
+		{dialect_end, "<invalid>", false},
+	}
+	for _, test := range tests {
+		if got := test.dialect.String(); got != test.want {
+			t.Errorf("expected %q, got %q", test.want, got)
+		}
+		if got := test.dialect.IsValid(); got != test.valid {
+			t.Errorf("%s: expected valid %t, got %t", test.want, test.valid, got)
+		}
+	}
+}
+
+func TestInDialect(t *testing.T) {
+	tests := []struct {
+		typ     Type
+		dialect Dialect
+		want    bool
+	}{
+		{PLUS, Lua51, true},
+		{PLUS, dialect_end, false},
+		{GOTO, Lua51, false},
+		{GOTO, Lua52, true},
+		{GOTO, Luau, false},
+		{DBCOLON, Lua52, true},
+		{DBCOLON, Luau, true},
+		{DBCOLON, Lua51, false},
+		{PIPE, Lua53, true},
+		{PIPE, Luau, true},
+		{TILDE, Luau, false},
+		{DSLASH, Luau, true},
+		{ARROW, Lua54, false},
+	}
+	for _, test := range tests {
+		if got := test.typ.InDialect(test.dialect); got != test.want {
+			t.Errorf("%s in %s: expected %t, got %t", test.typ, test.dialect, test.want, got)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		want    Type
+	}{
+		{"and", Lua51, AND},
+		{"foo", Lua51, NAME},
+		{"goto", Lua51, NAME},
+		{"goto", Lua52, GOTO},
+		{"goto", LuaJIT, GOTO},
+		{"goto", Luau, NAME},
+		{"continue", Luau, NAME},
+	}
+	for _, test := range tests {
+		if got := LookupDialect(test.name, test.dialect); got != test.want {
+			t.Errorf("%q in %s: expected %s, got %s", test.name, test.dialect, test.want, got)
+		}
+		if test.dialect == Lua51 {
+			if got := Lookup(test.name); got != test.want {
+				t.Errorf("%q: expected %s, got %s", test.name, test.want, got)
+			}
+		}
+	}
+}
@@ -1,8 +1,11 @@
 package token
 
 import (
+	"errors"
+	"sort"
 	"strconv"
 	"sync"
+	"unicode/utf8"
 )
 
 // A Position describes a position with a file, including the name of the file,
@@ -37,17 +40,37 @@ func (pos Position) String() string {
 	return s
 }
 
+// Pos is a compact representation of a position within a FileSet. The Pos of
+// an offset within a file is the base of the file plus the offset. Pos values
+// can be compared to determine the order of positions across every file in a
+// FileSet.
+type Pos int
+
+// NoPos is the zero value of Pos, which is not a position within any file.
+const NoPos Pos = 0
+
+// IsValid returns whether the position is not NoPos.
+func (p Pos) IsValid() bool {
+	return p != NoPos
+}
+
 // A File represents a Lua source file. Methods are safe to use concurrently.
 type File struct {
-	name  string
-	mutex sync.Mutex
-	lines []int
+	name    string
+	base    int
+	size    int
+	mutex   sync.Mutex
+	lines   []int
+	content []byte
 }
 
-// NewFile creates a new file with the given name.
+// NewFile creates a new file with the given name. The file is not a part of
+// any FileSet, and has a base of 1 and an unknown size.
 func NewFile(filename string) *File {
 	return &File{
 		name:  filename,
+		base:  1,
+		size:  -1,
 		lines: []int{0},
 	}
 }
@@ -57,6 +80,34 @@ func (f *File) Name() string {
 	return f.name
 }
 
+// Base returns the Pos of the start of the file.
+func (f *File) Base() int {
+	return f.base
+}
+
+// Size returns the size of the file, as specified when the file was added to
+// a FileSet. Returns -1 if the size is unknown.
+func (f *File) Size() int {
+	return f.size
+}
+
+// Pos returns the Pos value for a given offset within the file.
+func (f *File) Pos(offset int) Pos {
+	return Pos(f.base + offset)
+}
+
+// PosOffset returns the offset within the file of a given Pos value. The
+// result is meaningful only if p is a position within the file.
+func (f *File) PosOffset(p Pos) int {
+	return int(p) - f.base
+}
+
+// contains returns whether p is a position within the file. A position at the
+// end of the file is included.
+func (f *File) contains(p Pos) bool {
+	return f.base <= int(p) && (f.size < 0 || int(p) <= f.base+f.size)
+}
+
 // Returns the number of lines in the file.
 func (f *File) LineCount() (c int) {
 	f.mutex.Lock()
@@ -93,6 +144,24 @@ func (f *File) SetLinesForContent(content []byte) {
 	f.mutex.Unlock()
 }
 
+// SetContent sets the source content of the file, which is used to determine
+// columns in units other than bytes. The content is not copied, and must not
+// be modified afterwards.
+func (f *File) SetContent(content []byte) {
+	f.mutex.Lock()
+	f.content = content
+	f.mutex.Unlock()
+}
+
+// Content returns the source content of the file, or nil if the content has
+// not been set.
+func (f *File) Content() (content []byte) {
+	f.mutex.Lock()
+	content = f.content
+	f.mutex.Unlock()
+	return content
+}
+
 // ClearLines resets the line offsets for a file.
 func (f *File) ClearLines() {
 	f.mutex.Lock()
@@ -127,3 +196,274 @@ func (f *File) Position(offset int) (pos Position) {
 	f.mutex.Unlock()
 	return pos
 }
+
+// A ColumnUnit is a unit in which the column of a position is measured.
+type ColumnUnit int
+
+const (
+	ByteColumn  ColumnUnit = iota // Column is the number of bytes.
+	RuneColumn                    // Column is the number of Unicode code points.
+	UTF16Column                   // Column is the number of UTF-16 code units.
+)
+
+// width returns the number of units occupied by the encoded rune at the start
+// of b, along with the number of bytes in the rune. An invalid encoding is
+// treated as a single byte that occupies one unit.
+func (u ColumnUnit) width(b []byte) (w, n int) {
+	switch u {
+	case RuneColumn:
+		_, n = utf8.DecodeRune(b)
+		return 1, n
+	case UTF16Column:
+		r, n := utf8.DecodeRune(b)
+		if r >= 0x10000 {
+			return 2, n
+		}
+		return 1, n
+	}
+	return 1, 1
+}
+
+// lineBounds returns the offsets of the start and end of a line, starting at
+// 1. The end excludes the newline that terminates the line. Must be called
+// while locked.
+func (f *File) lineBounds(line int) (start, end int, ok bool) {
+	if line < 1 || line > len(f.lines) {
+		return 0, 0, false
+	}
+	start = f.lines[line-1]
+	if line < len(f.lines) {
+		end = f.lines[line] - 1
+	} else {
+		end = len(f.content)
+		if end > start && f.content[end-1] == '\n' {
+			end--
+		}
+	}
+	return start, end, true
+}
+
+// PositionIn returns the Position value for a given offset within the file,
+// with the column measured in the given unit. Columns other than bytes
+// require the content of the file to be set; otherwise, the column is measured
+// in bytes.
+func (f *File) PositionIn(offset int, unit ColumnUnit) (pos Position) {
+	pos = f.Position(offset)
+	if !pos.IsValid() || unit == ByteColumn {
+		return pos
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.content == nil {
+		return pos
+	}
+	start := f.lines[pos.Line-1]
+	if offset > len(f.content) {
+		offset = len(f.content)
+	}
+	b := f.content[start:offset]
+	pos.Column = 1
+	for len(b) > 0 {
+		w, n := unit.width(b)
+		pos.Column += w
+		b = b[n:]
+	}
+	return pos
+}
+
+// PositionRune returns the Position value for a given offset within the file,
+// with the column measured in Unicode code points.
+func (f *File) PositionRune(offset int) Position {
+	return f.PositionIn(offset, RuneColumn)
+}
+
+// PositionUTF16 returns the Position value for a given offset within the
+// file, with the column measured in UTF-16 code units.
+func (f *File) PositionUTF16(offset int) Position {
+	return f.PositionIn(offset, UTF16Column)
+}
+
+// OffsetIn returns the offset within the file of a given line and column,
+// both starting at 1, with the column measured in the given unit. The column
+// may refer to the end of the line, just after its last character.
+//
+// An error is returned if the line or column is out of range, or if the
+// column refers to the middle of a character. Columns other than bytes
+// require the content of the file to be set.
+func (f *File) OffsetIn(line, column int, unit ColumnUnit) (offset int, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if unit != ByteColumn && f.content == nil {
+		return 0, errors.New("file content not set")
+	}
+	start, end, ok := f.lineBounds(line)
+	if !ok {
+		return 0, errors.New("line " + strconv.Itoa(line) + " out of range")
+	}
+	if column < 1 {
+		return 0, errors.New("column " + strconv.Itoa(column) + " out of range")
+	}
+	if unit == ByteColumn {
+		if f.content == nil && line == len(f.lines) {
+			// The end of the last line is not known.
+			end = start + column - 1
+		}
+		if offset = start + column - 1; offset > end {
+			return 0, errors.New("column " + strconv.Itoa(column) + " out of range")
+		}
+		return offset, nil
+	}
+	offset = start
+	for c := 1; c < column; {
+		if offset >= end {
+			return 0, errors.New("column " + strconv.Itoa(column) + " out of range")
+		}
+		w, n := unit.width(f.content[offset:end])
+		if c += w; c > column {
+			return 0, errors.New("column " + strconv.Itoa(column) + " within character")
+		}
+		offset += n
+	}
+	return offset, nil
+}
+
+// Offset returns the offset within the file of a given line and column, both
+// starting at 1, with the column measured in bytes. The column may refer to the
+// end of the line, just after its last character. An error is returned if the
+// line or column is out of range.
+func (f *File) Offset(line, column int) (int, error) {
+	return f.OffsetIn(line, column, ByteColumn)
+}
+
+// LineStart returns the offset within the file of the start of a line,
+// starting at 1. An error is returned if the line is out of range.
+func (f *File) LineStart(line int) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if line < 1 || line > len(f.lines) {
+		return 0, errors.New("line " + strconv.Itoa(line) + " out of range")
+	}
+	return f.lines[line-1], nil
+}
+
+// LineContent returns the content of a line, starting at 1. The result
+// excludes the newline that terminates the line, including a preceding
+// carriage return. An error is returned if the line is out of range, or if the
+// content of the file has not been set. The result must not be modified.
+func (f *File) LineContent(line int) ([]byte, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.content == nil {
+		return nil, errors.New("file content not set")
+	}
+	start, end, ok := f.lineBounds(line)
+	if !ok || end > len(f.content) {
+		return nil, errors.New("line " + strconv.Itoa(line) + " out of range")
+	}
+	b := f.content[start:end]
+	if n := len(b); n > 0 && b[n-1] == '\r' {
+		b = b[:n-1]
+	}
+	return b[:len(b):len(b)], nil
+}
+
+// OffsetRune returns the offset within the file of a given line and column,
+// with the column measured in Unicode code points.
+func (f *File) OffsetRune(line, column int) (int, error) {
+	return f.OffsetIn(line, column, RuneColumn)
+}
+
+// OffsetUTF16 returns the offset within the file of a given line and column,
+// with the column measured in UTF-16 code units.
+func (f *File) OffsetUTF16(line, column int) (int, error) {
+	return f.OffsetIn(line, column, UTF16Column)
+}
+
+// A FileSet is a set of files, each of which are assigned a unique range of
+// Pos values. Methods are safe to use concurrently.
+type FileSet struct {
+	mutex sync.RWMutex
+	base  int
+	files []*File
+}
+
+// NewFileSet creates a new, empty file set.
+func NewFileSet() *FileSet {
+	return &FileSet{base: 1}
+}
+
+// Base returns the minimum base that can be used by the next added file.
+func (s *FileSet) Base() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.base
+}
+
+// AddFile creates a new file with the given name, base, and size, and adds it
+// to the set. If base is less than zero, then the result of Base is used. The
+// file occupies the Pos values from base to base+size, inclusive.
+//
+// AddFile panics if base is less than the result of Base, or if size is less
+// than zero.
+func (s *FileSet) AddFile(filename string, base, size int) *File {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if base < 0 {
+		base = s.base
+	}
+	if base < s.base {
+		panic("base too small")
+	}
+	if size < 0 {
+		panic("negative size")
+	}
+	f := &File{
+		name:  filename,
+		base:  base,
+		size:  size,
+		lines: []int{0},
+	}
+	// Leave room for a position at the end of the file.
+	s.base = base + size + 1
+	s.files = append(s.files, f)
+	return f
+}
+
+// File returns the file containing p, or nil if no such file exists.
+func (s *FileSet) File(p Pos) *File {
+	if !p.IsValid() {
+		return nil
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	i := sort.Search(len(s.files), func(i int) bool {
+		return s.files[i].base > int(p)
+	}) - 1
+	if i >= 0 && s.files[i].contains(p) {
+		return s.files[i]
+	}
+	return nil
+}
+
+// Position returns the Position value of p. The result is invalid if p is not
+// within any file of the set.
+func (s *FileSet) Position(p Pos) (pos Position) {
+	if f := s.File(p); f != nil {
+		return f.Position(f.PosOffset(p))
+	}
+	return pos
+}
+
+// Iterate calls fn for each file in the set, in the order they were added,
+// until fn returns false.
+func (s *FileSet) Iterate(fn func(*File) bool) {
+	s.mutex.RLock()
+	files := make([]*File, len(s.files))
+	copy(files, s.files)
+	s.mutex.RUnlock()
+	for _, f := range files {
+		if !fn(f) {
+			break
+		}
+	}
+}
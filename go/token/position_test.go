@@ -0,0 +1,273 @@
+package token
+
+import (
+	"testing"
+)
+
+func newFile(src string) *File {
+	f := NewFile("")
+	f.SetLinesForContent([]byte(src))
+	f.SetContent([]byte(src))
+	return f
+}
+
+const unicodeSrc = "x = 'é€𝄞' y\nz = \"𝄞\"\n"
+
+func TestPositionIn(t *testing.T) {
+	tests := []struct {
+		offset int
+		bytes  string
+		runes  string
+		utf16  string
+	}{
+		{0, "1:1", "1:1", "1:1"},
+		{5, "1:6", "1:6", "1:6"},
+		{7, "1:8", "1:7", "1:7"},
+		{10, "1:11", "1:8", "1:8"},
+		{14, "1:15", "1:9", "1:10"},
+		{16, "1:17", "1:11", "1:12"},
+		{17, "1:18", "1:12", "1:13"},
+		{18, "2:1", "2:1", "2:1"},
+		{23, "2:6", "2:6", "2:6"},
+		{27, "2:10", "2:7", "2:8"},
+	}
+	f := newFile(unicodeSrc)
+	for _, test := range tests {
+		if got := f.Position(test.offset).String(); got != ":"+test.bytes {
+			t.Errorf("%d: expected byte position %s, got %s", test.offset, test.bytes, got)
+		}
+		if got := f.PositionRune(test.offset).String(); got != ":"+test.runes {
+			t.Errorf("%d: expected rune position %s, got %s", test.offset, test.runes, got)
+		}
+		if got := f.PositionUTF16(test.offset).String(); got != ":"+test.utf16 {
+			t.Errorf("%d: expected UTF-16 position %s, got %s", test.offset, test.utf16, got)
+		}
+	}
+}
+
+func TestPositionInWithoutContent(t *testing.T) {
+	f := NewFile("")
+	f.SetLinesForContent([]byte(unicodeSrc))
+	for _, unit := range []ColumnUnit{RuneColumn, UTF16Column} {
+		if pos := f.PositionIn(10, unit); pos.Column != 11 {
+			t.Errorf("unit %d: expected byte column 11, got %d", unit, pos.Column)
+		}
+	}
+}
+
+func TestOffsetIn(t *testing.T) {
+	f := newFile(unicodeSrc)
+	// Each column converts back to the offset it was derived from.
+	for offset := 0; offset < len(unicodeSrc); offset++ {
+		for _, unit := range []ColumnUnit{ByteColumn, RuneColumn, UTF16Column} {
+			pos := f.PositionIn(offset, unit)
+			got, err := f.OffsetIn(pos.Line, pos.Column, unit)
+			if err != nil {
+				// Positions within a character have no column of their own.
+				if unit == ByteColumn {
+					t.Errorf("%d unit %d: unexpected error %s", offset, unit, err)
+				}
+				continue
+			}
+			if unit == ByteColumn && got != offset {
+				t.Errorf("%d unit %d: expected offset %d, got %d", offset, unit, offset, got)
+			}
+			if back := f.PositionIn(got, unit); back.Line != pos.Line || back.Column != pos.Column {
+				t.Errorf("%d unit %d: expected position %s, got %s", offset, unit, pos, back)
+			}
+		}
+	}
+
+	tests := []struct {
+		line, column int
+		unit         ColumnUnit
+		offset       int
+		ok           bool
+	}{
+		{1, 8, RuneColumn, 10, true},
+		{1, 9, RuneColumn, 14, true},
+		{1, 9, UTF16Column, 0, false},
+		{1, 10, UTF16Column, 14, true},
+		{1, 13, UTF16Column, 17, true},
+		{2, 7, RuneColumn, 27, true},
+		{2, 8, UTF16Column, 27, true},
+		{2, 9, RuneColumn, 0, false},
+		{3, 1, RuneColumn, 0, false},
+		{1, 0, UTF16Column, 0, false},
+	}
+	for _, test := range tests {
+		offset, err := f.OffsetIn(test.line, test.column, test.unit)
+		if test.ok != (err == nil) {
+			t.Errorf("%d:%d unit %d: expected ok=%t, got error %v", test.line, test.column, test.unit, test.ok, err)
+			continue
+		}
+		if test.ok && offset != test.offset {
+			t.Errorf("%d:%d unit %d: expected offset %d, got %d", test.line, test.column, test.unit, test.offset, offset)
+		}
+	}
+
+	if _, err := f.OffsetRune(1, 1); err != nil {
+		t.Errorf("OffsetRune: unexpected error %s", err)
+	}
+	if _, err := f.OffsetUTF16(1, 1); err != nil {
+		t.Errorf("OffsetUTF16: unexpected error %s", err)
+	}
+	if _, err := NewFile("").OffsetRune(1, 1); err == nil {
+		t.Error("expected error for file without content")
+	}
+}
+
+func TestFileSet(t *testing.T) {
+	s := NewFileSet()
+	if base := s.Base(); base != 1 {
+		t.Errorf("expected base 1, got %d", base)
+	}
+	a := s.AddFile("a.lua", -1, 11)
+	a.SetLinesForContent([]byte("x = 1\ny = 2"))
+	b := s.AddFile("b.lua", 20, 13)
+	b.SetLinesForContent([]byte("local z\nz = 3"))
+	if base := s.Base(); base != 34 {
+		t.Errorf("expected base 34, got %d", base)
+	}
+
+	tests := []struct {
+		p    Pos
+		want string
+	}{
+		{NoPos, "-"},
+		{a.Pos(0), "a.lua:1:1"},
+		{a.Pos(8), "a.lua:2:3"},
+		{a.Pos(11), "a.lua:2:6"},
+		{13, "-"},
+		{b.Pos(0), "b.lua:1:1"},
+		{b.Pos(8), "b.lua:2:1"},
+		{b.Pos(13), "b.lua:2:6"},
+		{b.Pos(14), "-"},
+		{1000, "-"},
+	}
+	for _, test := range tests {
+		if got := s.Position(test.p).String(); got != test.want {
+			t.Errorf("%d: expected %s, got %s", test.p, test.want, got)
+		}
+	}
+	if f := s.File(b.Pos(3)); f != b {
+		t.Errorf("expected file b.lua, got %v", f)
+	}
+	if b.PosOffset(b.Pos(5)) != 5 {
+		t.Errorf("expected offset 5, got %d", b.PosOffset(b.Pos(5)))
+	}
+	if a.Pos(11) >= b.Pos(0) {
+		t.Error("expected positions of a.lua to precede positions of b.lua")
+	}
+
+	var names []string
+	s.Iterate(func(f *File) bool {
+		names = append(names, f.Name())
+		return true
+	})
+	if len(names) != 2 || names[0] != "a.lua" || names[1] != "b.lua" {
+		t.Errorf("expected files [a.lua b.lua], got %v", names)
+	}
+}
+
+func TestFileSetAddFilePanics(t *testing.T) {
+	tests := []struct {
+		base, size int
+	}{
+		{5, 0},
+		{-1, -1},
+	}
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("base %d size %d: expected panic", test.base, test.size)
+				}
+			}()
+			s := NewFileSet()
+			s.AddFile("a.lua", 10, 0)
+			s.AddFile("b.lua", test.base, test.size)
+		}()
+	}
+}
+
+const linesSrc = "local a\r\nlocal b\n\nreturn a"
+
+func TestLineContent(t *testing.T) {
+	f := newFile(linesSrc)
+	tests := []struct {
+		line  int
+		start int
+		text  string
+		ok    bool
+	}{
+		{0, 0, "", false},
+		{1, 0, "local a", true},
+		{2, 9, "local b", true},
+		{3, 17, "", true},
+		{4, 18, "return a", true},
+		{5, 0, "", false},
+	}
+	for _, test := range tests {
+		start, err := f.LineStart(test.line)
+		if test.ok != (err == nil) {
+			t.Errorf("line %d: expected ok=%t, got error %v", test.line, test.ok, err)
+		} else if test.ok && start != test.start {
+			t.Errorf("line %d: expected start %d, got %d", test.line, test.start, start)
+		}
+		text, err := f.LineContent(test.line)
+		if test.ok != (err == nil) {
+			t.Errorf("line %d: expected ok=%t, got error %v", test.line, test.ok, err)
+		} else if string(text) != test.text {
+			t.Errorf("line %d: expected %q, got %q", test.line, test.text, text)
+		}
+	}
+
+	f = NewFile("")
+	f.SetLinesForContent([]byte(linesSrc))
+	if _, err := f.LineContent(1); err == nil {
+		t.Error("expected error for file without content")
+	}
+	if start, err := f.LineStart(2); err != nil || start != 9 {
+		t.Errorf("expected start 9, got %d (%v)", start, err)
+	}
+}
+
+func TestOffset(t *testing.T) {
+	f := newFile(linesSrc)
+	tests := []struct {
+		line, column int
+		offset       int
+		ok           bool
+	}{
+		{1, 1, 0, true},
+		{1, 8, 7, true},
+		{1, 9, 8, true},
+		{1, 10, 0, false},
+		{2, 1, 9, true},
+		{3, 1, 17, true},
+		{3, 2, 0, false},
+		{4, 8, 25, true},
+		{4, 9, 26, true},
+		{4, 10, 0, false},
+		{0, 1, 0, false},
+		{5, 1, 0, false},
+		{1, 0, 0, false},
+	}
+	for _, test := range tests {
+		offset, err := f.Offset(test.line, test.column)
+		if test.ok != (err == nil) {
+			t.Errorf("%d:%d: expected ok=%t, got error %v", test.line, test.column, test.ok, err)
+			continue
+		}
+		if !test.ok {
+			continue
+		}
+		if offset != test.offset {
+			t.Errorf("%d:%d: expected offset %d, got %d", test.line, test.column, test.offset, offset)
+		}
+		if pos := f.Position(offset); pos.Line != test.line || pos.Column != test.column {
+			t.Errorf("%d:%d: expected same position, got %d:%d", test.line, test.column, pos.Line, pos.Column)
+		}
+	}
+}
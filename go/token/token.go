@@ -23,22 +23,36 @@ const (
 	COMMENT      // Line-style comment
 	LONGCOMMENT  // Block-style comment
 	comm_end     // COMMENTS ]
+	BOM          // UTF-8 byte order mark at start of file
+	SHEBANG      // `#` line at start of file
 	pre_end      // PREFIXES ]
 	NAME         // Identifier
+	METAVAR      // `$` identifier within a template
 	num_start    // [ NUMBER
 	NUMBERFLOAT  // Float number
 	NUMBERHEX    // Hexadecimal number
+	NUMBERBIN    // Binary number
+	NUMBERI64    // Signed 64-bit integer number
+	NUMBERU64    // Unsigned 64-bit integer number
+	NUMBERIMAG   // Imaginary number
 	num_end      // NUMBER ]
 	str_start    // [ STRINGS
 	STRING       // Quote-style string
 	LONGSTRING   // Block-style string
 	str_end      // STRINGS ]
+	interp_start // [ INTERPOLATED STRINGS
+	INTERPSTRING // Interpolated string without expressions
+	INTERPBEGIN  // Interpolated string, up to the first expression
+	INTERPMID    // Interpolated string, between two expressions
+	INTERPEND    // Interpolated string, after the last expression
+	interp_end   // INTERPOLATED STRINGS ]
 	op_start     // [ OPERATORS
 	SEMICOLON    // `;` operator
 	ASSIGN       // `=` operator
 	COMMA        // `,` operator
 	DOT          // `.` operator
 	COLON        // `:` operator
+	DBCOLON      // `::` operator
 	LBRACK       // `[` operator
 	RBRACK       // `]` operator
 	VARARG       // `...` operator
@@ -46,10 +60,23 @@ const (
 	RPAREN       // `)` operator
 	LBRACE       // `{` operator
 	RBRACE       // `}` operator
+	ARROW        // `->` operator
+	QUESTION     // `?` operator
+	asgn_start   // [ COMPOUND ASSIGNMENT
+	ADDASSIGN    // `+=` operator
+	SUBASSIGN    // `-=` operator
+	MULASSIGN    // `*=` operator
+	DIVASSIGN    // `/=` operator
+	IDIVASSIGN   // `//=` operator
+	MODASSIGN    // `%=` operator
+	POWASSIGN    // `^=` operator
+	CATASSIGN    // `..=` operator
+	asgn_end     // COMPOUND ASSIGNMENT ]
 	binop_start  // [ BINARY OPERATORS
 	PLUS         // `+` binary operator
 	ASTERISK     // `*` binary operator
 	SLASH        // `/` binary operator
+	DSLASH       // `//` binary operator
 	PERCENT      // `%` binary operator
 	CARET        // `^` binary operator
 	CONCAT       // `..` binary operator
@@ -59,8 +86,13 @@ const (
 	GEQ          // `>=` binary operator
 	EQ           // `==` binary operator
 	NEQ          // `~=` binary operator
+	AMPERSAND    // `&` binary operator
+	PIPE         // `|` binary operator
+	SHL          // `<<` binary operator
+	SHR          // `>>` binary operator
 	unop_start   // [ UNARY
 	MINUS        // `-` binary / unary operator
+	TILDE        // `~` binary / unary operator
 	binop_end    // BINARY OPERATORS ]
 	HASH         // `#` unary operator
 	op_end       // OPERATORS ]
@@ -88,6 +120,7 @@ const (
 	LOCAL        // `local` keyword
 	FUNCTION     // `function` keyword
 	BREAK        // `break` keyword
+	GOTO         // `goto` keyword
 	NIL          // `nil` keyword
 	bool_start   // [ BOOLEANS
 	FALSE        // `false` keyword / boolean
@@ -103,15 +136,23 @@ var tokens = [...]string{
 	SPACE:       "<space>",
 	COMMENT:     "<comment>",
 	LONGCOMMENT: "<comment>",
+	BOM:         "<bom>",
+	SHEBANG:     "<shebang>",
 	NAME:        "<name>",
+	METAVAR:     "<metavar>",
 	NUMBERFLOAT: "<number>",
 	NUMBERHEX:   "<number>",
+	NUMBERBIN:   "<number>",
+	NUMBERI64:   "<number>",
+	NUMBERU64:   "<number>",
+	NUMBERIMAG:  "<number>",
 	STRING:      "<string>",
 	LONGSTRING:  "<string>",
 	PLUS:        "+",
 	MINUS:       "-",
 	ASTERISK:    "*",
 	SLASH:       "/",
+	DSLASH:      "//",
 	CARET:       "^",
 	PERCENT:     "%",
 	CONCAT:      "..",
@@ -121,11 +162,17 @@ var tokens = [...]string{
 	GEQ:         ">=",
 	EQ:          "==",
 	NEQ:         "~=",
+	AMPERSAND:   "&",
+	PIPE:        "|",
+	SHL:         "<<",
+	SHR:         ">>",
+	TILDE:       "~",
 	SEMICOLON:   ";",
 	ASSIGN:      "=",
 	COMMA:       ",",
 	DOT:         ".",
 	COLON:       ":",
+	DBCOLON:     "::",
 	LBRACK:      "[",
 	RBRACK:      "]",
 	VARARG:      "...",
@@ -133,6 +180,16 @@ var tokens = [...]string{
 	RPAREN:      ")",
 	LBRACE:      "{",
 	RBRACE:      "}",
+	ARROW:       "->",
+	QUESTION:    "?",
+	ADDASSIGN:   "+=",
+	SUBASSIGN:   "-=",
+	MULASSIGN:   "*=",
+	DIVASSIGN:   "/=",
+	IDIVASSIGN:  "//=",
+	MODASSIGN:   "%=",
+	POWASSIGN:   "^=",
+	CATASSIGN:   "..=",
 	HASH:        "#",
 	DO:          "do",
 	END:         "end",
@@ -149,12 +206,19 @@ var tokens = [...]string{
 	FUNCTION:    "function",
 	RETURN:      "return",
 	BREAK:       "break",
+	GOTO:        "goto",
 	NIL:         "nil",
 	FALSE:       "false",
 	TRUE:        "true",
 	AND:         "and",
 	OR:          "or",
 	NOT:         "not",
+
+	INTERPSTRING: "<interpolated string>",
+	INTERPBEGIN:  "<interpolated string>",
+	INTERPMID:    "<interpolated string>",
+	INTERPEND:    "<interpolated string>",
+
 	// So that we don't get errors when iterating keywords.
 	ekey_start:   "",
 	unop_end:     "",
@@ -205,6 +269,42 @@ func (t Type) IsString() bool {
 	return str_start < t && t < str_end
 }
 
+// IsInterp returns whether the type indicates a part of an interpolated
+// string.
+func (t Type) IsInterp() bool {
+	return interp_start < t && t < interp_end
+}
+
+// IsCompoundAssign returns whether the type indicates a compound assignment
+// operator.
+func (t Type) IsCompoundAssign() bool {
+	return asgn_start < t && t < asgn_end
+}
+
+// CompoundOp returns the binary operator applied by a compound assignment
+// operator. Returns INVALID if the type is not a compound assignment operator.
+func (t Type) CompoundOp() Type {
+	switch t {
+	case ADDASSIGN:
+		return PLUS
+	case SUBASSIGN:
+		return MINUS
+	case MULASSIGN:
+		return ASTERISK
+	case DIVASSIGN:
+		return SLASH
+	case IDIVASSIGN:
+		return DSLASH
+	case MODASSIGN:
+		return PERCENT
+	case POWASSIGN:
+		return CARET
+	case CATASSIGN:
+		return CONCAT
+	}
+	return INVALID
+}
+
 // IsOperator returns whether the type indicates an operator.
 func (t Type) IsOperator() bool {
 	return key_start < t && t < key_end
@@ -235,13 +335,21 @@ func (t Type) IsBinary() bool {
 func (t Type) Precedence() [2]int {
 	switch t {
 	case CARET:
-		return [2]int{10, 9}
-	case ASTERISK, SLASH, PERCENT:
-		return [2]int{7, 7}
+		return [2]int{14, 13}
+	case ASTERISK, SLASH, DSLASH, PERCENT:
+		return [2]int{11, 11}
 	case PLUS, MINUS:
-		return [2]int{6, 6}
+		return [2]int{10, 10}
 	case CONCAT:
-		return [2]int{5, 4}
+		return [2]int{9, 8}
+	case SHL, SHR:
+		return [2]int{7, 7}
+	case AMPERSAND:
+		return [2]int{6, 6}
+	case TILDE:
+		return [2]int{5, 5}
+	case PIPE:
+		return [2]int{4, 4}
 	case LT, GT, LEQ, GEQ, NEQ, EQ:
 		return [2]int{3, 3}
 	case AND:
@@ -253,7 +361,7 @@ func (t Type) Precedence() [2]int {
 }
 
 // UnaryPrecedence indicates the priority of unary operators.
-const UnaryPrecedence = 8
+const UnaryPrecedence = 12
 
 var keywords map[string]Type
 
@@ -264,9 +372,16 @@ func init() {
 	}
 }
 
-// Lookup maps a name to its keyword, or NAME if it is not a keyword.
+// Lookup maps a name to its keyword, or NAME if it is not a keyword within Lua
+// 5.1.
 func Lookup(name string) Type {
-	if t, ok := keywords[name]; ok {
+	return LookupDialect(name, Lua51)
+}
+
+// LookupDialect maps a name to its keyword, or NAME if it is not a keyword
+// within the given dialect.
+func LookupDialect(name string, d Dialect) Type {
+	if t, ok := keywords[name]; ok && t.InDialect(d) {
 		return t
 	}
 	return NAME
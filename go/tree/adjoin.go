@@ -4,27 +4,32 @@ import (
 	"github.com/anaminus/luasyntax/go/token"
 )
 
-// AdjoinSeparator calls left.Type.AdjoinSeparator(right.Type) to get a
-// separating character that allows the left token to precede the right.
+// AdjoinSeparator is like AdjoinSeparatorDialect, with the dialect Lua 5.1.
+func AdjoinSeparator(left, right Token) rune {
+	return AdjoinSeparatorDialect(left, right, token.Lua51)
+}
+
+// AdjoinSeparatorDialect calls left.Type.AdjoinSeparatorDialect(right.Type, d)
+// to get a separating character that allows the left token to precede the
+// right within dialect d.
 //
 // Returns -1 if the two tokens are allowed to be adjacent.
 //
-// When a CONCAT precedes a NUMBERFLOAT, -1 is returned if the bytes of the
-// number token do not start with a '.' character, and a space is returned
-// otherwise.
+// When a CONCAT precedes a number, -1 is returned if the bytes of the number
+// token do not start with a '.' character, and a space is returned otherwise.
 //
-// When a keyword precedes a NUMBERFLOAT, -1 is returned if the bytes of the
-// number token starts with a '.' character, and a space is returned otherwise.
-func AdjoinSeparator(left, right Token) rune {
-	c := left.Type.AdjoinSeparator(right.Type)
+// When a keyword precedes a number, -1 is returned if the bytes of the number
+// token starts with a '.' character, and a space is returned otherwise.
+func AdjoinSeparatorDialect(left, right Token, d token.Dialect) rune {
+	c := left.Type.AdjoinSeparatorDialect(right.Type, d)
 	if c == -2 {
 		switch {
-		case left.Type == token.CONCAT && right.Type == token.NUMBERFLOAT:
+		case left.Type == token.CONCAT:
 			// Adjacency is allowed only if number does not begin with a '.'.
 			if len(right.Bytes) == 0 || right.Bytes[0] != '.' {
 				return -1
 			}
-		case left.Type.IsKeyword() && right.Type == token.NUMBERFLOAT:
+		case left.Type.IsKeyword():
 			// Adjacency is allowed only if number begins with a '.'.
 			if len(right.Bytes) > 0 && right.Bytes[0] == '.' {
 				return -1
@@ -39,6 +44,7 @@ func AdjoinSeparator(left, right Token) rune {
 // adjoinFixer keeps track of the previous token while processing adjoined
 // tokens.
 type adjoinFixer struct {
+	dialect   token.Dialect
 	prevToken *Token
 }
 
@@ -54,19 +60,19 @@ func (v *adjoinFixer) VisitToken(_ Node, _ int, tok *Token) {
 		return
 	}
 
-	if v.prevToken == nil {
-		v.prevToken = tok
-		return
+	// The first token is preceded by nothing, which is allowed to be adjacent
+	// to anything.
+	var left Token
+	if v.prevToken != nil {
+		left = *v.prevToken
 	}
-
-	left := *v.prevToken
 	var right Token
 
 	// Walk through prefixes as though they were tokens.
 	for i := 0; i < len(tok.Prefix); i++ {
 		right.Type = tok.Prefix[i].Type
 		right.Bytes = tok.Prefix[i].Bytes
-		if c := AdjoinSeparator(left, right); c >= 0 {
+		if c := AdjoinSeparatorDialect(left, right, v.dialect); c >= 0 {
 			if tok.Prefix[i].Type == token.SPACE {
 				// Prepend directly to bytes.
 				tok.Prefix[i].Bytes = append(tok.Prefix[i].Bytes, 0)
@@ -87,7 +93,7 @@ func (v *adjoinFixer) VisitToken(_ Node, _ int, tok *Token) {
 
 	// Handle actual token, which appears after either the token's last prefix,
 	// or the previous token.
-	if c := AdjoinSeparator(left, *tok); c >= 0 {
+	if c := AdjoinSeparatorDialect(left, *tok, v.dialect); c >= 0 {
 		if n := len(tok.Prefix); n > 0 && tok.Prefix[n-1].Type == token.SPACE {
 			// Append after last SPACE prefix so that it appears directly before
 			// token. This will happen if the SPACE prefix is empty.
@@ -108,8 +114,14 @@ func (v *adjoinFixer) VisitToken(_ Node, _ int, tok *Token) {
 
 // FixAdjoinedTokens walks through a parse tree and ensures that adjacent tokens
 // have the minimum amount of spacing required to prevent them from being parsed
-// incorrectly.
+// incorrectly when scanned as Lua 5.1.
 func FixAdjoinedTokens(node Node) {
-	var v adjoinFixer
+	FixAdjoinedTokensDialect(node, token.Lua51)
+}
+
+// FixAdjoinedTokensDialect is like FixAdjoinedTokens, but the tokens are
+// scanned as dialect d.
+func FixAdjoinedTokensDialect(node Node, d token.Dialect) {
+	v := adjoinFixer{dialect: d}
 	Walk(&v, node)
 }
@@ -0,0 +1,481 @@
+package tree
+
+import (
+	"fmt"
+	"github.com/anaminus/luasyntax/go/token"
+	"reflect"
+	"strings"
+)
+
+// An ApplyFunc is called by Apply for each non-nil node, before and after the
+// children of the node are traversed. The Cursor describes the current node,
+// and provides operations for modifying the tree at that location. See Apply
+// for how the result affects the traversal.
+type ApplyFunc func(c *Cursor) bool
+
+// Apply traverses a tree in depth-first, lexical order, starting with root,
+// and calling pre and post for each non-nil node. Either function may be nil.
+//
+// For each node, pre is called before the children of the node are traversed.
+// If pre returns false, then the children and post are skipped. Otherwise,
+// post is called after the children are traversed. If post returns false, then
+// the traversal stops entirely.
+//
+// The pre and post functions may modify the tree through the Cursor. If the
+// current node is replaced in pre, then the children of the new node are
+// traversed. If the current node is deleted in pre, then the children and
+// post are skipped. Nodes inserted through the cursor are not traversed.
+//
+// The result is the root node, which may have been replaced. Note that the
+// offsets of tokens are not updated after modification; FixTokenOffsets may be
+// used to do so.
+func Apply(root Node, pre, post ApplyFunc) (result Node) {
+	holder := struct{ Root Node }{root}
+	a := &application{pre: pre, post: post}
+	defer func() {
+		if r := recover(); r != nil && r != abort {
+			panic(r)
+		}
+		result = holder.Root
+	}()
+	a.apply(nil, "Root", reflect.ValueOf(&holder).Elem().Field(0), nil, nil)
+	return holder.Root
+}
+
+// abort is a sentinel value used to stop Apply.
+var abort = new(int)
+
+// listKind describes how the separators of a list correspond to the items of
+// the list.
+type listKind int
+
+const (
+	// Items cannot be inserted or deleted.
+	listFixed listKind = iota
+	// No separators.
+	listNone
+	// Each item is followed by a separator, which may be INVALID.
+	listAligned
+	// Each item except the last is followed by a separator. The list cannot
+	// be empty.
+	listBetween
+	// Like listBetween, but the last item may also be followed by a
+	// separator.
+	listTrailing
+)
+
+// list describes a list of nodes within a parent node.
+type list struct {
+	kind listKind
+	seps reflect.Value // Separator tokens, if any.
+	sep  token.Type    // Type of inserted separators.
+}
+
+// iterator holds the state of a list being traversed.
+type iterator struct {
+	index int
+	step  int
+}
+
+// A Cursor describes a node encountered during Apply. The methods of a Cursor
+// are valid only during the call to the ApplyFunc that received it.
+type Cursor struct {
+	parent Node
+	name   string
+	field  reflect.Value // Field containing the node, or the slice of a list.
+	list   *list
+	iter   *iterator
+	node   Node
+}
+
+// Node returns the current node.
+func (c *Cursor) Node() Node { return c.node }
+
+// Parent returns the parent of the current node, or nil if the current node
+// is the root.
+func (c *Cursor) Parent() Node { return c.parent }
+
+// Name returns the name of the field of the parent that contains the current
+// node. Fields within embedded values are separated by dots, as in
+// "Func.Body".
+func (c *Cursor) Name() string { return c.name }
+
+// Index returns the index of the current node within the field of the parent,
+// if the field is a list. Returns -1 otherwise.
+func (c *Cursor) Index() int {
+	if c.iter == nil {
+		return -1
+	}
+	return c.iter.index
+}
+
+// elem returns the value containing the current node.
+func (c *Cursor) elem() reflect.Value {
+	if c.iter == nil {
+		return c.field
+	}
+	return c.field.Index(c.iter.index)
+}
+
+// Replace replaces the current node with n. Panics if n has a type that cannot
+// be stored in the field. n may be nil only if the field is optional.
+func (c *Cursor) Replace(n Node) {
+	elem := c.elem()
+	if n == nil && c.iter != nil && c.list.kind != listFixed {
+		panic("tree: Replace with nil node within list; use Delete")
+	}
+	elem.Set(nodeValue(elem.Type(), n, c.name))
+	c.node = elemNode(elem)
+}
+
+// checkList panics if the current node cannot be inserted or deleted.
+func (c *Cursor) checkList(method string) {
+	if c.iter == nil || c.list.kind == listFixed {
+		panic("tree: " + method + " of node not contained in list")
+	}
+}
+
+// Delete deletes the current node from its containing list, along with a
+// corresponding separator. Panics if the current node is not contained in a
+// list that permits deletion.
+//
+// The only node of a list that must not be empty, such as the items of an
+// ExprList or TypeList, cannot be deleted, and Delete panics in this case.
+// Such a list may instead be removed by replacing it, or by deleting it if it
+// is optional.
+func (c *Cursor) Delete() {
+	c.checkList("Delete")
+	if c.list.kind == listBetween && c.field.Len() == 1 {
+		panic("tree: Delete of only node of list that cannot be empty")
+	}
+	i := c.iter.index
+	items := c.field
+	deleteValue(items, i)
+	switch c.list.kind {
+	case listAligned:
+		if i < c.list.seps.Len() {
+			deleteValue(c.list.seps, i)
+		}
+	case listBetween, listTrailing:
+		if n := c.list.seps.Len(); i < n {
+			deleteValue(c.list.seps, i)
+		} else if n > 0 {
+			deleteValue(c.list.seps, n-1)
+		}
+	}
+	c.iter.step--
+	c.node = nil
+}
+
+// insert inserts n into the list at index i, along with a separator.
+func (c *Cursor) insert(i int, n Node) {
+	items := c.field
+	count := items.Len()
+	insertValue(items, i, nodeValue(items.Type().Elem(), n, c.name))
+	if c.list.kind == listAligned && i <= c.list.seps.Len() {
+		insertValue(c.list.seps, i, reflect.ValueOf(Token{}))
+		return
+	}
+	if c.list.kind != listBetween && c.list.kind != listTrailing || count == 0 {
+		return
+	}
+	sep := reflect.ValueOf(Token{Type: c.list.sep, Bytes: []byte(c.list.sep.String())})
+	if i < count {
+		// Separator follows the inserted item.
+		insertValue(c.list.seps, i, sep)
+	} else if c.list.seps.Len() == count {
+		// Retain trailing separator.
+		insertValue(c.list.seps, c.list.seps.Len(), sep)
+	} else {
+		// Separator precedes the inserted item.
+		insertValue(c.list.seps, count-1, sep)
+	}
+}
+
+// InsertBefore inserts n into the containing list before the current node.
+// The inserted node is not traversed. A separator is inserted along with the
+// node as needed. Panics if the current node is not contained in a list that
+// permits insertion.
+func (c *Cursor) InsertBefore(n Node) {
+	c.checkList("InsertBefore")
+	c.insert(c.iter.index, n)
+	c.iter.index++
+}
+
+// InsertAfter inserts n into the containing list after the current node. The
+// inserted node is not traversed. A separator is inserted along with the node
+// as needed. Panics if the current node is not contained in a list that
+// permits insertion.
+func (c *Cursor) InsertAfter(n Node) {
+	c.checkList("InsertAfter")
+	c.insert(c.iter.index+1, n)
+	c.iter.step++
+}
+
+// nodeValue converts n to a value that can be stored in a field of type t.
+func nodeValue(t reflect.Type, n Node, name string) reflect.Value {
+	if n == nil {
+		if t.Kind() == reflect.Struct {
+			panic("tree: nil node for required field " + name)
+		}
+		return reflect.Zero(t)
+	}
+	v := reflect.ValueOf(n)
+	if t.Kind() == reflect.Struct {
+		if v.Type() != reflect.PtrTo(t) || v.IsNil() {
+			panic(fmt.Sprintf("tree: node type %T cannot be stored in field %s of type %s", n, name, t))
+		}
+		return v.Elem()
+	}
+	if !v.Type().AssignableTo(t) {
+		panic(fmt.Sprintf("tree: node type %T cannot be stored in field %s of type %s", n, name, t))
+	}
+	return v
+}
+
+// elemNode returns the node contained in v, or nil if there is no node.
+func elemNode(v reflect.Value) Node {
+	switch v.Kind() {
+	case reflect.Struct:
+		return v.Addr().Interface().(Node)
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return v.Interface().(Node)
+	}
+	return nil
+}
+
+// insertValue inserts v into slice s at index i.
+func insertValue(s reflect.Value, i int, v reflect.Value) {
+	s.Set(reflect.Append(s, reflect.Zero(s.Type().Elem())))
+	reflect.Copy(s.Slice(i+1, s.Len()), s.Slice(i, s.Len()-1))
+	s.Index(i).Set(v)
+}
+
+// deleteValue deletes the value at index i from slice s.
+func deleteValue(s reflect.Value, i int) {
+	reflect.Copy(s.Slice(i, s.Len()), s.Slice(i+1, s.Len()))
+	s.Index(s.Len() - 1).Set(reflect.Zero(s.Type().Elem()))
+	s.Set(s.Slice(0, s.Len()-1))
+}
+
+// application holds the state of a call to Apply.
+type application struct {
+	pre, post ApplyFunc
+	cursor    Cursor
+}
+
+// field returns the field of node with the given name, which may refer to
+// fields of embedded values.
+func field(node Node, name string) reflect.Value {
+	v := reflect.ValueOf(node).Elem()
+	for _, name := range strings.Split(name, ".") {
+		v = v.FieldByName(name)
+	}
+	return v
+}
+
+// apply calls pre and post for the node contained in field, or within the
+// list held by field at the index of iter, and traverses the children of the
+// node.
+func (a *application) apply(parent Node, name string, field reflect.Value, l *list, iter *iterator) {
+	c := Cursor{parent: parent, name: name, field: field, list: l, iter: iter}
+	if c.node = elemNode(c.elem()); c.node == nil {
+		return
+	}
+	saved := a.cursor
+	a.cursor = c
+	if a.pre != nil && !a.pre(&a.cursor) {
+		a.cursor = saved
+		return
+	}
+	if node := a.cursor.node; node != nil {
+		a.walk(node)
+		if a.post != nil && !a.post(&a.cursor) {
+			panic(abort)
+		}
+	}
+	a.cursor = saved
+}
+
+// fields applies each named field of node.
+func (a *application) fields(node Node, names ...string) {
+	for _, name := range names {
+		a.apply(node, name, field(node, name), nil, nil)
+	}
+}
+
+// list applies each item of the named list field of node.
+func (a *application) list(node Node, name string, kind listKind, sep token.Type) {
+	l := &list{kind: kind, sep: sep}
+	if kind != listFixed && kind != listNone {
+		l.seps = field(node, "Seps")
+	}
+	items := field(node, name)
+	iter := &iterator{}
+	for iter.index < items.Len() {
+		iter.step = 1
+		a.apply(node, name, items, l, iter)
+		iter.index += iter.step
+	}
+}
+
+// walk applies the children of node.
+func (a *application) walk(node Node) {
+	switch node := node.(type) {
+	case *File:
+		a.fields(node, "Body")
+	case *Block:
+		a.list(node, "Items", listAligned, token.INVALID)
+	case *ExprList:
+		a.list(node, "Items", listBetween, token.COMMA)
+	case *NameList:
+		attribs := &list{kind: listFixed}
+		types := &list{kind: listFixed}
+		for i := range node.Items {
+			if i < len(node.Attribs) {
+				a.apply(node, "Attribs", field(node, "Attribs"), attribs, &iterator{index: i})
+			}
+			if i < len(node.Types) {
+				a.apply(node, "Types", field(node, "Types"), types, &iterator{index: i})
+			}
+		}
+	case *Attrib:
+	case *TypeAnnot:
+		a.fields(node, "Type")
+	case *BadExpr:
+	case *NumberExpr:
+	case *StringExpr:
+	case *NilExpr:
+	case *BoolExpr:
+	case *VarArgExpr:
+	case *UnopExpr:
+		a.fields(node, "Operand")
+	case *BinopExpr:
+		a.fields(node, "Left", "Right")
+	case *ParenExpr:
+		a.fields(node, "Value")
+	case *VariableExpr:
+	case *TableCtor:
+		a.fields(node, "Entries")
+	case *EntryList:
+		a.list(node, "Items", listTrailing, token.COMMA)
+	case *IndexEntry:
+		a.fields(node, "Key", "Value")
+	case *FieldEntry:
+		a.fields(node, "Value")
+	case *ValueEntry:
+		a.fields(node, "Value")
+	case *FunctionExpr:
+		a.fields(node, "Generics", "Params", "VarArgType", "ReturnType", "Body")
+	case *FieldExpr:
+		a.fields(node, "Value")
+	case *IndexExpr:
+		a.fields(node, "Value", "Index")
+	case *MethodExpr:
+		a.fields(node, "Value", "Args")
+	case *CallExpr:
+		a.fields(node, "Value", "Args")
+	case *IfExpr:
+		a.fields(node, "Cond", "Value")
+		a.list(node, "ElseIf", listNone, token.INVALID)
+		a.fields(node, "Else")
+	case *ElseIfExprClause:
+		a.fields(node, "Cond", "Value")
+	case *InterpExpr:
+		a.list(node, "Exprs", listFixed, token.INVALID)
+	case *AssertExpr:
+		a.fields(node, "Value", "Type")
+	case *ListArgs:
+		a.fields(node, "Values")
+	case *TableArg:
+		a.fields(node, "Value")
+	case *StringArg:
+		a.fields(node, "Value")
+	case *BadStmt:
+	case *MetaStmt:
+	case *DoStmt:
+		a.fields(node, "Body")
+	case *AssignStmt:
+		a.fields(node, "Left", "Right")
+	case *CompoundAssignStmt:
+		a.fields(node, "Left", "Right")
+	case *CallStmt:
+		a.fields(node, "Call")
+	case *IfStmt:
+		a.fields(node, "Cond", "Body")
+		a.list(node, "ElseIf", listNone, token.INVALID)
+		a.fields(node, "Else")
+	case *ElseIfClause:
+		a.fields(node, "Cond", "Body")
+	case *ElseClause:
+		a.fields(node, "Body")
+	case *NumericForStmt:
+		a.fields(node, "NameType", "Min", "Max", "Step", "Body")
+	case *GenericForStmt:
+		a.fields(node, "Names", "Iterator", "Body")
+	case *WhileStmt:
+		a.fields(node, "Cond", "Body")
+	case *RepeatStmt:
+		a.fields(node, "Body", "Cond")
+	case *LocalVarStmt:
+		a.fields(node, "Names", "Values")
+	case *LocalFunctionStmt:
+		a.fields(node, "Func.Generics", "Func.Params", "Func.VarArgType", "Func.ReturnType", "Func.Body")
+	case *FunctionStmt:
+		a.fields(node, "Name", "Func.Generics", "Func.Params", "Func.VarArgType", "Func.ReturnType", "Func.Body")
+	case *FuncNameList:
+	case *BreakStmt:
+	case *ContinueStmt:
+	case *GotoStmt:
+	case *LabelStmt:
+	case *TypeStmt:
+		a.fields(node, "Generics", "Type")
+	case *ReturnStmt:
+		a.fields(node, "Values")
+	case *TypeList:
+		a.list(node, "Items", listBetween, token.COMMA)
+	case *GenericList:
+		a.list(node, "Items", listBetween, token.COMMA)
+	case *GenericParam:
+		a.fields(node, "Default")
+	case *NamedType:
+		a.fields(node, "Params")
+	case *TypeParams:
+		a.fields(node, "Types")
+	case *LiteralType:
+	case *TypeofType:
+		a.fields(node, "Value")
+	case *TableType:
+		a.fields(node, "Entries")
+	case *TypeEntryList:
+		a.list(node, "Items", listTrailing, token.COMMA)
+	case *TypeIndexEntry:
+		a.fields(node, "Key", "Value")
+	case *TypeFieldEntry:
+		a.fields(node, "Value")
+	case *TypeValueEntry:
+		a.fields(node, "Value")
+	case *FunctionType:
+		a.fields(node, "Generics", "Params", "Return")
+	case *ParamType:
+		a.fields(node, "Type")
+	case *ParenType:
+		a.fields(node, "Value")
+	case *PackType:
+		a.fields(node, "Types")
+	case *VariadicType:
+		a.fields(node, "Value")
+	case *GenericPackType:
+	case *OptionalType:
+		a.fields(node, "Value")
+	case *UnionType:
+		a.list(node, "Items", listBetween, token.PIPE)
+	case *IntersectionType:
+		a.list(node, "Items", listBetween, token.AMPERSAND)
+	default:
+		panic(fmt.Sprintf("unexpected node type %T", node))
+	}
+}
@@ -0,0 +1,275 @@
+package tree_test
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/anaminus/luasyntax/go/token"
+	"github.com/anaminus/luasyntax/go/tree"
+	"strings"
+	"testing"
+)
+
+func source(node tree.Node) string {
+	var buf bytes.Buffer
+	node.WriteTo(&buf)
+	return buf.String()
+}
+
+// validator records the first invalid node within a tree.
+type validator struct {
+	invalid tree.Node
+}
+
+func (v *validator) Visit(node tree.Node) tree.Visitor {
+	if v.invalid != nil {
+		return nil
+	}
+	if !node.IsValid() {
+		v.invalid = node
+		return nil
+	}
+	return v
+}
+
+func invalidNode(node tree.Node) tree.Node {
+	var v validator
+	tree.Walk(&v, node)
+	return v.invalid
+}
+
+func name(s string) *tree.VariableExpr {
+	return &tree.VariableExpr{NameToken: tree.Token{
+		Type:   token.NAME,
+		Bytes:  []byte(s),
+		Prefix: []tree.Prefix{{Type: token.SPACE, Bytes: []byte(" ")}},
+	}}
+}
+
+// isName returns whether the current node is a variable with name s.
+func isName(c *tree.Cursor, s string) bool {
+	v, ok := c.Node().(*tree.VariableExpr)
+	return ok && string(v.NameToken.Bytes) == s
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		pre  tree.ApplyFunc
+		want string
+	}{
+		{
+			"replace",
+			"x = a + b",
+			func(c *tree.Cursor) bool {
+				if isName(c, "b") {
+					c.Replace(name("c"))
+				}
+				return true
+			},
+			"x = a + c",
+		},
+		{
+			"delete middle",
+			"f(a, b, c)",
+			func(c *tree.Cursor) bool {
+				if isName(c, "b") {
+					c.Delete()
+				}
+				return true
+			},
+			"f(a, c)",
+		},
+		{
+			"delete last",
+			"f(a, b)",
+			func(c *tree.Cursor) bool {
+				if isName(c, "b") {
+					c.Delete()
+				}
+				return true
+			},
+			"f(a)",
+		},
+		{
+			"delete trailing",
+			"t = {a, b,}",
+			func(c *tree.Cursor) bool {
+				if e, ok := c.Node().(*tree.ValueEntry); ok && source(e) == " b" {
+					c.Delete()
+				}
+				return true
+			},
+			"t = {a,}",
+		},
+		{
+			"delete statement",
+			"a()\nb()\nc()",
+			func(c *tree.Cursor) bool {
+				if s, ok := c.Node().(*tree.CallStmt); ok && strings.Contains(source(s), "b") {
+					c.Delete()
+				}
+				return true
+			},
+			"a()\nc()",
+		},
+		{
+			"insert",
+			"f(b)",
+			func(c *tree.Cursor) bool {
+				if isName(c, "b") {
+					c.InsertBefore(name("a"))
+					c.InsertAfter(name("c"))
+				}
+				return true
+			},
+			"f( a,b, c)",
+		},
+	}
+	for _, test := range tests {
+		f := parseFile(t, test.src)
+		result := tree.Apply(f, test.pre, nil)
+		if result != tree.Node(f) {
+			t.Errorf("%s: expected same root", test.name)
+		}
+		if got := source(f); got != test.want {
+			t.Errorf("%s: expected %q, got %q", test.name, test.want, got)
+		}
+		if node := invalidNode(f); node != nil {
+			t.Errorf("%s: invalid %T", test.name, node)
+		}
+	}
+}
+
+func TestApplyCursor(t *testing.T) {
+	f := parseFile(t, "local x = f(a, b.c)")
+	var visits []string
+	tree.Apply(f, func(c *tree.Cursor) bool {
+		parent := "<nil>"
+		if c.Parent() != nil {
+			parent = strings.TrimPrefix(fmt.Sprintf("%T", c.Parent()), "*tree.")
+		}
+		node := strings.TrimPrefix(fmt.Sprintf("%T", c.Node()), "*tree.")
+		visits = append(visits, fmt.Sprintf("%s.%s[%d]=%s", parent, c.Name(), c.Index(), node))
+		return true
+	}, nil)
+	want := []string{
+		"<nil>.Root[-1]=File",
+		"File.Body[-1]=Block",
+		"Block.Items[0]=LocalVarStmt",
+		"LocalVarStmt.Names[-1]=NameList",
+		"LocalVarStmt.Values[-1]=ExprList",
+		"ExprList.Items[0]=CallExpr",
+		"CallExpr.Value[-1]=VariableExpr",
+		"CallExpr.Args[-1]=ListArgs",
+		"ListArgs.Values[-1]=ExprList",
+		"ExprList.Items[0]=VariableExpr",
+		"ExprList.Items[1]=FieldExpr",
+		"FieldExpr.Value[-1]=VariableExpr",
+	}
+	if strings.Join(visits, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected visits\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(visits, "\n"))
+	}
+}
+
+func TestApplyControl(t *testing.T) {
+	const src = "x = a + (b + c) d = e"
+	tests := []struct {
+		name string
+		pre  tree.ApplyFunc
+		post tree.ApplyFunc
+		want string // Each visited variable.
+	}{
+		{"all", nil, nil, "x a b c d e"},
+		{
+			"skip children",
+			func(c *tree.Cursor) bool {
+				_, ok := c.Node().(*tree.ParenExpr)
+				return !ok
+			},
+			nil,
+			"x a d e",
+		},
+		{
+			"stop",
+			nil,
+			func(c *tree.Cursor) bool {
+				return !isName(c, "c")
+			},
+			"x a b c",
+		},
+	}
+	for _, test := range tests {
+		var names []string
+		visit := func(c *tree.Cursor) bool {
+			if v, ok := c.Node().(*tree.VariableExpr); ok {
+				names = append(names, string(v.NameToken.Bytes))
+			}
+			return true
+		}
+		pre := func(c *tree.Cursor) bool {
+			if test.pre != nil && !test.pre(c) {
+				return false
+			}
+			return true
+		}
+		post := func(c *tree.Cursor) bool {
+			visit(c)
+			if test.post != nil {
+				return test.post(c)
+			}
+			return true
+		}
+		tree.Apply(parseFile(t, src), pre, post)
+		if got := strings.Join(names, " "); got != test.want {
+			t.Errorf("%s: expected %q, got %q", test.name, test.want, got)
+		}
+	}
+}
+
+func TestApplyReplaceRoot(t *testing.T) {
+	expr := &tree.BinopExpr{
+		Left:       name("a"),
+		BinopToken: tree.Token{Type: token.PLUS, Bytes: []byte("+")},
+		Right:      name("b"),
+	}
+	result := tree.Apply(expr, func(c *tree.Cursor) bool {
+		if c.Parent() == nil {
+			c.Replace(name("c"))
+			return false
+		}
+		return true
+	}, nil)
+	if got := source(result); got != " c" {
+		t.Errorf("expected %q, got %q", " c", got)
+	}
+}
+
+func TestApplyPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		fn   func(c *tree.Cursor)
+	}{
+		{"delete only item", "f(a)", func(c *tree.Cursor) { c.Delete() }},
+		{"delete field", "x = a + b", func(c *tree.Cursor) { c.Delete() }},
+		{"insert field", "x = a + b", func(c *tree.Cursor) { c.InsertAfter(name("c")) }},
+		{"replace nil in list", "f(a)", func(c *tree.Cursor) { c.Replace(nil) }},
+		{"replace wrong type", "f(a)", func(c *tree.Cursor) { c.Replace(&tree.Block{}) }},
+	}
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected panic", test.name)
+				}
+			}()
+			tree.Apply(parseFile(t, test.src), func(c *tree.Cursor) bool {
+				if isName(c, "a") {
+					test.fn(c)
+				}
+				return true
+			}, nil)
+		}()
+	}
+}
@@ -0,0 +1,93 @@
+package tree
+
+import (
+	"reflect"
+)
+
+var (
+	nodeType  = reflect.TypeOf((*Node)(nil)).Elem()
+	tokenType = reflect.TypeOf(Token{})
+)
+
+// Clone returns a deep copy of node. Child nodes, tokens, and prefixes are
+// copied, along with the bytes of each token and prefix, so that the result
+// does not share any memory with node. Values that are not a part of the tree,
+// such as the Info of a File, are shared.
+func Clone(node Node) Node {
+	return cloneNode(node, false)
+}
+
+// CloneSharingBytes is like Clone, except that the bytes of each token and
+// prefix are shared with node rather than copied. The result must not be
+// modified in a way that changes the content of such bytes.
+func CloneSharingBytes(node Node) Node {
+	return cloneNode(node, true)
+}
+
+func cloneNode(node Node, share bool) Node {
+	if node == nil {
+		return nil
+	}
+	src := reflect.ValueOf(node)
+	if src.IsNil() {
+		return node
+	}
+	c := cloner{share: share}
+	dst := reflect.New(src.Type().Elem())
+	c.value(dst.Elem(), src.Elem())
+	return dst.Interface().(Node)
+}
+
+// cloner deeply copies the values of a tree.
+type cloner struct {
+	share bool
+}
+
+// value copies src to dst, which is assumed to be addressable and of the same
+// type.
+func (c *cloner) value(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Interface:
+		if src.IsNil() {
+			return
+		}
+		v := reflect.New(src.Elem().Type()).Elem()
+		c.value(v, src.Elem())
+		dst.Set(v)
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		if !src.Type().Implements(nodeType) {
+			// Not a part of the tree.
+			dst.Set(src)
+			return
+		}
+		v := reflect.New(src.Type().Elem())
+		c.value(v.Elem(), src.Elem())
+		dst.Set(v)
+	case reflect.Struct:
+		for i := 0; i < src.NumField(); i++ {
+			c.value(dst.Field(i), src.Field(i))
+		}
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		if src.Type().Elem().Kind() == reflect.Uint8 {
+			if c.share {
+				dst.Set(src)
+			} else {
+				dst.SetBytes(append([]byte{}, src.Bytes()...))
+			}
+			return
+		}
+		v := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			c.value(v.Index(i), src.Index(i))
+		}
+		dst.Set(v)
+	default:
+		dst.Set(src)
+	}
+}
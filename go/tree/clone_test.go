@@ -0,0 +1,69 @@
+package tree_test
+
+import (
+	"github.com/anaminus/luasyntax/go/tree"
+	"testing"
+)
+
+const cloneSrc = "-- comment\nlocal function f(a, ...)\n\treturn {a, [1] = 'x', y = (a + 1)}\nend\nf(1)\n"
+
+// firstName returns the first NAME token within node.
+func firstName(node tree.Node) *tree.Token {
+	v, _ := find(node, "*tree.VariableExpr", 0).(*tree.VariableExpr)
+	if v == nil {
+		return nil
+	}
+	return &v.NameToken
+}
+
+func TestClone(t *testing.T) {
+	f := parseFile(t, cloneSrc)
+	c := tree.Clone(f).(*tree.File)
+	if c == f {
+		t.Fatal("expected new node")
+	}
+	if !tree.Equal(f, c, 0) {
+		t.Error("expected clone to be equal")
+	}
+	if got := source(c); got != cloneSrc {
+		t.Errorf("expected %q, got %q", cloneSrc, got)
+	}
+	if c.Info != f.Info {
+		t.Error("expected Info to be shared")
+	}
+
+	// Modifying the clone does not affect the original.
+	tok := c.Body.Items[0].FirstToken()
+	tok.Bytes[0] = 'L'
+	tok.Prefix[0].Bytes[0] = '#'
+	firstName(c).Offset = 100
+	c.Body.Items = c.Body.Items[:1]
+	if got := source(f); got != cloneSrc {
+		t.Errorf("expected original to be unchanged, got %q", got)
+	}
+}
+
+func TestCloneSharingBytes(t *testing.T) {
+	f := parseFile(t, cloneSrc)
+	c := tree.CloneSharingBytes(f)
+	if !tree.Equal(f, c, 0) {
+		t.Error("expected clone to be equal")
+	}
+	a, b := firstName(f), firstName(c)
+	if a == b {
+		t.Error("expected tokens to be copied")
+	}
+	if &a.Bytes[0] != &b.Bytes[0] {
+		t.Error("expected bytes to be shared")
+	}
+}
+
+func TestCloneNil(t *testing.T) {
+	if tree.Clone(nil) != nil {
+		t.Error("expected nil")
+	}
+	var expr *tree.BinopExpr
+	if c := tree.Clone(expr); c != tree.Node(expr) {
+		t.Errorf("expected typed nil, got %v", c)
+	}
+}
@@ -0,0 +1,289 @@
+package tree
+
+import (
+	"bytes"
+	"github.com/anaminus/luasyntax/go/token"
+	"sort"
+	"strings"
+)
+
+// CommentGroup represents a sequence of comments within the prefix of a token,
+// with no empty lines between them.
+type CommentGroup struct {
+	// Token is the token whose prefix contains the comments.
+	Token *Token
+	// First is the index within the prefix of the token of the first comment
+	// in the group.
+	First int
+	// Last is the index within the prefix of the token of the last comment in
+	// the group. Prefixes between First and Last that are not comments are
+	// whitespace.
+	Last int
+	// Trailing indicates whether the group begins on the same line as the end
+	// of the preceding token.
+	Trailing bool
+}
+
+// List returns the prefixes of the group, including whitespace between
+// comments.
+func (g *CommentGroup) List() []Prefix {
+	return g.Token.Prefix[g.First : g.Last+1]
+}
+
+// Offset returns the offset of the first character of the group.
+func (g *CommentGroup) Offset() int {
+	n := g.Token.Offset
+	for _, p := range g.Token.Prefix[g.First:] {
+		n -= len(p.Bytes)
+	}
+	return n
+}
+
+// EndOffset returns the offset following the last character of the group.
+func (g *CommentGroup) EndOffset() int {
+	n := g.Offset()
+	for _, p := range g.List() {
+		n += len(p.Bytes)
+	}
+	return n
+}
+
+// Text returns the text of the comments in the group, with comment markers,
+// the first space of a line comment, leading and trailing empty lines, and
+// trailing whitespace of each line removed. Lines are separated by newlines.
+func (g *CommentGroup) Text() string {
+	var lines []string
+	for _, p := range g.List() {
+		var text []byte
+		switch p.Type {
+		case token.COMMENT:
+			text = p.Bytes[2:]
+			if len(text) > 0 && text[0] == ' ' {
+				text = text[1:]
+			}
+		case token.LONGCOMMENT:
+			text = trimLongBrackets(p.Bytes[2:])
+		default:
+			continue
+		}
+		for _, line := range strings.Split(string(text), "\n") {
+			lines = append(lines, strings.TrimRight(line, " \t\r"))
+		}
+	}
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// trimLongBrackets returns the content of a long bracket string.
+func trimLongBrackets(b []byte) []byte {
+	i := bytes.IndexByte(b[1:], '[')
+	if i < 0 || len(b) < 2*(i+2) {
+		return b
+	}
+	return b[i+2 : len(b)-(i+2)]
+}
+
+// commentGroups returns the comment groups within the prefix of tok. The
+// first argument indicates whether tok is the first token of a file.
+func commentGroups(tok *Token, first bool) (groups []*CommentGroup) {
+	sameLine := !first
+	var g *CommentGroup
+	for i, p := range tok.Prefix {
+		switch {
+		case p.Type == token.SPACE:
+			switch bytes.Count(p.Bytes, []byte{'\n'}) {
+			case 0:
+			case 1:
+				sameLine = false
+			default:
+				// Empty line.
+				sameLine = false
+				g = nil
+			}
+		case p.Type.IsComment():
+			if g == nil || g.Trailing != sameLine {
+				g = &CommentGroup{Token: tok, First: i, Trailing: sameLine}
+				groups = append(groups, g)
+			}
+			g.Last = i
+			if bytes.IndexByte(p.Bytes, '\n') >= 0 {
+				sameLine = false
+			}
+		default:
+			g = nil
+		}
+	}
+	return groups
+}
+
+// CommentMap maps a node to the comment groups associated with it. Comments
+// are stored within the prefixes of tokens; a CommentMap associates each
+// comment group with the node it most likely describes, so that the comments
+// may be moved or removed along with the node.
+type CommentMap map[Node][]*CommentGroup
+
+// isCommentNode returns whether comments may be associated with node.
+func isCommentNode(node Node) bool {
+	switch node.(type) {
+	case Stmt, Expr, Entry, TypeEntry, *ElseIfClause, *ElseClause, *File:
+		return true
+	}
+	return false
+}
+
+// commentMapper collects information about the tokens of a tree needed to
+// associate comments with nodes.
+type commentMapper struct {
+	root    Node
+	pending []Node
+	last    *Token
+	cmap    CommentMap
+
+	// Outermost node that starts at each token.
+	starts map[*Token]Node
+	// Outermost node that ends at each token.
+	ends map[*Token]Node
+	// Innermost node that encloses each token.
+	encl map[*Token]Node
+}
+
+// commentVisitor visits the nodes of a tree for a commentMapper.
+type commentVisitor struct {
+	m *commentMapper
+	// Innermost node that encloses the current node, including the current
+	// node.
+	encl Node
+}
+
+func (v *commentVisitor) Visit(node Node) Visitor {
+	if node == nil {
+		return nil
+	}
+	if !isCommentNode(node) {
+		return &commentVisitor{m: v.m, encl: v.encl}
+	}
+	m := v.m
+	if node == m.root {
+		// Comments are associated with the root only as a last resort.
+		return &commentVisitor{m: m, encl: node}
+	}
+	m.pending = append(m.pending, node)
+	if last := lastToken(node); last != nil {
+		if _, ok := m.ends[last]; !ok {
+			m.ends[last] = node
+		}
+	}
+	return &commentVisitor{m: m, encl: node}
+}
+
+func (v *commentVisitor) VisitToken(_ Node, _ int, tok *Token) {
+	if !tok.Type.IsValid() {
+		return
+	}
+	m := v.m
+	if len(m.pending) > 0 {
+		m.starts[tok] = m.pending[0]
+		m.pending = m.pending[:0]
+	}
+	m.encl[tok] = v.encl
+	prev := m.last
+	m.last = tok
+	for _, g := range commentGroups(tok, prev == nil) {
+		var node Node
+		if g.Trailing {
+			if node = m.ends[prev]; node == nil {
+				node = m.encl[prev]
+			}
+		} else {
+			if node = m.starts[tok]; node == nil {
+				node = m.encl[tok]
+			}
+		}
+		if node == nil {
+			node = m.root
+		}
+		m.cmap[node] = append(m.cmap[node], g)
+	}
+	if tok.Type == token.COMMA || tok.Type == token.SEMICOLON {
+		// A trailing group after a separator belongs to the node before the
+		// separator.
+		if _, ok := m.ends[tok]; !ok && prev != nil {
+			m.ends[tok] = m.ends[prev]
+		}
+	}
+}
+
+// NewCommentMap creates a CommentMap by grouping the comments within the tree
+// of node, and associating each group with a node of the tree. Comments are
+// associated with statements, expressions, table entries, and type entries.
+//
+// A group of comments that begins on the same line as a preceding token is a
+// trailing group, and is associated with the outermost node that ends with the
+// preceding token, or with the token before a preceding separator. Otherwise,
+// the group is a leading group, and is associated with the outermost node that
+// begins with the following token. If there is no such node, then the group is
+// associated with the innermost node that encloses the token, or node itself.
+//
+// Comments are grouped when they are separated only by whitespace containing
+// at most one newline.
+func NewCommentMap(node Node) CommentMap {
+	m := commentMapper{
+		root:   node,
+		cmap:   CommentMap{},
+		starts: map[*Token]Node{},
+		ends:   map[*Token]Node{},
+		encl:   map[*Token]Node{},
+	}
+	Walk(&commentVisitor{m: &m}, node)
+	return m.cmap
+}
+
+// Update replaces an old node in the comment map with a new node, and returns
+// the new node. Comments that were associated with the old node are
+// associated with the new node.
+func (cmap CommentMap) Update(old, new Node) Node {
+	if list := cmap[old]; len(list) > 0 {
+		delete(cmap, old)
+		cmap[new] = append(cmap[new], list...)
+	}
+	return new
+}
+
+// nodeCollector collects every node within a tree.
+type nodeCollector map[Node]bool
+
+func (c nodeCollector) Visit(node Node) Visitor {
+	if node != nil {
+		c[node] = true
+	}
+	return c
+}
+
+// Filter returns a new comment map containing only the entries of cmap whose
+// nodes are within the tree of node.
+func (cmap CommentMap) Filter(node Node) CommentMap {
+	nodes := nodeCollector{}
+	Walk(nodes, node)
+	umap := CommentMap{}
+	for n := range nodes {
+		if list := cmap[n]; len(list) > 0 {
+			umap[n] = list
+		}
+	}
+	return umap
+}
+
+// Comments returns every comment group in the map, sorted by offset.
+func (cmap CommentMap) Comments() []*CommentGroup {
+	var list []*CommentGroup
+	for _, groups := range cmap {
+		list = append(list, groups...)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Offset() < list[j].Offset() })
+	return list
+}
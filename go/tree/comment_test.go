@@ -0,0 +1,130 @@
+package tree_test
+
+import (
+	"fmt"
+	"github.com/anaminus/luasyntax/go/tree"
+	"strings"
+	"testing"
+)
+
+// describeComments formats each comment group of cmap, sorted by offset, with
+// the node it is associated with.
+func describeComments(cmap tree.CommentMap) []string {
+	nodes := map[*tree.CommentGroup]tree.Node{}
+	for node, groups := range cmap {
+		for _, g := range groups {
+			nodes[g] = node
+		}
+	}
+	var s []string
+	for _, g := range cmap.Comments() {
+		node := nodes[g]
+		kind := "leading"
+		if g.Trailing {
+			kind = "trailing"
+		}
+		typ := strings.TrimPrefix(fmt.Sprintf("%T", node), "*tree.")
+		s = append(s, fmt.Sprintf("%s %q: %s %q", kind, g.Text(), typ, strings.TrimSpace(source(node))))
+	}
+	return s
+}
+
+func TestCommentMap(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{"x = 1", nil},
+		{
+			"-- a\nx = 1 -- b\n\n-- c\n-- d\ny = 2",
+			[]string{
+				`leading "a": AssignStmt "-- a\nx = 1"`,
+				`trailing "b": AssignStmt "-- a\nx = 1"`,
+				`leading "c\nd": AssignStmt "-- b\n\n-- c\n-- d\ny = 2"`,
+			},
+		},
+		{
+			"local t = {\n\t-- a\n\tx = 1, -- b\n\ty = 2 --[[c]]\n}",
+			[]string{
+				`leading "a": FieldEntry "-- a\n\tx = 1"`,
+				`trailing "b": FieldEntry "-- a\n\tx = 1"`,
+				`trailing "c": FieldEntry "-- b\n\ty = 2"`,
+			},
+		},
+		{
+			"f(a, --[[b]] c)",
+			[]string{`trailing "b": VariableExpr "a"`},
+		},
+		{
+			"if x then\n\t-- a\nend",
+			[]string{`leading "a": IfStmt "if x then\n\t-- a\nend"`},
+		},
+		{
+			"x = 1\n-- a",
+			[]string{`leading "a": File "x = 1\n-- a"`},
+		},
+	}
+	for _, test := range tests {
+		f := parseFile(t, test.src)
+		got := describeComments(tree.NewCommentMap(f))
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%q: expected\n%s\ngot\n%s", test.src, strings.Join(test.want, "\n"), strings.Join(got, "\n"))
+		}
+	}
+}
+
+func TestCommentGroup(t *testing.T) {
+	tests := []struct {
+		src  string
+		text string
+	}{
+		{"-- a\nx = 1", "a"},
+		{"--a  \n--  b\nx = 1", "a\n b"},
+		{"--[[\n a\n b\n]]\nx = 1", " a\n b"},
+		{"--[==[ a ]] ]==] x = 1", " a ]]"},
+		{"--\n-- a\n--\nx = 1", "a"},
+	}
+	for _, test := range tests {
+		f := parseFile(t, test.src)
+		groups := tree.NewCommentMap(f).Comments()
+		if len(groups) != 1 {
+			t.Errorf("%q: expected 1 group, got %d", test.src, len(groups))
+			continue
+		}
+		g := groups[0]
+		if text := g.Text(); text != test.text {
+			t.Errorf("%q: expected text %q, got %q", test.src, test.text, text)
+		}
+		var list []string
+		for _, p := range g.List() {
+			list = append(list, string(p.Bytes))
+		}
+		if got := test.src[g.Offset():g.EndOffset()]; got != strings.Join(list, "") {
+			t.Errorf("%q: expected range %q, got %q", test.src, strings.Join(list, ""), got)
+		}
+	}
+}
+
+func TestCommentMapUpdate(t *testing.T) {
+	f := parseFile(t, "-- a\nx = 1\n-- b\ny = 2")
+	cmap := tree.NewCommentMap(f)
+	x, y := f.Body.Items[0], f.Body.Items[1]
+
+	filtered := cmap.Filter(y)
+	if len(filtered) != 1 || len(filtered[y]) != 1 || filtered[y][0].Text() != "b" {
+		t.Errorf("expected comment b for y, got %v", describeComments(filtered))
+	}
+
+	if node := cmap.Update(x, y); node != y {
+		t.Errorf("expected new node, got %v", node)
+	}
+	if _, ok := cmap[x]; ok {
+		t.Error("expected old node to be removed")
+	}
+	if n := len(cmap[y]); n != 2 {
+		t.Errorf("expected 2 groups for new node, got %d", n)
+	}
+	if node := cmap.Update(x, y); node != y || len(cmap[y]) != 2 {
+		t.Error("expected update of node without comments to have no effect")
+	}
+}
@@ -1,66 +1,300 @@
 package tree
 
 import (
+	"bytes"
 	"errors"
 	"github.com/anaminus/luasyntax/go/token"
 	"math"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 // ParseValue parses the content of the number token and returns the resulting
-// value, or an error explaining why the value could not be parsed.
+// value, or an error explaining why the value could not be parsed. A 64-bit
+// integer number is converted to a float. An imaginary number cannot be
+// represented, and returns an error.
+//
+// As with lua_str2number, the number is read as a double. A hexadecimal number
+// may have a fractional part and binary exponent. A value out of range results
+// in an infinity or zero rather than an error.
 func (e *NumberExpr) ParseValue() (v float64, err error) {
+	b := stripDigitSeparators(e.NumberToken.Bytes)
 	switch e.NumberToken.Type {
 	case token.NUMBERFLOAT:
 		// Actual parsing of the number depends on the compiler (strtod), so
 		// technically it's correct to just use Go's parser.
-		v, err = strconv.ParseFloat(string(e.NumberToken.Bytes), 64)
+		v, err = parseFloat(string(b))
 	case token.NUMBERHEX:
+		// Go requires the binary exponent of a hexadecimal float.
+		if bytes.IndexAny(b, "pP") < 0 {
+			b = append(b[:len(b):len(b)], 'p', '0')
+		}
+		v, err = parseFloat(string(b))
+	case token.NUMBERBIN:
+		var i uint64
+		// Trim leading `0b`.
+		i, err = strconv.ParseUint(string(b[2:]), 2, 64)
+		v = float64(i)
+	case token.NUMBERI64:
+		var i int64
+		i, err = e.parseInt64()
+		v = float64(i)
+	case token.NUMBERU64:
 		var i uint64
-		// Trim leading `0x`.
-		i, err = strconv.ParseUint(string(e.NumberToken.Bytes[2:]), 16, 32)
+		i, err = e.parseUint64()
 		v = float64(i)
+	case token.NUMBERIMAG:
+		err = errors.New("imaginary number cannot be represented as a float")
 	default:
 		err = errors.New("'" + token.NUMBERFLOAT.String() + "' expected")
 	}
 	return
 }
 
+// parseFloat parses s as a 64-bit float. As with strtod, a value that is out
+// of range is not an error.
+func parseFloat(s string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err, ok := err.(*strconv.NumError); ok && err.Err == strconv.ErrRange {
+		return v, nil
+	}
+	return v, err
+}
+
+// ParseDialectConst parses the content of the number token according to
+// dialect d, and returns the resulting value as a typed constant, or an error
+// explaining why the value could not be parsed.
+//
+// If the dialect has integers, then a number without a fractional part or
+// exponent is returned as an int64. A hexadecimal integer wraps around on
+// overflow, while a decimal integer that overflows is returned as a float64.
+// Otherwise, the result is the same as ParseConst.
+func (e *NumberExpr) ParseDialectConst(d token.Dialect) (v interface{}, err error) {
+	if d.Has(token.Integers) {
+		b := stripDigitSeparators(e.NumberToken.Bytes)
+		switch e.NumberToken.Type {
+		case token.NUMBERFLOAT:
+			if bytes.IndexAny(b, ".eE") < 0 {
+				if i, err := strconv.ParseInt(string(b), 10, 64); err == nil {
+					return i, nil
+				}
+			}
+		case token.NUMBERHEX:
+			if bytes.IndexAny(b, ".pP") < 0 {
+				var i uint64
+				for _, c := range b[2:] {
+					i = i<<4 | uint64(hexVal(c))
+				}
+				return int64(i), nil
+			}
+		}
+	}
+	return e.ParseConst()
+}
+
+// ParseConst parses the content of the number token and returns the resulting
+// value as a typed constant, or an error explaining why the value could not be
+// parsed. The type of the constant depends on the type of the token:
+//
+//	NUMBERI64:  int64
+//	NUMBERU64:  uint64
+//	NUMBERIMAG: complex128
+//	otherwise:  float64
+func (e *NumberExpr) ParseConst() (v interface{}, err error) {
+	switch e.NumberToken.Type {
+	case token.NUMBERI64:
+		return e.parseInt64()
+	case token.NUMBERU64:
+		return e.parseUint64()
+	case token.NUMBERIMAG:
+		b := trimNumberSuffix(e.NumberToken.Bytes)
+		n := NumberExpr{NumberToken: Token{Type: token.NUMBERFLOAT, Bytes: b}}
+		if numberBase(b) == 'x' {
+			n.NumberToken.Type = token.NUMBERHEX
+		}
+		var f float64
+		if f, err = n.ParseValue(); err != nil {
+			return nil, err
+		}
+		return complex(0, f), nil
+	}
+	return e.ParseValue()
+}
+
+// parseUint64 parses the integer part of a 64-bit integer number token. As
+// with LuaJIT, a value that overflows 64 bits is an error.
+func (e *NumberExpr) parseUint64() (uint64, error) {
+	b := trimNumberSuffix(e.NumberToken.Bytes)
+	switch numberBase(b) {
+	case 'x':
+		return strconv.ParseUint(string(b[2:]), 16, 64)
+	case 'b':
+		return strconv.ParseUint(string(b[2:]), 2, 64)
+	}
+	return strconv.ParseUint(string(b), 10, 64)
+}
+
+// parseInt64 parses the integer part of a signed 64-bit integer number token.
+// Values between 2^63 and 2^64 wrap around, as with LuaJIT.
+func (e *NumberExpr) parseInt64() (int64, error) {
+	i, err := e.parseUint64()
+	return int64(i), err
+}
+
+// trimNumberSuffix returns b with any `LL`, `ULL` or `i` suffix removed.
+func trimNumberSuffix(b []byte) []byte {
+	n := len(b)
+	for n > 0 {
+		switch b[n-1] | 0x20 {
+		case 'l', 'u':
+			n--
+			continue
+		case 'i':
+			n--
+		}
+		break
+	}
+	return b[:n]
+}
+
+// numberBase returns 'x' if b is a hexadecimal number, 'b' if b is a binary
+// number, and 'd' otherwise.
+func numberBase(b []byte) byte {
+	if len(b) >= 2 && b[0] == '0' {
+		switch b[1] | 0x20 {
+		case 'x':
+			return 'x'
+		case 'b':
+			return 'b'
+		}
+	}
+	return 'd'
+}
+
+// stripDigitSeparators returns b with any `_` digit separators removed.
+func stripDigitSeparators(b []byte) []byte {
+	if bytes.IndexByte(b, '_') < 0 {
+		return b
+	}
+	c := make([]byte, 0, len(b))
+	for _, ch := range b {
+		if ch != '_' {
+			c = append(c, ch)
+		}
+	}
+	return c
+}
+
 // FormatValue formats the absolute value of a given number, setting the result
 // to the bytes of the token.
 //
 // When fmt is 'e', 'E', 'f', 'g', or 'G', the number is formatted as a float
 // with the NUMBERFLOAT type, and fmt and prec follow the same rules as in
-// strconv.ParseFloat.
+// strconv.FormatFloat.
+//
+// When fmt is 'r', the number is formatted with the NUMBERFLOAT type, using the
+// shortest representation that reads back as the same value. An integral value
+// may be formatted without a fractional part or exponent, which a dialect with
+// integers would read as an integer. When fmt is 'R', the result is the same,
+// except that the number is always formatted to be read as a float. The prec
+// argument is unused.
 //
 // When fmt is 'd', 'i', or 'u', the number is formatted as a base-10 integer
 // with the NUMBERFLOAT type. The prec argument is unused.
 //
-// When fmt is 'x' or 'X', the number is formatted as a base-16 number with the
-// NUMBERHEX type. The prec argument is unused.
+// When fmt is 'x' or 'X', the number is formatted as a base-16 integer with
+// the NUMBERHEX type. The prec argument is unused.
+//
+// When fmt is 'p', the number is formatted as a base-16 float with a binary
+// exponent, and the NUMBERHEX type. The prec argument follows the same rules
+// as the 'x' format of strconv.FormatFloat. Such numbers require a dialect
+// with hexadecimal floats.
+//
+// When fmt is 'b', the number is formatted as a base-2 number with the
+// NUMBERBIN type. The prec argument is unused.
 //
 // When fmt is 0, the format is determined by the current token type, and uses
-// the shortest representation of the number. The prec argument is unused.
+// the shortest representation of the number. A NUMBERFLOAT is formatted as
+// with 'R' if the current bytes of the token have a fractional part or
+// exponent, and 'r' otherwise. A NUMBERHEX that is not integral is formatted
+// as with 'p'. The prec argument is unused.
+//
+// A value that cannot be represented by an integer format, because it is not
+// integral or does not fit in 64 bits, is formatted as with 'p' for 'x', and
+// as with 'r' otherwise. An infinity is always formatted as 1e999, which reads
+// back as an infinity. A NaN cannot be represented by a number token, and
+// causes a panic with a *ValueError; the expression 0/0 may be used instead.
 func (e *NumberExpr) FormatValue(v float64, fmt byte, prec int) {
+	if math.IsNaN(v) {
+		panic(&ValueError{Msg: "cannot format NaN as a number"})
+	}
 	if math.Signbit(v) {
 		v = -v
 	}
 	switch fmt {
 	case 'e', 'E', 'f', 'g', 'G':
+		if math.IsInf(v, 0) {
+			e.FormatValue(v, 'r', -1)
+			break
+		}
+		e.NumberToken.Type = token.NUMBERFLOAT
+		e.NumberToken.Bytes = []byte(strconv.FormatFloat(v, fmt, prec, 64))
+	case 'r', 'R':
 		e.NumberToken.Type = token.NUMBERFLOAT
-		e.NumberToken.Bytes = []byte(strconv.FormatFloat(v, fmt, prec, 32))
+		e.NumberToken.Bytes = formatShortest(v, fmt == 'R')
+	case 'p':
+		if math.IsInf(v, 0) {
+			e.FormatValue(v, 'r', -1)
+			break
+		}
+		e.NumberToken.Type = token.NUMBERHEX
+		e.NumberToken.Bytes = formatHexFloat(v, prec)
 	case 'd', 'i', 'u':
+		if !isUint64(v) {
+			e.FormatValue(v, 'r', -1)
+			break
+		}
 		e.NumberToken.Type = token.NUMBERFLOAT
 		e.NumberToken.Bytes = []byte(strconv.FormatUint(uint64(v), 10))
 	case 'x', 'X':
+		if !isUint64(v) {
+			e.FormatValue(v, 'p', -1)
+			break
+		}
 		e.NumberToken.Type = token.NUMBERHEX
 		e.NumberToken.Bytes = []byte("0x" + strconv.FormatUint(uint64(v), 16))
+	case 'b':
+		if !isUint64(v) {
+			e.FormatValue(v, 'r', -1)
+			break
+		}
+		e.NumberToken.Type = token.NUMBERBIN
+		e.NumberToken.Bytes = []byte("0b" + strconv.FormatUint(uint64(v), 2))
 	case 0:
 		switch e.NumberToken.Type {
 		case token.NUMBERFLOAT:
-			e.NumberToken.Bytes = []byte(strconv.FormatFloat(v, 'g', -1, 32))
+			// Retain the appearance of a float.
+			float := bytes.ContainsAny(e.NumberToken.Bytes, ".eE")
+			e.NumberToken.Bytes = formatShortest(v, float)
 		case token.NUMBERHEX:
-			e.NumberToken.Bytes = []byte("0x" + strconv.FormatUint(uint64(uint32(v)), 16))
+			e.FormatValue(v, 'x', -1)
+		case token.NUMBERBIN:
+			e.FormatValue(v, 'b', -1)
+		case token.NUMBERI64:
+			if !isUint64(v) || v >= 1<<63 {
+				e.FormatValue(v, 'r', -1)
+				break
+			}
+			e.FormatConst(int64(v), 0, -1)
+		case token.NUMBERU64:
+			if !isUint64(v) {
+				e.FormatValue(v, 'r', -1)
+				break
+			}
+			e.FormatConst(uint64(v), 0, -1)
+		case token.NUMBERIMAG:
+			e.FormatConst(complex(0, v), 0, -1)
 		default:
 			panic("expected number token type")
 		}
@@ -69,57 +303,343 @@ func (e *NumberExpr) FormatValue(v float64, fmt byte, prec int) {
 	}
 }
 
-// parseQuotedString parses literal quoted string into actual text.
-func parseQuotedString(b []byte) string {
+// isUint64 returns whether v is integral and within the range of a uint64.
+func isUint64(v float64) bool {
+	return v >= 0 && v < 1<<64 && v == math.Trunc(v)
+}
+
+// trimExponent removes the sign of a positive exponent, and leading zeros
+// of the exponent, from a formatted float.
+func trimExponent(s string, e byte) string {
+	i := strings.IndexByte(s, e)
+	if i < 0 {
+		return s
+	}
+	exp := s[i+1:]
+	sign := ""
+	switch exp[0] {
+	case '-':
+		sign = "-"
+		fallthrough
+	case '+':
+		exp = exp[1:]
+	}
+	exp = strings.TrimLeft(exp, "0")
+	if exp == "" {
+		exp = "0"
+	}
+	return s[:i+1] + sign + exp
+}
+
+// formatShortest returns the shortest representation of v that reads back as
+// the same value. If float is true, then the result always has a fractional
+// part or exponent.
+func formatShortest(v float64, float bool) []byte {
+	if math.IsInf(v, 0) {
+		// Out of range, which reads back as an infinity.
+		return []byte("1e999")
+	}
+
+	// Plain decimal form.
+	best := strconv.FormatFloat(v, 'f', -1, 64)
+	if strings.HasPrefix(best, "0.") {
+		best = best[1:]
+	} else if float && strings.IndexByte(best, '.') < 0 {
+		best += "."
+	}
+
+	// Decomposed into the shortest digits and an exponent.
+	e := strconv.FormatFloat(v, 'e', -1, 64)
+	i := strings.IndexByte(e, 'e')
+	digits := strings.Replace(e[:i], ".", "", 1)
+	exp, _ := strconv.Atoi(e[i+1:])
+	candidates := []string{
+		// Scientific notation.
+		trimExponent(e, 'e'),
+		// Integral digits with an exponent.
+		digits + "e" + strconv.Itoa(exp-len(digits)+1),
+	}
+	for _, c := range candidates {
+		if len(c) < len(best) {
+			best = c
+		}
+	}
+	return []byte(best)
+}
+
+// formatHexFloat formats v as a hexadecimal float with a binary exponent.
+func formatHexFloat(v float64, prec int) []byte {
+	return []byte(trimExponent(strconv.FormatFloat(v, 'x', prec, 64), 'p'))
+}
+
+// FormatInt formats the absolute value of an integer, setting the result to the
+// bytes and type of the token. Unlike FormatConst, no suffix is added, which
+// is suitable for dialects with integers.
+//
+// When fmt is 'd', the integer is formatted in base-10 with the NUMBERFLOAT
+// type. Note that the absolute value of the minimum integer is read as a float
+// when written in base-10.
+//
+// When fmt is 'x' or 'X', the integer is formatted in base-16 with the
+// NUMBERHEX type.
+//
+// When fmt is 'b', the integer is formatted in base-2 with the NUMBERBIN type.
+//
+// When fmt is 0, the base is determined by the current bytes of the token.
+func (e *NumberExpr) FormatInt(v int64, fmt byte) {
+	if fmt == 0 {
+		fmt = numberBase(e.NumberToken.Bytes)
+	}
+	i := uint64(v)
+	if v < 0 {
+		i = -i
+	}
+	switch fmt {
+	case 'd':
+		e.NumberToken.Type = token.NUMBERFLOAT
+		e.NumberToken.Bytes = []byte(strconv.FormatUint(i, 10))
+	case 'x', 'X':
+		e.NumberToken.Type = token.NUMBERHEX
+		e.NumberToken.Bytes = []byte("0x" + strconv.FormatUint(i, 16))
+	case 'b':
+		e.NumberToken.Type = token.NUMBERBIN
+		e.NumberToken.Bytes = []byte("0b" + strconv.FormatUint(i, 2))
+	default:
+		panic("unexpected format")
+	}
+}
+
+// FormatConst formats the absolute value of a typed constant, setting the
+// result to the bytes and type of the token. The type of v determines the
+// type of the token:
+//
+//	int64:      NUMBERI64, with the `LL` suffix
+//	uint64:     NUMBERU64, with the `ULL` suffix
+//	complex128: NUMBERIMAG, with the `i` suffix
+//	float64:    same as FormatValue
+//
+// For 64-bit integers, fmt is 'd', 'x', 'X', or 'b', selecting the base of the
+// number. For imaginary numbers, the imaginary part is formatted according to
+// FormatValue, and the real part is ignored. When fmt is 0, the base is
+// determined by the current bytes of the token. The prec argument is used only
+// for floats.
+func (e *NumberExpr) FormatConst(v interface{}, fmt byte, prec int) {
+	if fmt == 0 {
+		fmt = numberBase(e.NumberToken.Bytes)
+	}
+	var i uint64
+	var suffix string
+	switch v := v.(type) {
+	case float64:
+		if fmt == 'd' {
+			fmt = 'g'
+		}
+		e.FormatValue(v, fmt, prec)
+		return
+	case complex128:
+		if fmt == 'd' {
+			fmt = 'g'
+		}
+		e.FormatValue(imag(v), fmt, prec)
+		e.NumberToken.Type = token.NUMBERIMAG
+		e.NumberToken.Bytes = append(e.NumberToken.Bytes, 'i')
+		return
+	case int64:
+		i = uint64(v)
+		if v < 0 {
+			i = -i
+		}
+		e.NumberToken.Type = token.NUMBERI64
+		suffix = "LL"
+	case uint64:
+		i = v
+		e.NumberToken.Type = token.NUMBERU64
+		suffix = "ULL"
+	default:
+		panic("unexpected constant type")
+	}
+	switch fmt {
+	case 'd':
+		e.NumberToken.Bytes = []byte(strconv.FormatUint(i, 10) + suffix)
+	case 'x', 'X':
+		e.NumberToken.Bytes = []byte("0x" + strconv.FormatUint(i, 16) + suffix)
+	case 'b':
+		e.NumberToken.Bytes = []byte("0b" + strconv.FormatUint(i, 2) + suffix)
+	default:
+		panic("unexpected format")
+	}
+}
+
+// ValueError describes a problem with the content of a token that prevents
+// its value from being parsed.
+type ValueError struct {
+	// Offset is the offset in the source of the part of the token that caused
+	// the problem.
+	Offset int
+	// Msg describes the problem.
+	Msg string
+}
+
+// Error implements the error interface.
+func (err *ValueError) Error() string {
+	return err.Msg
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c|0x20 && c|0x20 <= 'f'
+}
+
+func hexVal(c byte) int64 {
+	if c <= '9' {
+		return int64(c - '0')
+	}
+	return int64(c|0x20-'a') + 10
+}
+
+func isSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', '\f', '\v':
+		return true
+	}
+	return false
+}
+
+// appendUTF8 appends the UTF-8 encoding of x to b. Unlike the utf8 package,
+// surrogates are encoded as-is, and values up to 2^31 are encoded using the
+// original 6-byte form of UTF-8, as Lua does.
+func appendUTF8(b []byte, x int64) []byte {
+	if x < 0x80 {
+		return append(b, byte(x))
+	}
+	var buf [6]byte
+	n := len(buf)
+	mfb := int64(0x3f) // Maximum that fits in the first byte.
+	for x > mfb {
+		n--
+		buf[n] = byte(0x80 | x&0x3f)
+		x >>= 6
+		mfb >>= 1
+	}
+	n--
+	buf[n] = byte(^mfb<<1 | x)
+	return append(b, buf[n:]...)
+}
+
+// parseQuotedString parses a literal quoted string into actual text, according
+// to the escape sequences of dialect d. The off argument is the offset of the
+// string in the source, which is used for errors.
+func parseQuotedString(b []byte, d token.Dialect, off int) (string, error) {
 	b = b[1 : len(b)-1]          // Trim quotes.
 	c := make([]byte, 0, len(b)) // Result will never be larger than source.
 	for i := 0; i < len(b); i++ {
 		ch := b[i]
-		if ch == '\\' {
-			i++
-			ch = b[i]
-			switch ch {
-			case 'a':
-				ch = '\a'
-			case 'b':
-				ch = '\b'
-			case 'f':
-				ch = '\f'
-			case 'n':
-				ch = '\n'
-			case 'r':
-				ch = '\r'
-			case 't':
-				ch = '\t'
-			case 'v':
-				ch = '\v'
-			default:
-				if '0' <= ch && ch <= '9' {
-					var n byte
-					for j := 0; j < 3 && '0' <= b[i] && b[i] <= '9'; j++ {
-						n = n*10 + (b[i] - '0')
-						i++
-					}
-					// Size of number was already checked by scanner.
-					ch = n
+		if ch != '\\' {
+			c = append(c, ch)
+			continue
+		}
+		esc := off + 1 + i
+		if i++; i >= len(b) {
+			return "", &ValueError{Offset: esc, Msg: "unfinished string"}
+		}
+		switch ch = b[i]; ch {
+		case 'a':
+			c = append(c, '\a')
+		case 'b':
+			c = append(c, '\b')
+		case 'f':
+			c = append(c, '\f')
+		case 'n':
+			c = append(c, '\n')
+		case 'r':
+			c = append(c, '\r')
+		case 't':
+			c = append(c, '\t')
+		case 'v':
+			c = append(c, '\v')
+		case '\\', '"', '\'':
+			c = append(c, ch)
+		case '\n', '\r':
+			// Escaped newline; a two-character sequence counts as one.
+			if i+1 < len(b) && (b[i+1] == '\n' || b[i+1] == '\r') && b[i+1] != ch {
+				i++
+			}
+			c = append(c, '\n')
+		case 'x':
+			if !d.Has(token.HexEscape) {
+				goto other
+			}
+			if i+2 >= len(b) || !isHexDigit(b[i+1]) || !isHexDigit(b[i+2]) {
+				return "", &ValueError{Offset: esc, Msg: "hexadecimal digit expected"}
+			}
+			c = append(c, byte(hexVal(b[i+1])<<4|hexVal(b[i+2])))
+			i += 2
+		case 'z':
+			if !d.Has(token.SkipEscape) {
+				goto other
+			}
+			for i+1 < len(b) && isSpace(b[i+1]) {
+				i++
+			}
+		case 'u':
+			if !d.Has(token.UnicodeEscape) {
+				goto other
+			}
+			if i++; i >= len(b) || b[i] != '{' {
+				return "", &ValueError{Offset: esc, Msg: "missing '{' in \\u{xxxx}"}
+			}
+			if i++; i >= len(b) || !isHexDigit(b[i]) {
+				return "", &ValueError{Offset: esc, Msg: "hexadecimal digit expected"}
+			}
+			max := int64(0x10FFFF)
+			if d.Has(token.LongUTF8) {
+				max = 0x7FFFFFFF
+			}
+			var r int64
+			for ; i < len(b) && isHexDigit(b[i]); i++ {
+				if r = r<<4 | hexVal(b[i]); r > max {
+					return "", &ValueError{Offset: esc, Msg: "UTF-8 value too large"}
 				}
 			}
+			if i >= len(b) || b[i] != '}' {
+				return "", &ValueError{Offset: esc, Msg: "missing '}' in \\u{xxxx}"}
+			}
+			c = appendUTF8(c, r)
+		default:
+			if !isDigit(ch) {
+				goto other
+			}
+			n := 0
+			for j := 0; j < 3 && i < len(b) && isDigit(b[i]); j++ {
+				n = n*10 + int(b[i]-'0')
+				i++
+			}
+			i--
+			if n > 255 {
+				return "", &ValueError{Offset: esc, Msg: "decimal escape too large"}
+			}
+			c = append(c, byte(n))
 		}
+		continue
+	other:
+		if d.Has(token.StrictEscape) {
+			return "", &ValueError{Offset: esc, Msg: "invalid escape sequence"}
+		}
+		// The character is escaped as itself.
 		c = append(c, ch)
 	}
-	return string(c)
+	return string(c), nil
 }
 
-// parseBlockString parses a literal long string into actual text.
+// parseBlockString parses a literal long string into actual text. As with Lua,
+// each newline sequence within the string is converted to a single '\n'.
 func parseBlockString(b []byte) string {
 	// Assumes string is wrapped in a `[==[]==]`-like block.
-	b = b[1:] // Trim first `[`
-	for i, c := range b {
-		if c == '[' {
-			// Trim to second '[', as well as trailing block.
-			b = b[i+1 : len(b)-i-2]
-		}
-	}
+	level := bytes.IndexByte(b[1:], '[') + 2
+	b = b[level : len(b)-level]
 	// Skip first newline.
 	if len(b) > 0 && (b[0] == '\n' || b[0] == '\r') {
 		if len(b) > 1 && (b[1] == '\n' || b[1] == '\r') && b[1] != b[0] {
@@ -128,15 +648,39 @@ func parseBlockString(b []byte) string {
 			b = b[1:]
 		}
 	}
-	return string(b)
+	if bytes.IndexByte(b, '\r') < 0 {
+		return string(b)
+	}
+	c := make([]byte, 0, len(b))
+	for i := 0; i < len(b); i++ {
+		switch ch := b[i]; ch {
+		case '\n', '\r':
+			if i+1 < len(b) && (b[i+1] == '\n' || b[i+1] == '\r') && b[i+1] != ch {
+				i++
+			}
+			c = append(c, '\n')
+		default:
+			c = append(c, ch)
+		}
+	}
+	return string(c)
 }
 
 // ParseValue parses the content of the string token and returns the resulting
-// value, or an error explaining why the value could not be parsed.
+// value, or an error explaining why the value could not be parsed. Escape
+// sequences are decoded according to Lua 5.1.
 func (e *StringExpr) ParseValue() (v string, err error) {
+	return e.ParseDialectValue(token.Lua51)
+}
+
+// ParseDialectValue parses the content of the string token and returns the
+// resulting value, or an error explaining why the value could not be parsed.
+// Escape sequences are decoded according to dialect d. An error caused by an
+// escape sequence is a *ValueError, with the offset of the sequence.
+func (e *StringExpr) ParseDialectValue(d token.Dialect) (v string, err error) {
 	switch e.StringToken.Type {
 	case token.STRING:
-		v = parseQuotedString(e.StringToken.Bytes)
+		v, err = parseQuotedString(e.StringToken.Bytes, d, e.StringToken.Offset)
 	case token.LONGSTRING:
 		v = parseBlockString(e.StringToken.Bytes)
 	default:
@@ -146,144 +690,164 @@ func (e *StringExpr) ParseValue() (v string, err error) {
 }
 
 // formatString formats a string in a form suitable to be safely read by a Lua
-// interpreter. The result string is enclosed in double quotes, and all double
-// quotes, newlines, embedded zeros, and backslashes are properly escaped.
-func formatString(src []byte) (dst []byte) {
-	if len(src) == 0 {
-		return []byte(`""`)
-	}
-
-	// Calculate size of dst and allocate.
-	size := len(src) + 2
-	for _, c := range src {
+// interpreter. The result string is enclosed in the given quote character. The
+// quote character, backslashes, and control characters are escaped, using the
+// shortest escape sequence available. Bytes that are not part of a valid UTF-8
+// sequence are escaped, so that the result remains valid UTF-8 text.
+func formatString(src []byte, quote byte) (dst []byte) {
+	dst = make([]byte, 0, len(src)+2)
+	dst = append(dst, quote)
+	for i := 0; i < len(src); {
+		c := src[i]
+		if c >= utf8.RuneSelf {
+			if r, n := utf8.DecodeRune(src[i:]); r != utf8.RuneError || n > 1 {
+				dst = append(dst, src[i:i+n]...)
+				i += n
+				continue
+			}
+		}
+		i++
 		switch c {
-		case 0, '\n', '"', '\\':
-			size++
-		}
-	}
-	dst = make([]byte, size)
-
-	// Fill in dst.
-	dst[0] = '"'
-	dst[len(dst)-1] = '"'
-	h := 0
-	for i, j := 0, 1; i < len(src); i, j = i+1, j+1 {
-		switch c := src[i]; c {
-		case 0:
-			c = '0'
-			fallthrough
-		case '\n', '"', '\\':
-			copy(dst[j-(i-h):j], src[h:i])
-			h = i + 1
-			dst[j] = '\\'
-			j++
-			dst[j] = c
-		}
-	}
-	copy(dst[len(dst)-1-(len(src)-h):], src[h:])
+		case quote, '\\':
+			dst = append(dst, '\\', c)
+			continue
+		case '\a':
+			dst = append(dst, '\\', 'a')
+			continue
+		case '\b':
+			dst = append(dst, '\\', 'b')
+			continue
+		case '\f':
+			dst = append(dst, '\\', 'f')
+			continue
+		case '\n':
+			dst = append(dst, '\\', 'n')
+			continue
+		case '\r':
+			dst = append(dst, '\\', 'r')
+			continue
+		case '\t':
+			dst = append(dst, '\\', 't')
+			continue
+		case '\v':
+			dst = append(dst, '\\', 'v')
+			continue
+		}
+		if c >= ' ' && c < 0x7F {
+			dst = append(dst, c)
+			continue
+		}
+		// A decimal escape is never longer than a hexadecimal escape.
+		dst = append(dst, '\\')
+		if i < len(src) && isDigit(src[i]) {
+			// Pad so that the following digit is not part of the escape.
+			dst = append(dst, '0'+c/100, '0'+c/10%10, '0'+c%10)
+		} else {
+			dst = strconv.AppendUint(dst, uint64(c), 10)
+		}
+	}
+	dst = append(dst, quote)
 	return
 }
 
-// findFirstNewline scans for the first newline in a text. Returns a slice of
-// the newline (which will have a length of 1 or 2) and whether it is at the
-// start of the text. Returns an empty slice if no newline was found.
-func findFirstNewline(src []byte) ([]byte, bool) {
-	for i := 0; i < len(src); i++ {
-		if src[i] == '\n' || src[i] == '\r' {
-			if i+1 < len(src) && (src[i+1] == '\n' || src[i+1] == '\r') && src[i+1] != src[i] {
-				return src[i : i+2], i == 0
-			} else {
-				return src[i : i+1], i == 0
-			}
+// canFormatBlockString returns whether a string can be represented as a long
+// string. Because newline sequences within a long string are normalized, a
+// string containing a carriage return cannot be represented. As with
+// formatString, a string containing other control characters or invalid UTF-8
+// is not represented literally.
+func canFormatBlockString(src []byte) bool {
+	for _, c := range src {
+		if c < ' ' && c != '\n' && c != '\t' || c == 0x7F {
+			return false
 		}
 	}
-	return nil, false
+	return utf8.Valid(src)
 }
 
 // formatBlockString formats a string by enclosing it in long brackets.
 func formatBlockString(src []byte, newline bool) (dst []byte) {
-	if len(src) == 0 {
-		return []byte(`[[]]`)
-	}
-
-	// Find shortest closing bracket not in the string.
+	// Find the shortest closing bracket such that the first occurrence of the
+	// bracket, when appended to the string, is at the end.
 	eq := 0
-loop:
-	for i := 0; i < len(src); i++ {
-		if src[i] == ']' {
-			i++
-			count := 0
-			for ; src[i] == '='; i++ {
-				count++
-			}
-			if src[i] == ']' && count == eq {
-				eq++
-				goto loop
-			}
+	for {
+		closing := append([]byte{']'}, bytes.Repeat([]byte{'='}, eq)...)
+		closing = append(closing, ']')
+		if bytes.Index(append(src[:len(src):len(src)], closing...), closing) == len(src) {
+			break
 		}
+		eq++
 	}
 
-	// Decide whether a leading newline must be inserted.
-	nl, atStart := findFirstNewline(src)
-	if len(nl) > 0 && atStart {
-		// Newline at start; insert unconditionally.
-	} else if newline {
-		// Otherwise, insert only if specified.
-		if len(nl) == 0 {
-			// Ensure that there is a newline to insert.
-			nl = []byte{'\n'}
-		}
-	} else {
-		// Insert nothing.
-		nl = []byte{}
+	// Decide whether a leading newline must be inserted. A newline at the
+	// start of the string would otherwise be skipped by the parser.
+	if len(src) > 0 && src[0] == '\n' {
+		newline = true
 	}
 
-	// Allocate result.
-	dstSize := 1 + eq + 1 + len(src) + 1 + eq + 1 + len(nl) // [==[src]==]\n
-	dst = make([]byte, dstSize)
-
-	// Fill in long brackets.
-	openBrack := dst[:eq+2]
-	openBrack[0] = '['
-	openBrack[len(openBrack)-1] = '['
-	closeBrack := dst[len(dst)-eq-2:]
-	closeBrack[0] = ']'
-	closeBrack[len(closeBrack)-1] = ']'
-	for i := 1; i < eq+1; i++ {
-		openBrack[i] = '='
-		closeBrack[i] = '='
+	dst = make([]byte, 0, len(src)+2*eq+5)
+	dst = append(dst, '[')
+	dst = append(dst, bytes.Repeat([]byte{'='}, eq)...)
+	dst = append(dst, '[')
+	if newline {
+		dst = append(dst, '\n')
 	}
-
-	// Fill in newline.
-	copy(dst[len(openBrack):], nl)
-
-	// Fill in enclosed string.
-	copy(dst[len(openBrack)+len(nl):], src)
-
+	dst = append(dst, src...)
+	dst = append(dst, ']')
+	dst = append(dst, bytes.Repeat([]byte{'='}, eq)...)
+	dst = append(dst, ']')
 	return
 }
 
 // FormatValue receives a string and formats it, setting it to the bytes of the
 // token. The format is determined by the current token type.
 //
-// When the token is a STRING, the result is the same as the "%q" verb in Lua's
-// string.format.
+// When the token is a STRING, the result is enclosed in the same quote
+// character as the current bytes of the token, or double quotes if there are
+// none. Characters that cannot appear literally are escaped.
 //
 // When the token is a LONGSTRING, the result is enclosed in the shortest
 // possible set of long brackets. If the newline argument is true, then the
 // result will be formatted with a newline at the start, which is ignored by the
-// parser.
+// parser. If the value cannot be represented as a long string, then the token
+// is changed to a STRING.
 func (e *StringExpr) FormatValue(v string, newline bool) {
 	switch e.StringToken.Type {
 	case token.STRING:
-		e.StringToken.Bytes = formatString(e.StringToken.Bytes)
+		quote := byte('"')
+		if b := e.StringToken.Bytes; len(b) > 0 && b[0] == '\'' {
+			quote = '\''
+		}
+		e.StringToken.Bytes = formatString([]byte(v), quote)
 	case token.LONGSTRING:
-		e.StringToken.Bytes = formatBlockString(e.StringToken.Bytes, newline)
+		if !canFormatBlockString([]byte(v)) {
+			e.StringToken.Type = token.STRING
+			e.StringToken.Bytes = formatString([]byte(v), '"')
+			break
+		}
+		e.StringToken.Bytes = formatBlockString([]byte(v), newline)
 	default:
 		panic("expected string token type")
 	}
 }
 
+// FormatShortest receives a string and formats it, setting the type and bytes
+// of the token to the shortest representation of the string. Double quotes,
+// single quotes, and long brackets are tried, in that order of preference.
+func (e *StringExpr) FormatShortest(v string) {
+	src := []byte(v)
+	e.StringToken.Type = token.STRING
+	e.StringToken.Bytes = formatString(src, '"')
+	if b := formatString(src, '\''); len(b) < len(e.StringToken.Bytes) {
+		e.StringToken.Bytes = b
+	}
+	if canFormatBlockString(src) {
+		if b := formatBlockString(src, false); len(b) < len(e.StringToken.Bytes) {
+			e.StringToken.Type = token.LONGSTRING
+			e.StringToken.Bytes = b
+		}
+	}
+}
+
 // ParseValue parses the content of the boolean token and returns the resulting
 // value, or an error explaining why the value could not be parsed.
 func (e *BoolExpr) ParseValue() (v bool, err error) {
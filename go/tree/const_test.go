@@ -0,0 +1,436 @@
+package tree_test
+
+import (
+	"fmt"
+	"github.com/anaminus/luasyntax/go/parser"
+	"github.com/anaminus/luasyntax/go/token"
+	"github.com/anaminus/luasyntax/go/tree"
+	"math"
+	"testing"
+)
+
+func number(typ token.Type, s string) *tree.NumberExpr {
+	return &tree.NumberExpr{NumberToken: tree.Token{Type: typ, Bytes: []byte(s)}}
+}
+
+func TestParseConst(t *testing.T) {
+	tests := []struct {
+		typ  token.Type
+		src  string
+		want interface{}
+	}{
+		{token.NUMBERI64, "123LL", int64(123)},
+		{token.NUMBERI64, "0x10ll", int64(16)},
+		{token.NUMBERI64, "0x7FFFFFFFFFFFFFFFLL", int64(1<<63 - 1)},
+		{token.NUMBERI64, "0xFFFFFFFFFFFFFFFFLL", int64(-1)},
+		{token.NUMBERI64, "9223372036854775808LL", int64(-1 << 63)},
+		{token.NUMBERI64, "0b101LL", int64(5)},
+		{token.NUMBERU64, "123ULL", uint64(123)},
+		{token.NUMBERU64, "18446744073709551615ull", uint64(1<<64 - 1)},
+		{token.NUMBERU64, "0xFFuLL", uint64(255)},
+		{token.NUMBERIMAG, "12i", complex(0, 12)},
+		{token.NUMBERIMAG, "1.5e2I", complex(0, 150)},
+		{token.NUMBERIMAG, ".5i", complex(0, .5)},
+		{token.NUMBERIMAG, "0x10i", complex(0, 16)},
+		{token.NUMBERFLOAT, "1.5", 1.5},
+		{token.NUMBERBIN, "0b11", float64(3)},
+	}
+	for _, test := range tests {
+		v, err := number(test.typ, test.src).ParseConst()
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", test.src, err)
+			continue
+		}
+		if v != test.want {
+			t.Errorf("%q: expected %T(%v), got %T(%v)", test.src, test.want, test.want, v, v)
+		}
+	}
+
+	for _, src := range []string{"18446744073709551616ULL", "0x10000000000000000LL"} {
+		if _, err := number(token.NUMBERU64, src).ParseConst(); err == nil {
+			t.Errorf("%q: expected error", src)
+		}
+	}
+}
+
+func TestFormatConst(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		fmt  byte
+		typ  token.Type
+		want string
+	}{
+		{int64(123), 'd', token.NUMBERI64, "123LL"},
+		{int64(-255), 'x', token.NUMBERI64, "0xffLL"},
+		{int64(5), 'b', token.NUMBERI64, "0b101LL"},
+		{int64(-1 << 63), 'd', token.NUMBERI64, "9223372036854775808LL"},
+		{uint64(1 << 63), 'd', token.NUMBERU64, "9223372036854775808ULL"},
+		{uint64(255), 'X', token.NUMBERU64, "0xffULL"},
+		{complex(0, 12), 'd', token.NUMBERIMAG, "12i"},
+		{complex(3, 0.5), 'g', token.NUMBERIMAG, "0.5i"},
+		{1.5, 'd', token.NUMBERFLOAT, "1.5"},
+	}
+	for _, test := range tests {
+		n := &tree.NumberExpr{}
+		n.FormatConst(test.v, test.fmt, -1)
+		if n.NumberToken.Type != test.typ || string(n.NumberToken.Bytes) != test.want {
+			t.Errorf("%v %c: expected %s %q, got %s %q", test.v, test.fmt,
+				test.typ, test.want, n.NumberToken.Type, n.NumberToken.Bytes)
+		}
+	}
+}
+
+func TestConstRoundTrip(t *testing.T) {
+	values := []interface{}{
+		int64(0), int64(1), int64(1<<63 - 1), int64(-1 << 63),
+		uint64(0), uint64(1<<64 - 1),
+		complex(0, 1), complex(0, 0.1), complex(0, 1e300),
+	}
+	for _, v := range values {
+		for _, fmt := range []byte{'d', 'x', 'b'} {
+			if _, ok := v.(complex128); ok && fmt != 'd' {
+				continue
+			}
+			n := &tree.NumberExpr{}
+			n.FormatConst(v, fmt, -1)
+			got, err := n.ParseConst()
+			if err != nil {
+				t.Errorf("%v %c: unexpected error %s", v, fmt, err)
+				continue
+			}
+			if got != v {
+				t.Errorf("%v %c: %q parsed as %v", v, fmt, n.NumberToken.Bytes, got)
+			}
+		}
+	}
+}
+
+func TestStringParseDialectValue(t *testing.T) {
+	tests := []struct {
+		dialect token.Dialect
+		typ     token.Type
+		src     string
+		want    string
+	}{
+		{token.Lua51, token.STRING, `"a\nb\tc\\\"\'"`, "a\nb\tc\\\"'"},
+		{token.Lua51, token.STRING, `"\65\066\0677"`, "ABC7"},
+		{token.Lua51, token.STRING, `"\x41"`, "x41"},
+		{token.Lua52, token.STRING, `"\x41"`, "A"},
+		{token.Lua52, token.STRING, "\"\\x41\\z   \n\t  b\"", "Ab"},
+		{token.Lua53, token.STRING, `"\u{48}\u{20AC}\u{10FFFF}"`, "H€\U0010FFFF"},
+		{token.Lua53, token.STRING, `"\u{D800}"`, "\xed\xa0\x80"},
+		{token.Lua54, token.STRING, `"\u{7FFFFFFF}"`, "\xfd\xbf\xbf\xbf\xbf\xbf"},
+		{token.Lua51, token.STRING, "\"a\\\r\nb\"", "a\nb"},
+		{token.Lua51, token.LONGSTRING, "[==[\r\na]]b\r\nc\n\rd]==]", "a]]b\nc\nd"},
+		{token.Lua51, token.LONGSTRING, "[[x[y]]", "x[y"},
+	}
+	for _, test := range tests {
+		e := &tree.StringExpr{StringToken: tree.Token{Type: test.typ, Bytes: []byte(test.src)}}
+		v, err := e.ParseDialectValue(test.dialect)
+		if err != nil {
+			t.Errorf("%s %q: unexpected error %s", test.dialect, test.src, err)
+			continue
+		}
+		if v != test.want {
+			t.Errorf("%s %q: expected %q, got %q", test.dialect, test.src, test.want, v)
+		}
+	}
+}
+
+func TestStringValueError(t *testing.T) {
+	tests := []struct {
+		dialect token.Dialect
+		src     string
+		offset  int
+	}{
+		{token.Lua52, `"ab\xZZ"`, 13},
+		{token.Lua53, `"\u{110000000}"`, 11},
+		{token.Lua51, `"a\256"`, 12},
+	}
+	for _, test := range tests {
+		e := &tree.StringExpr{StringToken: tree.Token{Type: token.STRING, Offset: 10, Bytes: []byte(test.src)}}
+		_, err := e.ParseDialectValue(test.dialect)
+		verr, ok := err.(*tree.ValueError)
+		if !ok {
+			t.Errorf("%s %q: expected *tree.ValueError, got %T", test.dialect, test.src, err)
+			continue
+		}
+		if verr.Offset != test.offset {
+			t.Errorf("%s %q: expected offset %d, got %d", test.dialect, test.src, test.offset, verr.Offset)
+		}
+	}
+}
+
+// parseString parses s as a string expression according to dialect d, and
+// returns the value of the string.
+func parseString(d token.Dialect, s string) (string, error) {
+	expr, err := (&parser.Config{Dialect: d}).ParseExpr("", s)
+	if err != nil {
+		return "", err
+	}
+	e, ok := expr.(*tree.StringExpr)
+	if !ok {
+		return "", fmt.Errorf("expected *tree.StringExpr, got %T", expr)
+	}
+	return e.ParseDialectValue(d)
+}
+
+func TestStringFormatValue(t *testing.T) {
+	values := []string{
+		"", "hello", "a\"b", "a'b", "a\"'b", "\x00\x01\x7f\xff", "\x001",
+		"line\nline", "\r\n", "]]", "a]", "\nx", "é€", "\\", "]=]]",
+	}
+	dialects := []token.Dialect{token.Lua51, token.Lua54, token.Luau}
+	for _, v := range values {
+		var exprs []*tree.StringExpr
+		for _, typ := range []token.Type{token.STRING, token.LONGSTRING} {
+			e := &tree.StringExpr{StringToken: tree.Token{Type: typ}}
+			e.FormatValue(v, false)
+			exprs = append(exprs, e)
+		}
+		e := &tree.StringExpr{}
+		e.FormatShortest(v)
+		exprs = append(exprs, e)
+		for _, e := range exprs {
+			for _, d := range dialects {
+				got, err := parseString(d, string(e.StringToken.Bytes))
+				if err != nil {
+					t.Errorf("%s %q: %q: unexpected error %s", d, v, e.StringToken.Bytes, err)
+					continue
+				}
+				if got != v {
+					t.Errorf("%s %q: %q parsed as %q", d, v, e.StringToken.Bytes, got)
+				}
+			}
+		}
+	}
+}
+
+func TestStringFormatShortest(t *testing.T) {
+	tests := []struct {
+		v    string
+		want string
+	}{
+		{"hello", `"hello"`},
+		{`a"b`, `'a"b'`},
+		{"a'b", `"a'b"`},
+		{"a\nb", `"a\nb"`},
+	}
+	for _, test := range tests {
+		e := &tree.StringExpr{}
+		e.FormatShortest(test.v)
+		if got := string(e.StringToken.Bytes); got != test.want {
+			t.Errorf("%q: expected %s, got %s", test.v, test.want, got)
+		}
+	}
+}
+
+func TestNumberParseValue(t *testing.T) {
+	tests := []struct {
+		typ  token.Type
+		src  string
+		want float64
+	}{
+		{token.NUMBERFLOAT, "1e300", 1e300},
+		{token.NUMBERFLOAT, "1e400", math.Inf(1)},
+		{token.NUMBERFLOAT, "1e-400", 0},
+		{token.NUMBERFLOAT, ".5", 0.5},
+		{token.NUMBERFLOAT, "3.", 3},
+		{token.NUMBERHEX, "0xFFFFFFFFFF", 0xFFFFFFFFFF},
+		{token.NUMBERHEX, "0xFFFFFFFFFFFFFFFF", 1 << 64},
+		{token.NUMBERHEX, "0x1.8p3", 12},
+		{token.NUMBERHEX, "0x.8", 0.5},
+		{token.NUMBERHEX, "0xA.8P-1", 5.25},
+		{token.NUMBERBIN, "0b101", 5},
+	}
+	for _, test := range tests {
+		v, err := number(test.typ, test.src).ParseValue()
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", test.src, err)
+			continue
+		}
+		if v != test.want {
+			t.Errorf("%q: expected %v, got %v", test.src, test.want, v)
+		}
+	}
+	if _, err := number(token.NUMBERIMAG, "1i").ParseValue(); err == nil {
+		t.Errorf("%q: expected error", "1i")
+	}
+}
+
+func TestNumberParseDialectConst(t *testing.T) {
+	tests := []struct {
+		dialect token.Dialect
+		typ     token.Type
+		src     string
+		want    interface{}
+	}{
+		{token.Lua51, token.NUMBERFLOAT, "3", 3.0},
+		{token.Lua51, token.NUMBERHEX, "0x10", 16.0},
+		{token.Lua53, token.NUMBERFLOAT, "3", int64(3)},
+		{token.Lua53, token.NUMBERFLOAT, "3.0", 3.0},
+		{token.Lua53, token.NUMBERFLOAT, "3e0", 3.0},
+		{token.Lua53, token.NUMBERFLOAT, "9223372036854775807", int64(math.MaxInt64)},
+		{token.Lua53, token.NUMBERFLOAT, "9223372036854775808", 9223372036854775808.0},
+		{token.Lua53, token.NUMBERHEX, "0xFFFFFFFFFFFFFFFF", int64(-1)},
+		{token.Lua53, token.NUMBERHEX, "0x10000000000000001", int64(1)},
+		{token.Lua53, token.NUMBERHEX, "0x1p4", 16.0},
+		{token.Lua54, token.NUMBERHEX, "0x.8", 0.5},
+	}
+	for _, test := range tests {
+		v, err := number(test.typ, test.src).ParseDialectConst(test.dialect)
+		if err != nil {
+			t.Errorf("%s %q: unexpected error %s", test.dialect, test.src, err)
+			continue
+		}
+		if v != test.want {
+			t.Errorf("%s %q: expected %T(%v), got %T(%v)", test.dialect, test.src, test.want, test.want, v, v)
+		}
+	}
+}
+
+func TestNumberFormatValue(t *testing.T) {
+	tests := []struct {
+		v    float64
+		fmt  byte
+		typ  token.Type
+		want string
+	}{
+		{1.5, 'g', token.NUMBERFLOAT, "1.5"},
+		{-2, 'f', token.NUMBERFLOAT, "2"},
+		{3, 'r', token.NUMBERFLOAT, "3"},
+		{3, 'R', token.NUMBERFLOAT, "3."},
+		{0.1, 'r', token.NUMBERFLOAT, ".1"},
+		{1e21, 'r', token.NUMBERFLOAT, "1e21"},
+		{255, 'd', token.NUMBERFLOAT, "255"},
+		{255, 'x', token.NUMBERHEX, "0xff"},
+		{5, 'b', token.NUMBERBIN, "0b101"},
+		{12, 'p', token.NUMBERHEX, "0x1.8p3"},
+
+		// Infinities.
+		{math.Inf(1), 'r', token.NUMBERFLOAT, "1e999"},
+		{math.Inf(-1), 'R', token.NUMBERFLOAT, "1e999"},
+		{math.Inf(1), 'g', token.NUMBERFLOAT, "1e999"},
+		{math.Inf(1), 'p', token.NUMBERFLOAT, "1e999"},
+		{math.Inf(1), 'd', token.NUMBERFLOAT, "1e999"},
+		{math.Inf(1), 'x', token.NUMBERFLOAT, "1e999"},
+
+		// Values outside the range of integer formats.
+		{1.5, 'd', token.NUMBERFLOAT, "1.5"},
+		{1 << 64, 'd', token.NUMBERFLOAT, "18446744073709552e3"},
+		{1 << 64, 'b', token.NUMBERFLOAT, "18446744073709552e3"},
+		{1.5, 'x', token.NUMBERHEX, "0x1.8p0"},
+		{1 << 64, 'x', token.NUMBERHEX, "0x1p64"},
+	}
+	for _, test := range tests {
+		n := &tree.NumberExpr{}
+		n.FormatValue(test.v, test.fmt, -1)
+		if n.NumberToken.Type != test.typ || string(n.NumberToken.Bytes) != test.want {
+			t.Errorf("%v %c: expected %s %q, got %s %q", test.v, test.fmt,
+				test.typ, test.want, n.NumberToken.Type, n.NumberToken.Bytes)
+		}
+	}
+}
+
+func TestNumberFormatValueCurrent(t *testing.T) {
+	tests := []struct {
+		typ  token.Type
+		src  string
+		v    float64
+		want string
+	}{
+		{token.NUMBERFLOAT, "1", 3, "3"},
+		{token.NUMBERFLOAT, "1.0", 3, "3."},
+		{token.NUMBERFLOAT, "1e0", 3, "3."},
+		{token.NUMBERHEX, "0x1", 255, "0xff"},
+		{token.NUMBERHEX, "0x1", 0.5, "0x1p-1"},
+		{token.NUMBERBIN, "0b1", 5, "0b101"},
+		{token.NUMBERBIN, "0b1", 0.5, ".5"},
+		{token.NUMBERI64, "1LL", 255, "255LL"},
+		{token.NUMBERI64, "0x1LL", 255, "0xffLL"},
+		{token.NUMBERI64, "1LL", 1 << 63, "9223372036854776e3"},
+		{token.NUMBERU64, "1ULL", 1 << 63, "9223372036854775808ULL"},
+		{token.NUMBERU64, "1ULL", 1 << 64, "18446744073709552e3"},
+		{token.NUMBERIMAG, "1i", 2.5, "2.5i"},
+	}
+	for _, test := range tests {
+		n := number(test.typ, test.src)
+		n.FormatValue(test.v, 0, -1)
+		if got := string(n.NumberToken.Bytes); got != test.want {
+			t.Errorf("%q with %v: expected %q, got %q", test.src, test.v, test.want, got)
+		}
+	}
+}
+
+func TestNumberFormatValueNaN(t *testing.T) {
+	defer func() {
+		if _, ok := recover().(*tree.ValueError); !ok {
+			t.Error("expected panic with *tree.ValueError")
+		}
+	}()
+	(&tree.NumberExpr{}).FormatValue(math.NaN(), 'r', -1)
+}
+
+func TestNumberRoundTrip(t *testing.T) {
+	values := []float64{
+		0, 1, 0.5, 3, 100, 0.1, 1e21, 1.5e-7, 0.00012, 123456789, 1200000,
+		1e300, 5e-324, math.MaxFloat64, 1 << 63, 1 << 64, math.Inf(1),
+	}
+	for _, v := range values {
+		for _, fmt := range []byte{'r', 'R', 'p', 'd', 'x', 'b', 0} {
+			n := number(token.NUMBERFLOAT, "1.0")
+			n.FormatValue(v, fmt, -1)
+			// Binary numbers require a dialect other than Lua 5.4.
+			config := parser.Config{Dialect: token.Lua54}
+			if n.NumberToken.Type == token.NUMBERBIN {
+				config.Dialect = token.LuaJIT
+			}
+			expr, err := config.ParseExpr("", string(n.NumberToken.Bytes))
+			if err != nil {
+				t.Errorf("%v %c: %q: unexpected error %s", v, fmt, n.NumberToken.Bytes, err)
+				continue
+			}
+			m, ok := expr.(*tree.NumberExpr)
+			if !ok {
+				t.Errorf("%v %c: %q: expected *tree.NumberExpr, got %T", v, fmt, n.NumberToken.Bytes, expr)
+				continue
+			}
+			got, err := m.ParseValue()
+			if err != nil {
+				t.Errorf("%v %c: %q: unexpected error %s", v, fmt, n.NumberToken.Bytes, err)
+				continue
+			}
+			if got != v {
+				t.Errorf("%v %c: %q parsed as %v", v, fmt, n.NumberToken.Bytes, got)
+			}
+			if fmt == 'R' {
+				if c, _ := m.ParseDialectConst(token.Lua54); c != v {
+					t.Errorf("%v %c: %q parsed as %T", v, fmt, n.NumberToken.Bytes, c)
+				}
+			}
+		}
+	}
+}
+
+func TestFormatInt(t *testing.T) {
+	tests := []struct {
+		v    int64
+		fmt  byte
+		typ  token.Type
+		want string
+	}{
+		{255, 'd', token.NUMBERFLOAT, "255"},
+		{-255, 'x', token.NUMBERHEX, "0xff"},
+		{5, 'b', token.NUMBERBIN, "0b101"},
+		{math.MinInt64, 'x', token.NUMBERHEX, "0x8000000000000000"},
+		{math.MinInt64, 'd', token.NUMBERFLOAT, "9223372036854775808"},
+	}
+	for _, test := range tests {
+		n := &tree.NumberExpr{}
+		n.FormatInt(test.v, test.fmt)
+		if n.NumberToken.Type != test.typ || string(n.NumberToken.Bytes) != test.want {
+			t.Errorf("%d %c: expected %s %q, got %s %q", test.v, test.fmt,
+				test.typ, test.want, n.NumberToken.Type, n.NumberToken.Bytes)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package tree
+
+import (
+	"bytes"
+	"github.com/anaminus/luasyntax/go/token"
+	"reflect"
+)
+
+// An EqualMode value is a set of flags that control the behavior of Equal.
+type EqualMode uint
+
+const (
+	// IgnoreSpace causes whitespace prefixes to be ignored.
+	IgnoreSpace EqualMode = 1 << iota
+	// IgnoreComments causes comment prefixes to be ignored.
+	IgnoreComments
+	// IgnoreOffsets causes the offsets of tokens to be ignored.
+	IgnoreOffsets
+	// IgnoreParens causes parentheses around expressions and types to be
+	// ignored, such that a ParenExpr or ParenType is considered equal to the
+	// value it encloses. Note that this may hide differences in semantics; for
+	// example, parentheses truncate the results of a function call to one
+	// value.
+	IgnoreParens
+)
+
+// Equal returns whether the trees of a and b have the same structure. Nodes
+// are equal when they have the same type and equal children. Tokens are equal
+// when they have the same type, bytes, offset, and prefixes. The mode argument
+// controls which components are ignored. Values that are not a part of the
+// tree, such as the Info of a File, are ignored.
+func Equal(a, b Node, mode EqualMode) bool {
+	e := equaler{mode: mode}
+	return e.value(reflect.ValueOf(&a).Elem(), reflect.ValueOf(&b).Elem())
+}
+
+// equaler compares the values of two trees.
+type equaler struct {
+	mode EqualMode
+}
+
+// unparen returns the value enclosed by any ParenExpr or ParenType in v.
+func unparen(v reflect.Value) reflect.Value {
+	for !v.IsNil() {
+		switch n := v.Elem().Interface().(type) {
+		case *ParenExpr:
+			if n == nil || n.Value == nil {
+				return v
+			}
+			v = reflect.ValueOf(&n.Value).Elem()
+		case *ParenType:
+			if n == nil || n.Value == nil {
+				return v
+			}
+			v = reflect.ValueOf(&n.Value).Elem()
+		default:
+			return v
+		}
+	}
+	return v
+}
+
+// value compares a and b, which are assumed to be of the same type.
+func (e *equaler) value(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Interface:
+		if e.mode&IgnoreParens != 0 {
+			a, b = unparen(a), unparen(b)
+		}
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		if a.Elem().Type() != b.Elem().Type() {
+			return false
+		}
+		return e.value(a.Elem(), b.Elem())
+	case reflect.Ptr:
+		if !a.Type().Implements(nodeType) {
+			// Not a part of the tree.
+			return true
+		}
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return e.value(a.Elem(), b.Elem())
+	case reflect.Struct:
+		if a.Type() == tokenType {
+			return e.token(a.Addr().Interface().(*Token), b.Addr().Interface().(*Token))
+		}
+		for i := 0; i < a.NumField(); i++ {
+			if !e.value(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !e.value(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a.Interface() == b.Interface()
+	}
+}
+
+// ignorePrefix returns whether a prefix is ignored by the mode.
+func (e *equaler) ignorePrefix(p Prefix) bool {
+	if e.mode&IgnoreComments != 0 && p.Type.IsComment() {
+		return true
+	}
+	if e.mode&IgnoreSpace != 0 && p.Type == token.SPACE {
+		return true
+	}
+	return false
+}
+
+// token compares two tokens.
+func (e *equaler) token(a, b *Token) bool {
+	if a.Type != b.Type || !bytes.Equal(a.Bytes, b.Bytes) {
+		return false
+	}
+	if e.mode&IgnoreOffsets == 0 && a.Offset != b.Offset {
+		return false
+	}
+	i, j := 0, 0
+	for {
+		for i < len(a.Prefix) && e.ignorePrefix(a.Prefix[i]) {
+			i++
+		}
+		for j < len(b.Prefix) && e.ignorePrefix(b.Prefix[j]) {
+			j++
+		}
+		if i >= len(a.Prefix) || j >= len(b.Prefix) {
+			return i >= len(a.Prefix) && j >= len(b.Prefix)
+		}
+		if a.Prefix[i].Type != b.Prefix[j].Type || !bytes.Equal(a.Prefix[i].Bytes, b.Prefix[j].Bytes) {
+			return false
+		}
+		i++
+		j++
+	}
+}
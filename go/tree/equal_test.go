@@ -0,0 +1,62 @@
+package tree_test
+
+import (
+	"github.com/anaminus/luasyntax/go/tree"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		a, b string
+		mode tree.EqualMode
+		want bool
+	}{
+		{"x = 1", "x = 1", 0, true},
+		{"x = 1", "x = 2", 0, false},
+		{"x = 1", "x = 1.0", tree.IgnoreSpace | tree.IgnoreOffsets, false},
+		{"x = 1", "x  =  1", 0, false},
+		{"x = 1", "x  =  1", tree.IgnoreSpace, false},
+		{"x = 1", "x  =  1", tree.IgnoreSpace | tree.IgnoreOffsets, true},
+		{"x = 1", "x = 1 ", tree.IgnoreSpace, false},
+		{"x = 1", "x = 1 ", tree.IgnoreSpace | tree.IgnoreOffsets, true},
+		{"x = 1 --c", "x = 1 --d", tree.IgnoreSpace, false},
+		{"x = 1 --c", "x = 1 --d", tree.IgnoreComments, true},
+		{"x = 1 --c", "x = 1", tree.IgnoreComments, false},
+		{"x = 1 --c", "x = 1", tree.IgnoreComments | tree.IgnoreSpace | tree.IgnoreOffsets, true},
+		{"--c\nx = 1", "x = 1", tree.IgnoreComments | tree.IgnoreSpace | tree.IgnoreOffsets, true},
+		{"x = (a)", "x = a", tree.IgnoreSpace | tree.IgnoreOffsets, false},
+		{"x = (a)", "x = a", tree.IgnoreSpace | tree.IgnoreOffsets | tree.IgnoreParens, true},
+		{"x = ((a + b)) * c", "x = (a + b) * c", tree.IgnoreSpace | tree.IgnoreOffsets | tree.IgnoreParens, true},
+		{"x = (a + b) * c", "x = a + b * c", tree.IgnoreSpace | tree.IgnoreOffsets | tree.IgnoreParens, false},
+		{"x = a", "local x = a", tree.IgnoreSpace | tree.IgnoreOffsets, false},
+	}
+	for _, test := range tests {
+		a, b := parseFile(t, test.a), parseFile(t, test.b)
+		if got := tree.Equal(a, b, test.mode); got != test.want {
+			t.Errorf("%q, %q with mode %d: expected %t, got %t", test.a, test.b, test.mode, test.want, got)
+		}
+		if got := tree.Equal(b, a, test.mode); got != test.want {
+			t.Errorf("%q, %q with mode %d: expected %t, got %t", test.b, test.a, test.mode, test.want, got)
+		}
+	}
+}
+
+func TestEqualNil(t *testing.T) {
+	var expr *tree.BinopExpr
+	tests := []struct {
+		a, b tree.Node
+		want bool
+	}{
+		{nil, nil, true},
+		{nil, &tree.File{}, false},
+		{expr, expr, true},
+		{expr, &tree.BinopExpr{}, false},
+		{&tree.BinopExpr{}, &tree.BinopExpr{}, true},
+		{&tree.BinopExpr{}, &tree.UnopExpr{}, false},
+	}
+	for _, test := range tests {
+		if got := tree.Equal(test.a, test.b, 0); got != test.want {
+			t.Errorf("%#v, %#v: expected %t, got %t", test.a, test.b, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package tree
+
+import (
+	"fmt"
+	"github.com/anaminus/luasyntax/go/token"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// A PrintMode value is a set of flags that control the output of Fprint.
+type PrintMode uint
+
+const (
+	// HidePrefixes causes the prefixes of tokens to be omitted.
+	HidePrefixes PrintMode = 1 << iota
+	// HideOffsets causes the offsets of tokens to be omitted.
+	HideOffsets
+	// HideEmpty causes nil nodes and INVALID tokens to be omitted, along
+	// with lists that are empty or contain only omitted elements. The length
+	// of a list that is not omitted includes the omitted elements.
+	HideEmpty
+)
+
+var fileType = reflect.TypeOf((*token.File)(nil))
+
+// Fprint writes to w a dump of the tree of node, intended for debugging. Each
+// node is written with its type and fields, indented by depth. Each token is
+// written on one line with its type, its bytes, its offset prefixed with '@',
+// and its prefixes within brackets. The mode argument controls which
+// components are omitted.
+func Fprint(w io.Writer, node Node, mode PrintMode) error {
+	p := dumper{w: w, mode: mode}
+	p.value(reflect.ValueOf(&node).Elem())
+	p.printf("\n")
+	return p.err
+}
+
+// dumper writes the dump of a tree.
+type dumper struct {
+	w      io.Writer
+	mode   PrintMode
+	indent int
+	err    error
+}
+
+func (p *dumper) printf(format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	_, p.err = fmt.Fprintf(p.w, format, args...)
+}
+
+// line begins a new line at the current indentation.
+func (p *dumper) line() {
+	p.printf("\n%s", strings.Repeat(".  ", p.indent))
+}
+
+// isEmpty returns whether v is hidden by HideEmpty.
+func (p *dumper) isEmpty(v reflect.Value) bool {
+	if p.mode&HideEmpty == 0 {
+		return false
+	}
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if !p.isEmpty(v.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		if v.Type() == tokenType {
+			return !v.Field(0).Interface().(token.Type).IsValid()
+		}
+	}
+	return false
+}
+
+func (p *dumper) token(t *Token) {
+	p.printf("%s %q", t.Type, t.Bytes)
+	if p.mode&HideOffsets == 0 && t.Type.IsValid() {
+		p.printf(" @%d", t.Offset)
+	}
+	if p.mode&HidePrefixes == 0 && len(t.Prefix) > 0 {
+		p.printf(" [")
+		for i, prefix := range t.Prefix {
+			if i > 0 {
+				p.printf(", ")
+			}
+			p.printf("%s %q", prefix.Type, prefix.Bytes)
+		}
+		p.printf("]")
+	}
+}
+
+func (p *dumper) value(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			p.printf("nil")
+			return
+		}
+		if v.Type() == fileType {
+			p.printf("%s %q", v.Type(), v.Interface().(*token.File).Name())
+			return
+		}
+		if v.Kind() == reflect.Interface {
+			p.value(v.Elem())
+			return
+		}
+		p.printf("*")
+		p.value(v.Elem())
+	case reflect.Struct:
+		if v.Type() == tokenType {
+			p.token(v.Addr().Interface().(*Token))
+			return
+		}
+		p.printf("%s {", v.Type())
+		p.indent++
+		for i := 0; i < v.NumField(); i++ {
+			if p.isEmpty(v.Field(i)) {
+				continue
+			}
+			p.line()
+			p.printf("%s: ", v.Type().Field(i).Name)
+			p.value(v.Field(i))
+		}
+		p.indent--
+		p.line()
+		p.printf("}")
+	case reflect.Slice:
+		p.printf("%s (len = %d) {", v.Type(), v.Len())
+		if v.Len() == 0 {
+			p.printf("}")
+			return
+		}
+		p.indent++
+		for i := 0; i < v.Len(); i++ {
+			if p.isEmpty(v.Index(i)) {
+				continue
+			}
+			p.line()
+			p.printf("%s: ", strconv.Itoa(i))
+			p.value(v.Index(i))
+		}
+		p.indent--
+		p.line()
+		p.printf("}")
+	default:
+		if s, ok := v.Interface().(fmt.Stringer); ok {
+			p.printf("%s", s)
+			return
+		}
+		p.printf("%v", v.Interface())
+	}
+}
@@ -0,0 +1,127 @@
+package tree_test
+
+import (
+	"bytes"
+	"github.com/anaminus/luasyntax/go/parser"
+	"github.com/anaminus/luasyntax/go/token"
+	"github.com/anaminus/luasyntax/go/tree"
+	"testing"
+)
+
+func TestFprint(t *testing.T) {
+	tests := []struct {
+		src  string
+		mode tree.PrintMode
+		want string
+	}{
+		{"--c\n-a", 0, `*tree.UnopExpr {
+.  UnopToken: - "-" @4 [<comment> "--c", <space> "\n"]
+.  Operand: *tree.VariableExpr {
+.  .  NameToken: <name> "a" @5
+.  }
+}
+`},
+		{"--c\n-a", tree.HidePrefixes, `*tree.UnopExpr {
+.  UnopToken: - "-" @4
+.  Operand: *tree.VariableExpr {
+.  .  NameToken: <name> "a" @5
+.  }
+}
+`},
+		{"--c\n-a", tree.HideOffsets, `*tree.UnopExpr {
+.  UnopToken: - "-" [<comment> "--c", <space> "\n"]
+.  Operand: *tree.VariableExpr {
+.  .  NameToken: <name> "a"
+.  }
+}
+`},
+		{"f()", 0, `*tree.CallExpr {
+.  Value: *tree.VariableExpr {
+.  .  NameToken: <name> "f" @0
+.  }
+.  Args: *tree.ListArgs {
+.  .  LParenToken: ( "(" @1
+.  .  Values: nil
+.  .  RParenToken: ) ")" @2
+.  }
+}
+`},
+		{"f()", tree.HideEmpty | tree.HideOffsets, `*tree.CallExpr {
+.  Value: *tree.VariableExpr {
+.  .  NameToken: <name> "f"
+.  }
+.  Args: *tree.ListArgs {
+.  .  LParenToken: ( "("
+.  .  RParenToken: ) ")"
+.  }
+}
+`},
+	}
+	for _, test := range tests {
+		expr, err := parser.ParseExpr("", test.src)
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", test.src, err)
+			continue
+		}
+		var buf bytes.Buffer
+		if err := tree.Fprint(&buf, expr, test.mode); err != nil {
+			t.Errorf("%q: unexpected error %s", test.src, err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("%q with mode %d: expected\n%s\ngot\n%s", test.src, test.mode, test.want, got)
+		}
+	}
+}
+
+func TestFprintHideEmpty(t *testing.T) {
+	list := &tree.ExprList{
+		Items: []tree.Expr{
+			nil,
+			&tree.VariableExpr{NameToken: tree.Token{Type: token.NAME, Bytes: []byte("a")}},
+			nil,
+		},
+		Seps: []tree.Token{{}, {}},
+	}
+	tests := []struct {
+		mode tree.PrintMode
+		want string
+	}{
+		{tree.HideOffsets, `*tree.ExprList {
+.  Items: []tree.Expr (len = 3) {
+.  .  0: nil
+.  .  1: *tree.VariableExpr {
+.  .  .  NameToken: <name> "a"
+.  .  }
+.  .  2: nil
+.  }
+.  Seps: []tree.Token (len = 2) {
+.  .  0: <invalid> ""
+.  .  1: <invalid> ""
+.  }
+}
+`},
+		{tree.HideOffsets | tree.HideEmpty, `*tree.ExprList {
+.  Items: []tree.Expr (len = 3) {
+.  .  1: *tree.VariableExpr {
+.  .  .  NameToken: <name> "a"
+.  .  }
+.  }
+}
+`},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := tree.Fprint(&buf, list, test.mode); err != nil {
+			t.Errorf("mode %d: unexpected error %s", test.mode, err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("mode %d: expected\n%s\ngot\n%s", test.mode, test.want, got)
+		}
+	}
+
+	var buf bytes.Buffer
+	tree.Fprint(&buf, nil, tree.HideEmpty)
+	if got := buf.String(); got != "nil\n" {
+		t.Errorf("expected %q, got %q", "nil\n", got)
+	}
+}
@@ -23,7 +23,38 @@ func (l *ExprList) FirstToken() *Token { return l.Items[0].FirstToken() }
 func (l *ExprList) LastToken() *Token  { return l.Items[len(l.Items)-1].LastToken() }
 
 func (l *NameList) FirstToken() *Token { return &l.Items[0] }
-func (l *NameList) LastToken() *Token  { return &l.Items[len(l.Items)-1] }
+func (l *NameList) LastToken() *Token {
+	if len(l.Types) == len(l.Items) {
+		if typ := l.Types[len(l.Types)-1]; typ != nil {
+			return typ.LastToken()
+		}
+	}
+	if len(l.Attribs) == len(l.Items) {
+		if attrib := l.Attribs[len(l.Attribs)-1]; attrib != nil {
+			return attrib.LastToken()
+		}
+	}
+	return &l.Items[len(l.Items)-1]
+}
+
+func (a *Attrib) FirstToken() *Token { return &a.LAngleToken }
+func (a *Attrib) LastToken() *Token  { return &a.RAngleToken }
+
+func (a *TypeAnnot) FirstToken() *Token { return &a.ColonToken }
+func (a *TypeAnnot) LastToken() *Token  { return a.Type.LastToken() }
+
+func (e *BadExpr) FirstToken() *Token {
+	if len(e.Tokens) == 0 {
+		return nil
+	}
+	return &e.Tokens[0]
+}
+func (e *BadExpr) LastToken() *Token {
+	if len(e.Tokens) == 0 {
+		return nil
+	}
+	return &e.Tokens[len(e.Tokens)-1]
+}
 
 func (e *NumberExpr) FirstToken() *Token { return &e.NumberToken }
 func (e *NumberExpr) LastToken() *Token  { return &e.NumberToken }
@@ -92,6 +123,28 @@ func (e *MethodExpr) LastToken() *Token  { return e.Args.LastToken() }
 func (e *CallExpr) FirstToken() *Token { return e.Value.FirstToken() }
 func (e *CallExpr) LastToken() *Token  { return e.Args.LastToken() }
 
+func (e *IfExpr) FirstToken() *Token { return &e.IfToken }
+func (e *IfExpr) LastToken() *Token  { return e.Else.LastToken() }
+
+func (c *ElseIfExprClause) FirstToken() *Token { return &c.ElseIfToken }
+func (c *ElseIfExprClause) LastToken() *Token  { return c.Value.LastToken() }
+
+func (e *InterpExpr) FirstToken() *Token {
+	if len(e.Segments) == 0 {
+		return nil
+	}
+	return &e.Segments[0]
+}
+func (e *InterpExpr) LastToken() *Token {
+	if len(e.Segments) == 0 {
+		return nil
+	}
+	return &e.Segments[len(e.Segments)-1]
+}
+
+func (e *AssertExpr) FirstToken() *Token { return e.Value.FirstToken() }
+func (e *AssertExpr) LastToken() *Token  { return e.Type.LastToken() }
+
 func (c *ListArgs) FirstToken() *Token { return &c.LParenToken }
 func (c *ListArgs) LastToken() *Token  { return &c.RParenToken }
 
@@ -101,12 +154,31 @@ func (c *TableArg) LastToken() *Token  { return c.Value.LastToken() }
 func (c *StringArg) FirstToken() *Token { return &c.Value.StringToken }
 func (c *StringArg) LastToken() *Token  { return &c.Value.StringToken }
 
+func (s *BadStmt) FirstToken() *Token {
+	if len(s.Tokens) == 0 {
+		return nil
+	}
+	return &s.Tokens[0]
+}
+func (s *BadStmt) LastToken() *Token {
+	if len(s.Tokens) == 0 {
+		return nil
+	}
+	return &s.Tokens[len(s.Tokens)-1]
+}
+
+func (s *MetaStmt) FirstToken() *Token { return &s.NameToken }
+func (s *MetaStmt) LastToken() *Token  { return &s.NameToken }
+
 func (s *DoStmt) FirstToken() *Token { return &s.DoToken }
 func (s *DoStmt) LastToken() *Token  { return &s.EndToken }
 
 func (s *AssignStmt) FirstToken() *Token { return s.Left.FirstToken() }
 func (s *AssignStmt) LastToken() *Token  { return s.Right.LastToken() }
 
+func (s *CompoundAssignStmt) FirstToken() *Token { return s.Left.FirstToken() }
+func (s *CompoundAssignStmt) LastToken() *Token  { return s.Right.LastToken() }
+
 func (s *CallStmt) FirstToken() *Token { return s.Call.FirstToken() }
 func (s *CallStmt) LastToken() *Token  { return s.Call.LastToken() }
 
@@ -161,6 +233,23 @@ func (l *FuncNameList) LastToken() *Token {
 func (s *BreakStmt) FirstToken() *Token { return &s.BreakToken }
 func (s *BreakStmt) LastToken() *Token  { return &s.BreakToken }
 
+func (s *ContinueStmt) FirstToken() *Token { return &s.ContinueToken }
+func (s *ContinueStmt) LastToken() *Token  { return &s.ContinueToken }
+
+func (s *GotoStmt) FirstToken() *Token { return &s.GotoToken }
+func (s *GotoStmt) LastToken() *Token  { return &s.NameToken }
+
+func (s *LabelStmt) FirstToken() *Token { return &s.LColonToken }
+func (s *LabelStmt) LastToken() *Token  { return &s.RColonToken }
+
+func (s *TypeStmt) FirstToken() *Token {
+	if s.ExportToken.Type.IsValid() {
+		return &s.ExportToken
+	}
+	return &s.TypeToken
+}
+func (s *TypeStmt) LastToken() *Token { return s.Type.LastToken() }
+
 func (s *ReturnStmt) FirstToken() *Token { return &s.ReturnToken }
 func (s *ReturnStmt) LastToken() *Token {
 	if s.Values.Len() == 0 {
@@ -168,3 +257,109 @@ func (s *ReturnStmt) LastToken() *Token {
 	}
 	return s.Values.LastToken()
 }
+
+func (l *TypeList) FirstToken() *Token { return l.Items[0].FirstToken() }
+func (l *TypeList) LastToken() *Token  { return l.Items[len(l.Items)-1].LastToken() }
+
+func (l *GenericList) FirstToken() *Token { return &l.LAngleToken }
+func (l *GenericList) LastToken() *Token  { return &l.RAngleToken }
+
+func (p *GenericParam) FirstToken() *Token { return &p.NameToken }
+func (p *GenericParam) LastToken() *Token {
+	if p.AssignToken.Type.IsValid() {
+		return p.Default.LastToken()
+	}
+	if p.VarArgToken.Type.IsValid() {
+		return &p.VarArgToken
+	}
+	return &p.NameToken
+}
+
+func (t *NamedType) FirstToken() *Token {
+	if t.ModuleToken.Type.IsValid() {
+		return &t.ModuleToken
+	}
+	return &t.NameToken
+}
+func (t *NamedType) LastToken() *Token {
+	if t.Params != nil {
+		return t.Params.LastToken()
+	}
+	return &t.NameToken
+}
+
+func (p *TypeParams) FirstToken() *Token { return &p.LAngleToken }
+func (p *TypeParams) LastToken() *Token  { return &p.RAngleToken }
+
+func (t *LiteralType) FirstToken() *Token { return &t.LiteralToken }
+func (t *LiteralType) LastToken() *Token  { return &t.LiteralToken }
+
+func (t *TypeofType) FirstToken() *Token { return &t.TypeofToken }
+func (t *TypeofType) LastToken() *Token  { return &t.RParenToken }
+
+func (t *TableType) FirstToken() *Token { return &t.LBraceToken }
+func (t *TableType) LastToken() *Token  { return &t.RBraceToken }
+
+func (l *TypeEntryList) FirstToken() *Token {
+	if len(l.Items) == 0 {
+		return nil
+	}
+	return l.Items[0].FirstToken()
+}
+func (l *TypeEntryList) LastToken() *Token {
+	if len(l.Seps) == len(l.Items) {
+		return &l.Seps[len(l.Seps)-1]
+	}
+	return l.Items[len(l.Items)-1].LastToken()
+}
+
+func (e *TypeIndexEntry) FirstToken() *Token { return &e.LBrackToken }
+func (e *TypeIndexEntry) LastToken() *Token  { return e.Value.LastToken() }
+
+func (e *TypeFieldEntry) FirstToken() *Token { return &e.NameToken }
+func (e *TypeFieldEntry) LastToken() *Token  { return e.Value.LastToken() }
+
+func (e *TypeValueEntry) FirstToken() *Token { return e.Value.FirstToken() }
+func (e *TypeValueEntry) LastToken() *Token  { return e.Value.LastToken() }
+
+func (t *FunctionType) FirstToken() *Token {
+	if t.Generics != nil {
+		return t.Generics.FirstToken()
+	}
+	return &t.LParenToken
+}
+func (t *FunctionType) LastToken() *Token { return t.Return.LastToken() }
+
+func (t *ParamType) FirstToken() *Token { return &t.NameToken }
+func (t *ParamType) LastToken() *Token  { return t.Type.LastToken() }
+
+func (t *ParenType) FirstToken() *Token { return &t.LParenToken }
+func (t *ParenType) LastToken() *Token  { return &t.RParenToken }
+
+func (t *PackType) FirstToken() *Token { return &t.LParenToken }
+func (t *PackType) LastToken() *Token  { return &t.RParenToken }
+
+func (t *VariadicType) FirstToken() *Token { return &t.VarArgToken }
+func (t *VariadicType) LastToken() *Token  { return t.Value.LastToken() }
+
+func (t *GenericPackType) FirstToken() *Token { return &t.NameToken }
+func (t *GenericPackType) LastToken() *Token  { return &t.VarArgToken }
+
+func (t *OptionalType) FirstToken() *Token { return t.Value.FirstToken() }
+func (t *OptionalType) LastToken() *Token  { return &t.QuestionToken }
+
+func (t *UnionType) FirstToken() *Token {
+	if t.LeadToken.Type.IsValid() {
+		return &t.LeadToken
+	}
+	return t.Items[0].FirstToken()
+}
+func (t *UnionType) LastToken() *Token { return t.Items[len(t.Items)-1].LastToken() }
+
+func (t *IntersectionType) FirstToken() *Token {
+	if t.LeadToken.Type.IsValid() {
+		return &t.LeadToken
+	}
+	return t.Items[0].FirstToken()
+}
+func (t *IntersectionType) LastToken() *Token { return t.Items[len(t.Items)-1].LastToken() }
@@ -0,0 +1,44 @@
+package tree
+
+// PathEnclosingInterval returns the path of nodes that enclose the interval
+// [start, end) of offsets within file. The path begins with file, and each
+// subsequent node is a child of the previous node, ending with the innermost
+// node that encloses the interval. A node encloses the interval if the
+// interval lies between Offset and EndOffset of the node, inclusive. When the
+// interval lies on the boundary of two adjacent nodes, the first node is
+// chosen.
+//
+// The exact result indicates whether the interval matches the range of the
+// innermost node exactly. Prefixes are not considered to be a part of a node,
+// so an interval within whitespace or a comment results in the node that
+// encloses the surrounding tokens.
+//
+// If the interval does not lie within the full range of the file, then nil is
+// returned. If start is greater than end, they are swapped.
+func PathEnclosingInterval(file *File, start, end int) (path []Node, exact bool) {
+	if start > end {
+		start, end = end, start
+	}
+	if fstart := file.StartOffset(); fstart < 0 || start < fstart || end > file.EndOffset() {
+		return nil, false
+	}
+	path = append(path, file)
+	node := Node(file)
+loop:
+	for {
+		for _, e := range elements(node) {
+			if e.node == nil {
+				continue
+			}
+			offset := e.node.Offset()
+			if offset >= 0 && offset <= start && end <= e.node.EndOffset() {
+				path = append(path, e.node)
+				node = e.node
+				continue loop
+			}
+		}
+		break
+	}
+	exact = node.Offset() == start && node.EndOffset() == end
+	return path, exact
+}
@@ -0,0 +1,57 @@
+package tree_test
+
+import (
+	"fmt"
+	"github.com/anaminus/luasyntax/go/tree"
+	"strings"
+	"testing"
+)
+
+// pathString formats each node of a path with %T, without the package name.
+func pathString(path []tree.Node) string {
+	s := make([]string, len(path))
+	for i, node := range path {
+		s[i] = strings.TrimPrefix(fmt.Sprintf("%T", node), "*tree.")
+	}
+	return strings.Join(s, " ")
+}
+
+func TestPathEnclosingInterval(t *testing.T) {
+	const src = "local x = a + b * c\n-- comment\nf(x, 1)"
+	tests := []struct {
+		start, end int
+		path       string
+		exact      bool
+	}{
+		{0, 0, "File Block LocalVarStmt", false},
+		{14, 15, "File Block LocalVarStmt ExprList BinopExpr BinopExpr VariableExpr", true},
+		{15, 14, "File Block LocalVarStmt ExprList BinopExpr BinopExpr VariableExpr", true},
+		{14, 19, "File Block LocalVarStmt ExprList BinopExpr BinopExpr", true},
+		{10, 19, "File Block LocalVarStmt ExprList BinopExpr", true},
+		{10, 15, "File Block LocalVarStmt ExprList BinopExpr", false},
+		{12, 12, "File Block LocalVarStmt ExprList BinopExpr", false},
+		{11, 11, "File Block LocalVarStmt ExprList BinopExpr VariableExpr", false},
+		{23, 25, "File Block", false},
+		{31, 38, "File Block CallStmt CallExpr", true},
+		{33, 34, "File Block CallStmt CallExpr ListArgs ExprList VariableExpr", true},
+		{0, 38, "File Block", true},
+		{-1, 2, "", false},
+		{0, 39, "", false},
+	}
+	f := parseFile(t, src)
+	for _, test := range tests {
+		path, exact := tree.PathEnclosingInterval(f, test.start, test.end)
+		if got := pathString(path); got != test.path {
+			t.Errorf("[%d, %d): expected path %q, got %q", test.start, test.end, test.path, got)
+		}
+		if exact != test.exact {
+			t.Errorf("[%d, %d): expected exact=%t, got %t", test.start, test.end, test.exact, exact)
+		}
+	}
+}
+
+func TestPathEnclosingIntervalEmpty(t *testing.T) {
+	if path, exact := tree.PathEnclosingInterval(&tree.File{}, 0, 0); path != nil || exact {
+		t.Errorf("expected nil path, got %q, %t", pathString(path), exact)
+	}
+}
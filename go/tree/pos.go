@@ -0,0 +1,403 @@
+package tree
+
+// element is a token or child node directly within a node.
+type element struct {
+	node Node
+	tok  *Token
+}
+
+// elementCollector collects the tokens and child nodes directly within a
+// node, in lexical order.
+type elementCollector struct {
+	started bool
+	elems   []element
+}
+
+func (c *elementCollector) Visit(node Node) Visitor {
+	if node == nil {
+		return nil
+	}
+	if !c.started {
+		c.started = true
+		return c
+	}
+	// Do not descend into child nodes.
+	c.elems = append(c.elems, element{node: node})
+	return nil
+}
+
+func (c *elementCollector) VisitToken(_ Node, _ int, tok *Token) {
+	c.elems = append(c.elems, element{tok: tok})
+}
+
+// elements returns the tokens and child nodes directly within a node.
+func elements(node Node) []element {
+	var c elementCollector
+	Walk(&c, node)
+	return c.elems
+}
+
+// firstToken returns the first valid token within node, or nil if there are
+// no valid tokens. Unlike FirstToken, the node is not required to be valid.
+func firstToken(node Node) *Token {
+	for _, e := range elements(node) {
+		if e.tok != nil {
+			if e.tok.Type.IsValid() {
+				return e.tok
+			}
+			continue
+		}
+		if tok := firstToken(e.node); tok != nil {
+			return tok
+		}
+	}
+	return nil
+}
+
+// lastToken returns the last valid token within node, or nil if there are no
+// valid tokens. Unlike LastToken, the node is not required to be valid.
+func lastToken(node Node) *Token {
+	elems := elements(node)
+	for i := len(elems) - 1; i >= 0; i-- {
+		e := elems[i]
+		if e.tok != nil {
+			if e.tok.Type.IsValid() {
+				return e.tok
+			}
+			continue
+		}
+		if tok := lastToken(e.node); tok != nil {
+			return tok
+		}
+	}
+	return nil
+}
+
+func nodeOffset(node Node) int {
+	if tok := firstToken(node); tok != nil {
+		return tok.Offset
+	}
+	return -1
+}
+
+func nodeStartOffset(node Node) int {
+	if tok := firstToken(node); tok != nil {
+		return tok.StartOffset()
+	}
+	return -1
+}
+
+func nodeEndOffset(node Node) int {
+	if tok := lastToken(node); tok != nil {
+		return tok.EndOffset()
+	}
+	return -1
+}
+
+func (f *File) Offset() int      { return nodeOffset(f) }
+func (f *File) EndOffset() int   { return nodeEndOffset(f) }
+func (f *File) StartOffset() int { return nodeStartOffset(f) }
+
+func (b *Block) Offset() int      { return nodeOffset(b) }
+func (b *Block) EndOffset() int   { return nodeEndOffset(b) }
+func (b *Block) StartOffset() int { return nodeStartOffset(b) }
+
+func (l *ExprList) Offset() int      { return nodeOffset(l) }
+func (l *ExprList) EndOffset() int   { return nodeEndOffset(l) }
+func (l *ExprList) StartOffset() int { return nodeStartOffset(l) }
+
+func (l *NameList) Offset() int      { return nodeOffset(l) }
+func (l *NameList) EndOffset() int   { return nodeEndOffset(l) }
+func (l *NameList) StartOffset() int { return nodeStartOffset(l) }
+
+func (a *Attrib) Offset() int      { return nodeOffset(a) }
+func (a *Attrib) EndOffset() int   { return nodeEndOffset(a) }
+func (a *Attrib) StartOffset() int { return nodeStartOffset(a) }
+
+func (a *TypeAnnot) Offset() int      { return nodeOffset(a) }
+func (a *TypeAnnot) EndOffset() int   { return nodeEndOffset(a) }
+func (a *TypeAnnot) StartOffset() int { return nodeStartOffset(a) }
+
+func (e *BadExpr) Offset() int      { return nodeOffset(e) }
+func (e *BadExpr) EndOffset() int   { return nodeEndOffset(e) }
+func (e *BadExpr) StartOffset() int { return nodeStartOffset(e) }
+
+func (e *NumberExpr) Offset() int      { return nodeOffset(e) }
+func (e *NumberExpr) EndOffset() int   { return nodeEndOffset(e) }
+func (e *NumberExpr) StartOffset() int { return nodeStartOffset(e) }
+
+func (e *StringExpr) Offset() int      { return nodeOffset(e) }
+func (e *StringExpr) EndOffset() int   { return nodeEndOffset(e) }
+func (e *StringExpr) StartOffset() int { return nodeStartOffset(e) }
+
+func (e *NilExpr) Offset() int      { return nodeOffset(e) }
+func (e *NilExpr) EndOffset() int   { return nodeEndOffset(e) }
+func (e *NilExpr) StartOffset() int { return nodeStartOffset(e) }
+
+func (e *BoolExpr) Offset() int      { return nodeOffset(e) }
+func (e *BoolExpr) EndOffset() int   { return nodeEndOffset(e) }
+func (e *BoolExpr) StartOffset() int { return nodeStartOffset(e) }
+
+func (e *VarArgExpr) Offset() int      { return nodeOffset(e) }
+func (e *VarArgExpr) EndOffset() int   { return nodeEndOffset(e) }
+func (e *VarArgExpr) StartOffset() int { return nodeStartOffset(e) }
+
+func (e *UnopExpr) Offset() int      { return nodeOffset(e) }
+func (e *UnopExpr) EndOffset() int   { return nodeEndOffset(e) }
+func (e *UnopExpr) StartOffset() int { return nodeStartOffset(e) }
+
+func (e *BinopExpr) Offset() int      { return nodeOffset(e) }
+func (e *BinopExpr) EndOffset() int   { return nodeEndOffset(e) }
+func (e *BinopExpr) StartOffset() int { return nodeStartOffset(e) }
+
+func (e *ParenExpr) Offset() int      { return nodeOffset(e) }
+func (e *ParenExpr) EndOffset() int   { return nodeEndOffset(e) }
+func (e *ParenExpr) StartOffset() int { return nodeStartOffset(e) }
+
+func (e *VariableExpr) Offset() int      { return nodeOffset(e) }
+func (e *VariableExpr) EndOffset() int   { return nodeEndOffset(e) }
+func (e *VariableExpr) StartOffset() int { return nodeStartOffset(e) }
+
+func (e *TableCtor) Offset() int      { return nodeOffset(e) }
+func (e *TableCtor) EndOffset() int   { return nodeEndOffset(e) }
+func (e *TableCtor) StartOffset() int { return nodeStartOffset(e) }
+
+func (l *EntryList) Offset() int      { return nodeOffset(l) }
+func (l *EntryList) EndOffset() int   { return nodeEndOffset(l) }
+func (l *EntryList) StartOffset() int { return nodeStartOffset(l) }
+
+func (e *IndexEntry) Offset() int      { return nodeOffset(e) }
+func (e *IndexEntry) EndOffset() int   { return nodeEndOffset(e) }
+func (e *IndexEntry) StartOffset() int { return nodeStartOffset(e) }
+
+func (e *FieldEntry) Offset() int      { return nodeOffset(e) }
+func (e *FieldEntry) EndOffset() int   { return nodeEndOffset(e) }
+func (e *FieldEntry) StartOffset() int { return nodeStartOffset(e) }
+
+func (e *ValueEntry) Offset() int      { return nodeOffset(e) }
+func (e *ValueEntry) EndOffset() int   { return nodeEndOffset(e) }
+func (e *ValueEntry) StartOffset() int { return nodeStartOffset(e) }
+
+func (s *FunctionExpr) Offset() int      { return nodeOffset(s) }
+func (s *FunctionExpr) EndOffset() int   { return nodeEndOffset(s) }
+func (s *FunctionExpr) StartOffset() int { return nodeStartOffset(s) }
+
+func (e *FieldExpr) Offset() int      { return nodeOffset(e) }
+func (e *FieldExpr) EndOffset() int   { return nodeEndOffset(e) }
+func (e *FieldExpr) StartOffset() int { return nodeStartOffset(e) }
+
+func (e *IndexExpr) Offset() int      { return nodeOffset(e) }
+func (e *IndexExpr) EndOffset() int   { return nodeEndOffset(e) }
+func (e *IndexExpr) StartOffset() int { return nodeStartOffset(e) }
+
+func (e *MethodExpr) Offset() int      { return nodeOffset(e) }
+func (e *MethodExpr) EndOffset() int   { return nodeEndOffset(e) }
+func (e *MethodExpr) StartOffset() int { return nodeStartOffset(e) }
+
+func (e *CallExpr) Offset() int      { return nodeOffset(e) }
+func (e *CallExpr) EndOffset() int   { return nodeEndOffset(e) }
+func (e *CallExpr) StartOffset() int { return nodeStartOffset(e) }
+
+func (e *IfExpr) Offset() int      { return nodeOffset(e) }
+func (e *IfExpr) EndOffset() int   { return nodeEndOffset(e) }
+func (e *IfExpr) StartOffset() int { return nodeStartOffset(e) }
+
+func (c *ElseIfExprClause) Offset() int      { return nodeOffset(c) }
+func (c *ElseIfExprClause) EndOffset() int   { return nodeEndOffset(c) }
+func (c *ElseIfExprClause) StartOffset() int { return nodeStartOffset(c) }
+
+func (e *InterpExpr) Offset() int      { return nodeOffset(e) }
+func (e *InterpExpr) EndOffset() int   { return nodeEndOffset(e) }
+func (e *InterpExpr) StartOffset() int { return nodeStartOffset(e) }
+
+func (e *AssertExpr) Offset() int      { return nodeOffset(e) }
+func (e *AssertExpr) EndOffset() int   { return nodeEndOffset(e) }
+func (e *AssertExpr) StartOffset() int { return nodeStartOffset(e) }
+
+func (c *ListArgs) Offset() int      { return nodeOffset(c) }
+func (c *ListArgs) EndOffset() int   { return nodeEndOffset(c) }
+func (c *ListArgs) StartOffset() int { return nodeStartOffset(c) }
+
+func (c *TableArg) Offset() int      { return nodeOffset(c) }
+func (c *TableArg) EndOffset() int   { return nodeEndOffset(c) }
+func (c *TableArg) StartOffset() int { return nodeStartOffset(c) }
+
+func (c *StringArg) Offset() int      { return nodeOffset(c) }
+func (c *StringArg) EndOffset() int   { return nodeEndOffset(c) }
+func (c *StringArg) StartOffset() int { return nodeStartOffset(c) }
+
+func (s *BadStmt) Offset() int      { return nodeOffset(s) }
+func (s *BadStmt) EndOffset() int   { return nodeEndOffset(s) }
+func (s *BadStmt) StartOffset() int { return nodeStartOffset(s) }
+
+func (s *MetaStmt) Offset() int      { return nodeOffset(s) }
+func (s *MetaStmt) EndOffset() int   { return nodeEndOffset(s) }
+func (s *MetaStmt) StartOffset() int { return nodeStartOffset(s) }
+
+func (s *DoStmt) Offset() int      { return nodeOffset(s) }
+func (s *DoStmt) EndOffset() int   { return nodeEndOffset(s) }
+func (s *DoStmt) StartOffset() int { return nodeStartOffset(s) }
+
+func (s *AssignStmt) Offset() int      { return nodeOffset(s) }
+func (s *AssignStmt) EndOffset() int   { return nodeEndOffset(s) }
+func (s *AssignStmt) StartOffset() int { return nodeStartOffset(s) }
+
+func (s *CompoundAssignStmt) Offset() int      { return nodeOffset(s) }
+func (s *CompoundAssignStmt) EndOffset() int   { return nodeEndOffset(s) }
+func (s *CompoundAssignStmt) StartOffset() int { return nodeStartOffset(s) }
+
+func (s *CallStmt) Offset() int      { return nodeOffset(s) }
+func (s *CallStmt) EndOffset() int   { return nodeEndOffset(s) }
+func (s *CallStmt) StartOffset() int { return nodeStartOffset(s) }
+
+func (s *IfStmt) Offset() int      { return nodeOffset(s) }
+func (s *IfStmt) EndOffset() int   { return nodeEndOffset(s) }
+func (s *IfStmt) StartOffset() int { return nodeStartOffset(s) }
+
+func (c *ElseIfClause) Offset() int      { return nodeOffset(c) }
+func (c *ElseIfClause) EndOffset() int   { return nodeEndOffset(c) }
+func (c *ElseIfClause) StartOffset() int { return nodeStartOffset(c) }
+
+func (c *ElseClause) Offset() int      { return nodeOffset(c) }
+func (c *ElseClause) EndOffset() int   { return nodeEndOffset(c) }
+func (c *ElseClause) StartOffset() int { return nodeStartOffset(c) }
+
+func (s *NumericForStmt) Offset() int      { return nodeOffset(s) }
+func (s *NumericForStmt) EndOffset() int   { return nodeEndOffset(s) }
+func (s *NumericForStmt) StartOffset() int { return nodeStartOffset(s) }
+
+func (s *GenericForStmt) Offset() int      { return nodeOffset(s) }
+func (s *GenericForStmt) EndOffset() int   { return nodeEndOffset(s) }
+func (s *GenericForStmt) StartOffset() int { return nodeStartOffset(s) }
+
+func (s *WhileStmt) Offset() int      { return nodeOffset(s) }
+func (s *WhileStmt) EndOffset() int   { return nodeEndOffset(s) }
+func (s *WhileStmt) StartOffset() int { return nodeStartOffset(s) }
+
+func (s *RepeatStmt) Offset() int      { return nodeOffset(s) }
+func (s *RepeatStmt) EndOffset() int   { return nodeEndOffset(s) }
+func (s *RepeatStmt) StartOffset() int { return nodeStartOffset(s) }
+
+func (s *LocalVarStmt) Offset() int      { return nodeOffset(s) }
+func (s *LocalVarStmt) EndOffset() int   { return nodeEndOffset(s) }
+func (s *LocalVarStmt) StartOffset() int { return nodeStartOffset(s) }
+
+func (s *LocalFunctionStmt) Offset() int      { return nodeOffset(s) }
+func (s *LocalFunctionStmt) EndOffset() int   { return nodeEndOffset(s) }
+func (s *LocalFunctionStmt) StartOffset() int { return nodeStartOffset(s) }
+
+func (s *FunctionStmt) Offset() int      { return nodeOffset(s) }
+func (s *FunctionStmt) EndOffset() int   { return nodeEndOffset(s) }
+func (s *FunctionStmt) StartOffset() int { return nodeStartOffset(s) }
+
+func (l *FuncNameList) Offset() int      { return nodeOffset(l) }
+func (l *FuncNameList) EndOffset() int   { return nodeEndOffset(l) }
+func (l *FuncNameList) StartOffset() int { return nodeStartOffset(l) }
+
+func (s *BreakStmt) Offset() int      { return nodeOffset(s) }
+func (s *BreakStmt) EndOffset() int   { return nodeEndOffset(s) }
+func (s *BreakStmt) StartOffset() int { return nodeStartOffset(s) }
+
+func (s *ContinueStmt) Offset() int      { return nodeOffset(s) }
+func (s *ContinueStmt) EndOffset() int   { return nodeEndOffset(s) }
+func (s *ContinueStmt) StartOffset() int { return nodeStartOffset(s) }
+
+func (s *GotoStmt) Offset() int      { return nodeOffset(s) }
+func (s *GotoStmt) EndOffset() int   { return nodeEndOffset(s) }
+func (s *GotoStmt) StartOffset() int { return nodeStartOffset(s) }
+
+func (s *LabelStmt) Offset() int      { return nodeOffset(s) }
+func (s *LabelStmt) EndOffset() int   { return nodeEndOffset(s) }
+func (s *LabelStmt) StartOffset() int { return nodeStartOffset(s) }
+
+func (s *TypeStmt) Offset() int      { return nodeOffset(s) }
+func (s *TypeStmt) EndOffset() int   { return nodeEndOffset(s) }
+func (s *TypeStmt) StartOffset() int { return nodeStartOffset(s) }
+
+func (s *ReturnStmt) Offset() int      { return nodeOffset(s) }
+func (s *ReturnStmt) EndOffset() int   { return nodeEndOffset(s) }
+func (s *ReturnStmt) StartOffset() int { return nodeStartOffset(s) }
+
+func (l *TypeList) Offset() int      { return nodeOffset(l) }
+func (l *TypeList) EndOffset() int   { return nodeEndOffset(l) }
+func (l *TypeList) StartOffset() int { return nodeStartOffset(l) }
+
+func (l *GenericList) Offset() int      { return nodeOffset(l) }
+func (l *GenericList) EndOffset() int   { return nodeEndOffset(l) }
+func (l *GenericList) StartOffset() int { return nodeStartOffset(l) }
+
+func (p *GenericParam) Offset() int      { return nodeOffset(p) }
+func (p *GenericParam) EndOffset() int   { return nodeEndOffset(p) }
+func (p *GenericParam) StartOffset() int { return nodeStartOffset(p) }
+
+func (t *NamedType) Offset() int      { return nodeOffset(t) }
+func (t *NamedType) EndOffset() int   { return nodeEndOffset(t) }
+func (t *NamedType) StartOffset() int { return nodeStartOffset(t) }
+
+func (p *TypeParams) Offset() int      { return nodeOffset(p) }
+func (p *TypeParams) EndOffset() int   { return nodeEndOffset(p) }
+func (p *TypeParams) StartOffset() int { return nodeStartOffset(p) }
+
+func (t *LiteralType) Offset() int      { return nodeOffset(t) }
+func (t *LiteralType) EndOffset() int   { return nodeEndOffset(t) }
+func (t *LiteralType) StartOffset() int { return nodeStartOffset(t) }
+
+func (t *TypeofType) Offset() int      { return nodeOffset(t) }
+func (t *TypeofType) EndOffset() int   { return nodeEndOffset(t) }
+func (t *TypeofType) StartOffset() int { return nodeStartOffset(t) }
+
+func (t *TableType) Offset() int      { return nodeOffset(t) }
+func (t *TableType) EndOffset() int   { return nodeEndOffset(t) }
+func (t *TableType) StartOffset() int { return nodeStartOffset(t) }
+
+func (l *TypeEntryList) Offset() int      { return nodeOffset(l) }
+func (l *TypeEntryList) EndOffset() int   { return nodeEndOffset(l) }
+func (l *TypeEntryList) StartOffset() int { return nodeStartOffset(l) }
+
+func (e *TypeIndexEntry) Offset() int      { return nodeOffset(e) }
+func (e *TypeIndexEntry) EndOffset() int   { return nodeEndOffset(e) }
+func (e *TypeIndexEntry) StartOffset() int { return nodeStartOffset(e) }
+
+func (e *TypeFieldEntry) Offset() int      { return nodeOffset(e) }
+func (e *TypeFieldEntry) EndOffset() int   { return nodeEndOffset(e) }
+func (e *TypeFieldEntry) StartOffset() int { return nodeStartOffset(e) }
+
+func (e *TypeValueEntry) Offset() int      { return nodeOffset(e) }
+func (e *TypeValueEntry) EndOffset() int   { return nodeEndOffset(e) }
+func (e *TypeValueEntry) StartOffset() int { return nodeStartOffset(e) }
+
+func (t *FunctionType) Offset() int      { return nodeOffset(t) }
+func (t *FunctionType) EndOffset() int   { return nodeEndOffset(t) }
+func (t *FunctionType) StartOffset() int { return nodeStartOffset(t) }
+
+func (t *ParamType) Offset() int      { return nodeOffset(t) }
+func (t *ParamType) EndOffset() int   { return nodeEndOffset(t) }
+func (t *ParamType) StartOffset() int { return nodeStartOffset(t) }
+
+func (t *ParenType) Offset() int      { return nodeOffset(t) }
+func (t *ParenType) EndOffset() int   { return nodeEndOffset(t) }
+func (t *ParenType) StartOffset() int { return nodeStartOffset(t) }
+
+func (t *PackType) Offset() int      { return nodeOffset(t) }
+func (t *PackType) EndOffset() int   { return nodeEndOffset(t) }
+func (t *PackType) StartOffset() int { return nodeStartOffset(t) }
+
+func (t *VariadicType) Offset() int      { return nodeOffset(t) }
+func (t *VariadicType) EndOffset() int   { return nodeEndOffset(t) }
+func (t *VariadicType) StartOffset() int { return nodeStartOffset(t) }
+
+func (t *GenericPackType) Offset() int      { return nodeOffset(t) }
+func (t *GenericPackType) EndOffset() int   { return nodeEndOffset(t) }
+func (t *GenericPackType) StartOffset() int { return nodeStartOffset(t) }
+
+func (t *OptionalType) Offset() int      { return nodeOffset(t) }
+func (t *OptionalType) EndOffset() int   { return nodeEndOffset(t) }
+func (t *OptionalType) StartOffset() int { return nodeStartOffset(t) }
+
+func (t *UnionType) Offset() int      { return nodeOffset(t) }
+func (t *UnionType) EndOffset() int   { return nodeEndOffset(t) }
+func (t *UnionType) StartOffset() int { return nodeStartOffset(t) }
+
+func (t *IntersectionType) Offset() int      { return nodeOffset(t) }
+func (t *IntersectionType) EndOffset() int   { return nodeEndOffset(t) }
+func (t *IntersectionType) StartOffset() int { return nodeStartOffset(t) }
@@ -0,0 +1,137 @@
+package tree_test
+
+import (
+	"fmt"
+	"github.com/anaminus/luasyntax/go/parser"
+	"github.com/anaminus/luasyntax/go/token"
+	"github.com/anaminus/luasyntax/go/tree"
+	"testing"
+)
+
+func parseFile(t *testing.T, src string) *tree.File {
+	t.Helper()
+	f, err := (&parser.Config{Mode: parser.RecoverErrors}).ParseFile("", src)
+	if f == nil {
+		t.Fatalf("%q: %s", src, err)
+	}
+	return f
+}
+
+// finder finds the nth node of a type, formatted with %T.
+type finder struct {
+	typ  string
+	n    int
+	node tree.Node
+}
+
+func (f *finder) Visit(node tree.Node) tree.Visitor {
+	if f.node != nil {
+		return nil
+	}
+	if fmt.Sprintf("%T", node) == f.typ {
+		if f.n == 0 {
+			f.node = node
+			return nil
+		}
+		f.n--
+	}
+	return f
+}
+
+func find(root tree.Node, typ string, n int) tree.Node {
+	f := finder{typ: typ, n: n}
+	tree.Walk(&f, root)
+	return f.node
+}
+
+func TestNodeOffsets(t *testing.T) {
+	tests := []struct {
+		src  string
+		typ  string
+		n    int
+		text string // Source from Offset to EndOffset.
+		full string // Source from StartOffset to EndOffset.
+	}{
+		{"x = 1", "*tree.File", 0, "x = 1", "x = 1"},
+		{"  -- c\n  x = 1  ", "*tree.File", 0, "x = 1  ", "  -- c\n  x = 1  "},
+		{"x = 1 + 2 * 3", "*tree.BinopExpr", 0, "1 + 2 * 3", " 1 + 2 * 3"},
+		{"x = 1 + 2 * 3", "*tree.BinopExpr", 1, "2 * 3", " 2 * 3"},
+		{"f(a, --[[b]] b)", "*tree.VariableExpr", 2, "b", " --[[b]] b"},
+		{"f(a, b)", "*tree.ExprList", 0, "a, b", "a, b"},
+		{"local t = {1, 2,}", "*tree.TableCtor", 0, "{1, 2,}", " {1, 2,}"},
+		{"do\n\tlocal x\nend", "*tree.Block", 1, "local x", "\n\tlocal x"},
+		{"f = function(a, ...) end", "*tree.FunctionExpr", 0, "function(a, ...) end", " function(a, ...) end"},
+	}
+	for _, test := range tests {
+		f := parseFile(t, test.src)
+		node := find(f, test.typ, test.n)
+		if node == nil {
+			t.Errorf("%q: %s #%d not found", test.src, test.typ, test.n)
+			continue
+		}
+		off, start, end := node.Offset(), node.StartOffset(), node.EndOffset()
+		if off < 0 || start < 0 || end < 0 {
+			t.Errorf("%q: %s: unexpected offsets %d, %d, %d", test.src, test.typ, off, start, end)
+			continue
+		}
+		if got := test.src[off:end]; got != test.text {
+			t.Errorf("%q: %s: expected %q, got %q", test.src, test.typ, test.text, got)
+		}
+		if got := test.src[start:end]; got != test.full {
+			t.Errorf("%q: %s: expected full %q, got %q", test.src, test.typ, test.full, got)
+		}
+	}
+}
+
+func TestNodeOffsetsWithoutTokens(t *testing.T) {
+	tests := []tree.Node{
+		&tree.File{},
+		&tree.Block{},
+		&tree.BinopExpr{},
+		&tree.ExprList{},
+		&tree.CallStmt{},
+		&tree.FunctionExpr{},
+	}
+	// A bad expression may be empty.
+	f := parseFile(t, "x = @ + 1")
+	tests = append(tests, find(f, "*tree.BadExpr", 0))
+	for _, node := range tests {
+		if off, start, end := node.Offset(), node.StartOffset(), node.EndOffset(); off != -1 || start != -1 || end != -1 {
+			t.Errorf("%T: expected -1, got %d, %d, %d", node, off, start, end)
+		}
+	}
+}
+
+func TestNodeOffsetsInvalid(t *testing.T) {
+	// An invalid node uses the valid tokens that it has.
+	tests := []struct {
+		node       tree.Node
+		off, start int
+		end        int
+	}{
+		{
+			&tree.BinopExpr{
+				Left: &tree.NumberExpr{NumberToken: tree.Token{Type: token.NUMBERFLOAT, Offset: 4, Bytes: []byte("1")}},
+				BinopToken: tree.Token{Type: token.PLUS, Offset: 6, Bytes: []byte("+"),
+					Prefix: []tree.Prefix{{Type: token.SPACE, Bytes: []byte(" ")}}},
+			},
+			4, 4, 7,
+		},
+		{
+			&tree.ParenExpr{
+				Value:       &tree.NumberExpr{NumberToken: tree.Token{Type: token.NUMBERFLOAT, Offset: 1, Bytes: []byte("1")}},
+				RParenToken: tree.Token{Type: token.RPAREN, Offset: 2, Bytes: []byte(")")},
+			},
+			1, 1, 3,
+		},
+	}
+	for _, test := range tests {
+		node := test.node
+		if node.IsValid() {
+			t.Errorf("%T: expected invalid node", node)
+		}
+		if off, start, end := node.Offset(), node.StartOffset(), node.EndOffset(); off != test.off || start != test.start || end != test.end {
+			t.Errorf("%T: expected %d, %d, %d, got %d, %d, %d", node, test.off, test.start, test.end, off, start, end)
+		}
+	}
+}
@@ -101,6 +101,16 @@ func (l *NameList) WriteTo(w io.Writer) (n int64, err error) {
 		if !c.writeTo(w, item) {
 			break
 		}
+		if i < len(l.Attribs) && l.Attribs[i] != nil {
+			if !c.writeTo(w, l.Attribs[i]) {
+				break
+			}
+		}
+		if i < len(l.Types) && l.Types[i] != nil {
+			if !c.writeTo(w, l.Types[i]) {
+				break
+			}
+		}
 		if i < len(l.Seps) && l.Seps[i].Type.IsValid() {
 			if !c.writeTo(w, l.Seps[i]) {
 				break
@@ -110,6 +120,31 @@ func (l *NameList) WriteTo(w io.Writer) (n int64, err error) {
 	return c.finish()
 }
 
+func (a *Attrib) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	c.writeTo(w, a.LAngleToken)
+	c.writeTo(w, a.NameToken)
+	c.writeTo(w, a.RAngleToken)
+	return c.finish()
+}
+
+func (a *TypeAnnot) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	c.writeTo(w, a.ColonToken)
+	c.writeTo(w, a.Type)
+	return c.finish()
+}
+
+func (e *BadExpr) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	for _, tok := range e.Tokens {
+		if !c.writeTo(w, tok) {
+			break
+		}
+	}
+	return c.finish()
+}
+
 func (e *NumberExpr) WriteTo(w io.Writer) (n int64, err error) {
 	return e.NumberToken.WriteTo(w)
 }
@@ -205,6 +240,9 @@ func (e *ValueEntry) WriteTo(w io.Writer) (n int64, err error) {
 func (e *FunctionExpr) WriteTo(w io.Writer) (n int64, err error) {
 	var c copier
 	c.writeTo(w, e.FuncToken)
+	if e.Generics != nil {
+		c.writeTo(w, e.Generics)
+	}
 	c.writeTo(w, e.LParenToken)
 	if e.Params != nil {
 		c.writeTo(w, e.Params)
@@ -215,7 +253,13 @@ func (e *FunctionExpr) WriteTo(w io.Writer) (n int64, err error) {
 	} else if e.VarArgToken.Type.IsValid() {
 		c.writeTo(w, e.VarArgToken)
 	}
+	if e.VarArgType != nil {
+		c.writeTo(w, e.VarArgType)
+	}
 	c.writeTo(w, e.RParenToken)
+	if e.ReturnType != nil {
+		c.writeTo(w, e.ReturnType)
+	}
 	c.writeTo(w, &e.Body)
 	c.writeTo(w, e.EndToken)
 	return c.finish()
@@ -254,6 +298,54 @@ func (e *CallExpr) WriteTo(w io.Writer) (n int64, err error) {
 	return c.finish()
 }
 
+func (e *IfExpr) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	c.writeTo(w, e.IfToken)
+	c.writeTo(w, e.Cond)
+	c.writeTo(w, e.ThenToken)
+	c.writeTo(w, e.Value)
+	for i := range e.ElseIf {
+		if !c.writeTo(w, &e.ElseIf[i]) {
+			break
+		}
+	}
+	c.writeTo(w, e.ElseToken)
+	c.writeTo(w, e.Else)
+	return c.finish()
+}
+
+func (cl *ElseIfExprClause) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	c.writeTo(w, cl.ElseIfToken)
+	c.writeTo(w, cl.Cond)
+	c.writeTo(w, cl.ThenToken)
+	c.writeTo(w, cl.Value)
+	return c.finish()
+}
+
+func (e *InterpExpr) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	for i, seg := range e.Segments {
+		if !c.writeTo(w, seg) {
+			break
+		}
+		if i < len(e.Exprs) {
+			if !c.writeTo(w, e.Exprs[i]) {
+				break
+			}
+		}
+	}
+	return c.finish()
+}
+
+func (e *AssertExpr) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	c.writeTo(w, e.Value)
+	c.writeTo(w, e.AssertToken)
+	c.writeTo(w, e.Type)
+	return c.finish()
+}
+
 func (ac *ListArgs) WriteTo(w io.Writer) (n int64, err error) {
 	var c copier
 	c.writeTo(w, ac.LParenToken)
@@ -272,6 +364,20 @@ func (sc *StringArg) WriteTo(w io.Writer) (n int64, err error) {
 	return sc.Value.WriteTo(w)
 }
 
+func (s *BadStmt) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	for _, tok := range s.Tokens {
+		if !c.writeTo(w, tok) {
+			break
+		}
+	}
+	return c.finish()
+}
+
+func (s *MetaStmt) WriteTo(w io.Writer) (n int64, err error) {
+	return s.NameToken.WriteTo(w)
+}
+
 func (s *DoStmt) WriteTo(w io.Writer) (n int64, err error) {
 	var c copier
 	c.writeTo(w, s.DoToken)
@@ -288,6 +394,14 @@ func (s *AssignStmt) WriteTo(w io.Writer) (n int64, err error) {
 	return c.finish()
 }
 
+func (s *CompoundAssignStmt) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	c.writeTo(w, s.Left)
+	c.writeTo(w, s.OpToken)
+	c.writeTo(w, s.Right)
+	return c.finish()
+}
+
 func (s *CallStmt) WriteTo(w io.Writer) (n int64, err error) {
 	return s.Call.WriteTo(w)
 }
@@ -330,6 +444,9 @@ func (s *NumericForStmt) WriteTo(w io.Writer) (n int64, err error) {
 	var c copier
 	c.writeTo(w, s.ForToken)
 	c.writeTo(w, s.NameToken)
+	if s.NameType != nil {
+		c.writeTo(w, s.NameType)
+	}
 	c.writeTo(w, s.AssignToken)
 	c.writeTo(w, s.Min)
 	c.writeTo(w, s.MaxSepToken)
@@ -391,6 +508,9 @@ func (s *LocalFunctionStmt) WriteTo(w io.Writer) (n int64, err error) {
 	c.writeTo(w, s.LocalToken)
 	c.writeTo(w, s.Func.FuncToken)
 	c.writeTo(w, s.NameToken)
+	if s.Func.Generics != nil {
+		c.writeTo(w, s.Func.Generics)
+	}
 	c.writeTo(w, s.Func.LParenToken)
 	if s.Func.Params != nil {
 		c.writeTo(w, s.Func.Params)
@@ -401,7 +521,13 @@ func (s *LocalFunctionStmt) WriteTo(w io.Writer) (n int64, err error) {
 	} else if s.Func.VarArgToken.Type.IsValid() {
 		c.writeTo(w, s.Func.VarArgToken)
 	}
+	if s.Func.VarArgType != nil {
+		c.writeTo(w, s.Func.VarArgType)
+	}
 	c.writeTo(w, s.Func.RParenToken)
+	if s.Func.ReturnType != nil {
+		c.writeTo(w, s.Func.ReturnType)
+	}
 	c.writeTo(w, &s.Func.Body)
 	c.writeTo(w, s.Func.EndToken)
 	return c.finish()
@@ -411,6 +537,9 @@ func (s *FunctionStmt) WriteTo(w io.Writer) (n int64, err error) {
 	var c copier
 	c.writeTo(w, s.Func.FuncToken)
 	c.writeTo(w, &s.Name)
+	if s.Func.Generics != nil {
+		c.writeTo(w, s.Func.Generics)
+	}
 	c.writeTo(w, s.Func.LParenToken)
 	if s.Func.Params != nil {
 		c.writeTo(w, s.Func.Params)
@@ -421,7 +550,13 @@ func (s *FunctionStmt) WriteTo(w io.Writer) (n int64, err error) {
 	} else if s.Func.VarArgToken.Type.IsValid() {
 		c.writeTo(w, s.Func.VarArgToken)
 	}
+	if s.Func.VarArgType != nil {
+		c.writeTo(w, s.Func.VarArgType)
+	}
 	c.writeTo(w, s.Func.RParenToken)
+	if s.Func.ReturnType != nil {
+		c.writeTo(w, s.Func.ReturnType)
+	}
 	c.writeTo(w, &s.Func.Body)
 	c.writeTo(w, s.Func.EndToken)
 	return c.finish()
@@ -450,6 +585,40 @@ func (s *BreakStmt) WriteTo(w io.Writer) (n int64, err error) {
 	return s.BreakToken.WriteTo(w)
 }
 
+func (s *ContinueStmt) WriteTo(w io.Writer) (n int64, err error) {
+	return s.ContinueToken.WriteTo(w)
+}
+
+func (s *GotoStmt) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	c.writeTo(w, s.GotoToken)
+	c.writeTo(w, s.NameToken)
+	return c.finish()
+}
+
+func (s *LabelStmt) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	c.writeTo(w, s.LColonToken)
+	c.writeTo(w, s.NameToken)
+	c.writeTo(w, s.RColonToken)
+	return c.finish()
+}
+
+func (s *TypeStmt) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	if s.ExportToken.Type.IsValid() {
+		c.writeTo(w, s.ExportToken)
+	}
+	c.writeTo(w, s.TypeToken)
+	c.writeTo(w, s.NameToken)
+	if s.Generics != nil {
+		c.writeTo(w, s.Generics)
+	}
+	c.writeTo(w, s.AssignToken)
+	c.writeTo(w, s.Type)
+	return c.finish()
+}
+
 func (s *ReturnStmt) WriteTo(w io.Writer) (n int64, err error) {
 	var c copier
 	c.writeTo(w, s.ReturnToken)
@@ -458,3 +627,218 @@ func (s *ReturnStmt) WriteTo(w io.Writer) (n int64, err error) {
 	}
 	return c.finish()
 }
+
+func (l *TypeList) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	for i, item := range l.Items {
+		if !c.writeTo(w, item) {
+			break
+		}
+		if i < len(l.Seps) && l.Seps[i].Type.IsValid() {
+			if !c.writeTo(w, l.Seps[i]) {
+				break
+			}
+		}
+	}
+	return c.finish()
+}
+
+func (l *GenericList) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	c.writeTo(w, l.LAngleToken)
+	for i := range l.Items {
+		if !c.writeTo(w, &l.Items[i]) {
+			break
+		}
+		if i < len(l.Seps) && l.Seps[i].Type.IsValid() {
+			if !c.writeTo(w, l.Seps[i]) {
+				break
+			}
+		}
+	}
+	c.writeTo(w, l.RAngleToken)
+	return c.finish()
+}
+
+func (p *GenericParam) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	c.writeTo(w, p.NameToken)
+	if p.VarArgToken.Type.IsValid() {
+		c.writeTo(w, p.VarArgToken)
+	}
+	if p.AssignToken.Type.IsValid() {
+		c.writeTo(w, p.AssignToken)
+		c.writeTo(w, p.Default)
+	}
+	return c.finish()
+}
+
+func (t *NamedType) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	if t.ModuleToken.Type.IsValid() {
+		c.writeTo(w, t.ModuleToken)
+		c.writeTo(w, t.DotToken)
+	}
+	c.writeTo(w, t.NameToken)
+	if t.Params != nil {
+		c.writeTo(w, t.Params)
+	}
+	return c.finish()
+}
+
+func (p *TypeParams) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	c.writeTo(w, p.LAngleToken)
+	if p.Types != nil {
+		c.writeTo(w, p.Types)
+	}
+	c.writeTo(w, p.RAngleToken)
+	return c.finish()
+}
+
+func (t *LiteralType) WriteTo(w io.Writer) (n int64, err error) {
+	return t.LiteralToken.WriteTo(w)
+}
+
+func (t *TypeofType) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	c.writeTo(w, t.TypeofToken)
+	c.writeTo(w, t.LParenToken)
+	c.writeTo(w, t.Value)
+	c.writeTo(w, t.RParenToken)
+	return c.finish()
+}
+
+func (t *TableType) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	c.writeTo(w, t.LBraceToken)
+	c.writeTo(w, &t.Entries)
+	c.writeTo(w, t.RBraceToken)
+	return c.finish()
+}
+
+func (l *TypeEntryList) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	for i, entry := range l.Items {
+		if !c.writeTo(w, entry) {
+			break
+		}
+		if i < len(l.Seps) && l.Seps[i].Type.IsValid() {
+			if !c.writeTo(w, l.Seps[i]) {
+				break
+			}
+		}
+	}
+	return c.finish()
+}
+
+func (e *TypeIndexEntry) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	c.writeTo(w, e.LBrackToken)
+	c.writeTo(w, e.Key)
+	c.writeTo(w, e.RBrackToken)
+	c.writeTo(w, e.ColonToken)
+	c.writeTo(w, e.Value)
+	return c.finish()
+}
+
+func (e *TypeFieldEntry) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	c.writeTo(w, e.NameToken)
+	c.writeTo(w, e.ColonToken)
+	c.writeTo(w, e.Value)
+	return c.finish()
+}
+
+func (e *TypeValueEntry) WriteTo(w io.Writer) (n int64, err error) {
+	return e.Value.WriteTo(w)
+}
+
+func (t *FunctionType) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	if t.Generics != nil {
+		c.writeTo(w, t.Generics)
+	}
+	c.writeTo(w, t.LParenToken)
+	if t.Params != nil {
+		c.writeTo(w, t.Params)
+	}
+	c.writeTo(w, t.RParenToken)
+	c.writeTo(w, t.ArrowToken)
+	c.writeTo(w, t.Return)
+	return c.finish()
+}
+
+func (t *ParamType) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	c.writeTo(w, t.NameToken)
+	c.writeTo(w, t.ColonToken)
+	c.writeTo(w, t.Type)
+	return c.finish()
+}
+
+func (t *ParenType) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	c.writeTo(w, t.LParenToken)
+	c.writeTo(w, t.Value)
+	c.writeTo(w, t.RParenToken)
+	return c.finish()
+}
+
+func (t *PackType) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	c.writeTo(w, t.LParenToken)
+	if t.Types != nil {
+		c.writeTo(w, t.Types)
+	}
+	c.writeTo(w, t.RParenToken)
+	return c.finish()
+}
+
+func (t *VariadicType) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	c.writeTo(w, t.VarArgToken)
+	c.writeTo(w, t.Value)
+	return c.finish()
+}
+
+func (t *GenericPackType) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	c.writeTo(w, t.NameToken)
+	c.writeTo(w, t.VarArgToken)
+	return c.finish()
+}
+
+func (t *OptionalType) WriteTo(w io.Writer) (n int64, err error) {
+	var c copier
+	c.writeTo(w, t.Value)
+	c.writeTo(w, t.QuestionToken)
+	return c.finish()
+}
+
+func (t *UnionType) WriteTo(w io.Writer) (n int64, err error) {
+	return writeOpType(w, t.LeadToken, t.Items, t.Seps)
+}
+
+func (t *IntersectionType) WriteTo(w io.Writer) (n int64, err error) {
+	return writeOpType(w, t.LeadToken, t.Items, t.Seps)
+}
+
+// writeOpType writes the components of a UnionType or IntersectionType.
+func writeOpType(w io.Writer, lead Token, items []Type, seps []Token) (n int64, err error) {
+	var c copier
+	if lead.Type.IsValid() {
+		c.writeTo(w, lead)
+	}
+	for i, item := range items {
+		if !c.writeTo(w, item) {
+			break
+		}
+		if i < len(seps) && seps[i].Type.IsValid() {
+			if !c.writeTo(w, seps[i]) {
+				break
+			}
+		}
+	}
+	return c.finish()
+}
@@ -51,6 +51,8 @@ func FixTokenOffsets(node Node, offset int) {
 	if file, ok := node.(*File); ok {
 		r.info = file.Info
 		r.info.ClearLines()
+		// The original content no longer corresponds to the tree.
+		r.info.SetContent(nil)
 	}
 	Walk(&r, node)
 }
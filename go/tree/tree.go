@@ -25,6 +25,19 @@ type Node interface {
 	// LastToken returns the last Token in the node. Assumes that the node is
 	// valid.
 	LastToken() *Token
+	// Offset returns the offset of the first character of the node,
+	// excluding the prefix of the first token. Returns -1 if the node has no
+	// valid tokens. Does not assume that the node is valid.
+	Offset() int
+	// StartOffset returns the offset of the first character of the node,
+	// including the prefix of the first token, such as leading comments and
+	// whitespace. Returns -1 if the node has no valid tokens. Does not assume
+	// that the node is valid.
+	StartOffset() int
+	// EndOffset returns the offset following the last character of the node.
+	// Returns -1 if the node has no valid tokens. Does not assume that the
+	// node is valid.
+	EndOffset() int
 	// Implements the io.WriterTo interface by writing the source-code
 	// equivalent of the node. Assumes that the node is valid.
 	io.WriterTo
@@ -82,6 +95,8 @@ type File struct {
 	// Info contains information about the file, such as the name, and line
 	// offsets.
 	Info *token.File
+	// Dialect is the dialect of Lua with which the file was parsed.
+	Dialect token.Dialect
 	// Body is the top-level block of the file.
 	Body Block
 	// EOFToken is the EOF token at the end of the file.
@@ -127,6 +142,15 @@ func (l *ExprList) Len() int {
 type NameList struct {
 	// Items contains each NAME in the list.
 	Items []Token
+	// Attribs contains the attribute following each name. It is empty if no
+	// name has an attribute. Otherwise, the length of Attribs is the same as
+	// Items, and an attribute is nil if not present. Attributes are valid only
+	// for the names of a LocalVarStmt.
+	Attribs []*Attrib
+	// Types contains the type annotation following each name. It is empty if
+	// no name has an annotation. Otherwise, the length of Types is the same as
+	// Items, and an annotation is nil if not present.
+	Types []*TypeAnnot
 	// Seps contains each COMMA between names. The length of Seps is one less
 	// then the length of Names.
 	Seps []Token
@@ -137,6 +161,34 @@ func (l *NameList) Len() int {
 	return len(l.Items) + len(l.Seps)
 }
 
+// BadExpr is a placeholder for an expression that could not be parsed due to
+// syntax errors.
+type BadExpr struct {
+	// Tokens contains each token that was skipped while parsing the
+	// expression. It may be empty.
+	Tokens []Token
+}
+
+func (BadExpr) exprNode() {}
+
+// Attrib represents an attribute of a local variable, such as `<const>`.
+type Attrib struct {
+	// LAngleToken is the LT token that opens the attribute.
+	LAngleToken Token
+	// NameToken is the name of the attribute.
+	NameToken Token
+	// RAngleToken is the GT token that closes the attribute.
+	RAngleToken Token
+}
+
+// TypeAnnot represents a type annotation, such as `: number`.
+type TypeAnnot struct {
+	// ColonToken is the COLON token that begins the annotation.
+	ColonToken Token
+	// Type is the annotated type.
+	Type Type
+}
+
 // NumberExpr represents a Lua number expression.
 type NumberExpr struct {
 	// NumberToken is the number token holding the content of the expression.
@@ -294,6 +346,9 @@ func (ValueEntry) entryNode() {}
 type FunctionExpr struct {
 	// FuncToken is the FUNCTION token that begins the function.
 	FuncToken Token
+	// Generics is the list of generic type parameters of the function. It is
+	// nil if not present.
+	Generics *GenericList
 	// LParenToken is the LPAREN token that opens the function's parameters.
 	LParenToken Token
 	// Params is a list of named parameters of the function. It will be nil if
@@ -306,8 +361,14 @@ type FunctionExpr struct {
 	// VarArgToken is the VARARG token following the named parameters of the
 	// function. It will be INVALID if the vararg parameter is not present.
 	VarArgToken Token
+	// VarArgType is the type annotation of the vararg parameter. It is nil if
+	// not present.
+	VarArgType *TypeAnnot
 	// RParenToken is the RPAREN token that closes the function's parameters.
 	RParenToken Token
+	// ReturnType is the type annotation of the function's return values. It is
+	// nil if not present.
+	ReturnType *TypeAnnot
 	// Body is the body of the function.
 	Body Block
 	// EndToken is the END token that ends the function.
@@ -344,6 +405,66 @@ type IndexExpr struct {
 
 func (IndexExpr) exprNode() {}
 
+// IfExpr represents an `if .. then .. else ..` expression.
+type IfExpr struct {
+	// IfToken is the IF token that begins the if expression.
+	IfToken Token
+	// Cond is the condition of the if expression.
+	Cond Expr
+	// ThenToken is the THEN token that begins the value of the if expression.
+	ThenToken Token
+	// Value is the value of the if expression when the condition is true.
+	Value Expr
+	// ElseIf is a list of zero or more elseif clauses of the if expression.
+	ElseIf []ElseIfExprClause
+	// ElseToken is the ELSE token that begins the alternative value.
+	ElseToken Token
+	// Else is the value of the if expression when no condition is true.
+	Else Expr
+}
+
+func (IfExpr) exprNode() {}
+
+// ElseIfExprClause represents an `elseif .. then ..` clause within an `if`
+// expression.
+type ElseIfExprClause struct {
+	// ElseIfToken is the ELSEIF token that begins the elseif clause.
+	ElseIfToken Token
+	// Cond is the condition of the elseif clause.
+	Cond Expr
+	// ThenToken is the THEN token that begins the value of the elseif clause.
+	ThenToken Token
+	// Value is the value of the elseif clause.
+	Value Expr
+}
+
+// InterpExpr represents an interpolated string expression.
+type InterpExpr struct {
+	// Segments contains each string segment of the expression. The first
+	// segment is an INTERPSTRING when the length of Segments is 1, and an
+	// INTERPBEGIN otherwise. The last segment is an INTERPEND, and segments in
+	// between are INTERPMID.
+	Segments []Token
+	// Exprs contains each expression following a segment. The length of Exprs
+	// is one less than the length of Segments.
+	Exprs []Expr
+}
+
+func (InterpExpr) exprNode() {}
+
+// AssertExpr represents an expression asserted to have a type, such as `x ::
+// number`.
+type AssertExpr struct {
+	// Value is the expression being asserted.
+	Value Expr
+	// AssertToken is the DBCOLON token that separates the type.
+	AssertToken Token
+	// Type is the asserted type.
+	Type Type
+}
+
+func (AssertExpr) exprNode() {}
+
 // Call is the interface that all function call expressions implement.
 type Call interface {
 	Node
@@ -422,6 +543,25 @@ type Stmt interface {
 	stmtNode()
 }
 
+// BadStmt is a placeholder for a statement that could not be parsed due to
+// syntax errors.
+type BadStmt struct {
+	// Tokens contains each token that was consumed or skipped while parsing
+	// the statement.
+	Tokens []Token
+}
+
+func (BadStmt) stmtNode() {}
+
+// MetaStmt represents a statement within a template that consists of only a
+// metavariable, which is a placeholder for one or more statements.
+type MetaStmt struct {
+	// NameToken is the NAME token of the metavariable, including the `$`.
+	NameToken Token
+}
+
+func (MetaStmt) stmtNode() {}
+
 // DoStmt represents a `do ... end` Lua statement.
 type DoStmt struct {
 	// DoToken is the DO token that begins the do statement.
@@ -449,6 +589,19 @@ type AssignStmt struct {
 
 func (AssignStmt) stmtNode() {}
 
+// CompoundAssignStmt represents the assignment of a variable with the result of
+// an operation on the variable, such as `x += 1`.
+type CompoundAssignStmt struct {
+	// Left is the variable being assigned.
+	Left Expr
+	// OpToken is the compound assignment operator.
+	OpToken Token
+	// Right is the right side of the operation.
+	Right Expr
+}
+
+func (CompoundAssignStmt) stmtNode() {}
+
 // CallStmt represents a call expression as a statement.
 type CallStmt struct {
 	// Call is the call expression.
@@ -503,6 +656,9 @@ type NumericForStmt struct {
 	ForToken Token
 	// NameToken is the name of the control variable.
 	NameToken Token
+	// NameType is the type annotation of the control variable. It is nil if
+	// not present.
+	NameType *TypeAnnot
 	// AssignToken is the ASSIGN token that begins the control expressions.
 	AssignToken Token
 	// Min is the expression indicating the lower bound of the control variable.
@@ -658,6 +814,58 @@ type BreakStmt struct {
 
 func (BreakStmt) stmtNode() {}
 
+// ContinueStmt represents a `continue` statement.
+type ContinueStmt struct {
+	// ContinueToken is the NAME token of the continue statement. Because
+	// `continue` is not a reserved word, it is not a keyword token.
+	ContinueToken Token
+}
+
+func (ContinueStmt) stmtNode() {}
+
+// GotoStmt represents a `goto` statement.
+type GotoStmt struct {
+	// GotoToken is the GOTO token of the goto statement.
+	GotoToken Token
+	// NameToken is the name of the label to jump to.
+	NameToken Token
+}
+
+func (GotoStmt) stmtNode() {}
+
+// LabelStmt represents a `::label::` statement.
+type LabelStmt struct {
+	// LColonToken is the DBCOLON token that opens the label.
+	LColonToken Token
+	// NameToken is the name of the label.
+	NameToken Token
+	// RColonToken is the DBCOLON token that closes the label.
+	RColonToken Token
+}
+
+func (LabelStmt) stmtNode() {}
+
+// TypeStmt represents a `type` declaration.
+type TypeStmt struct {
+	// ExportToken is the NAME token of the `export` prefix. It is INVALID if
+	// not present.
+	ExportToken Token
+	// TypeToken is the NAME token of the `type` word that begins the
+	// declaration.
+	TypeToken Token
+	// NameToken is the name of the declared type.
+	NameToken Token
+	// Generics is the list of generic type parameters of the declaration. It
+	// is nil if not present.
+	Generics *GenericList
+	// AssignToken is the ASSIGN token that begins the type.
+	AssignToken Token
+	// Type is the declared type.
+	Type Type
+}
+
+func (TypeStmt) stmtNode() {}
+
 // ReturnStmt represents a `return` statement.
 type ReturnStmt struct {
 	// ReturnToken is the RETURN token of the return statement.
@@ -668,3 +876,297 @@ type ReturnStmt struct {
 }
 
 func (ReturnStmt) stmtNode() {}
+
+// Type is the interface that all type nodes implement.
+type Type interface {
+	Node
+	typeNode()
+}
+
+// TypeList represents a list of types.
+type TypeList struct {
+	// Items contains each type in the list.
+	Items []Type
+	// Seps contains each COMMA between types. The length of Seps is one less
+	// than the length of Items.
+	Seps []Token
+}
+
+// Len returns the combined length of Items and Seps.
+func (l *TypeList) Len() int {
+	return len(l.Items) + len(l.Seps)
+}
+
+// GenericList represents a list of generic type parameters, such as `<T,
+// U...>`.
+type GenericList struct {
+	// LAngleToken is the LT token that opens the list.
+	LAngleToken Token
+	// Items contains each parameter in the list.
+	Items []GenericParam
+	// Seps contains each COMMA between parameters. The length of Seps is one
+	// less than the length of Items.
+	Seps []Token
+	// RAngleToken is the GT token that closes the list.
+	RAngleToken Token
+}
+
+// Len returns the combined length of Items and Seps.
+func (l *GenericList) Len() int {
+	return len(l.Items) + len(l.Seps)
+}
+
+// GenericParam represents a generic type parameter.
+type GenericParam struct {
+	// NameToken is the name of the parameter.
+	NameToken Token
+	// VarArgToken is the VARARG token that indicates a type pack parameter.
+	// It is INVALID if not present.
+	VarArgToken Token
+	// AssignToken is the ASSIGN token that begins the default type. It is
+	// INVALID if not present.
+	AssignToken Token
+	// Default is the default type of the parameter. It is nil if not present.
+	Default Type
+}
+
+// NamedType represents a type referred to by name, such as `number` or
+// `module.Type<T>`.
+type NamedType struct {
+	// ModuleToken is the name of the module containing the type. It is
+	// INVALID if not present.
+	ModuleToken Token
+	// DotToken is the DOT token that separates the module from the name. It
+	// is INVALID if the module is not present.
+	DotToken Token
+	// NameToken is the name of the type.
+	NameToken Token
+	// Params is the list of type arguments of the type. It is nil if not
+	// present.
+	Params *TypeParams
+}
+
+func (NamedType) typeNode() {}
+
+// TypeParams represents the type arguments of a named type, such as
+// `<number, string>`.
+type TypeParams struct {
+	// LAngleToken is the LT token that opens the arguments.
+	LAngleToken Token
+	// Types contains each argument. It is nil if there are no arguments.
+	Types *TypeList
+	// RAngleToken is the GT token that closes the arguments.
+	RAngleToken Token
+}
+
+// LiteralType represents a singleton type, which is `nil`, a boolean, or a
+// string.
+type LiteralType struct {
+	// LiteralToken is the token holding the value of the type.
+	LiteralToken Token
+}
+
+func (LiteralType) typeNode() {}
+
+// TypeofType represents the type of an expression, such as `typeof(x)`.
+type TypeofType struct {
+	// TypeofToken is the NAME token of the `typeof` word.
+	TypeofToken Token
+	// LParenToken is the LPAREN token that opens the expression.
+	LParenToken Token
+	// Value is the expression from which the type is determined.
+	Value Expr
+	// RParenToken is the RPAREN token that closes the expression.
+	RParenToken Token
+}
+
+func (TypeofType) typeNode() {}
+
+// TableType represents a table type.
+type TableType struct {
+	// LBraceToken is the LBRACE token that opens the table.
+	LBraceToken Token
+	// Entries is a list of entries in the table.
+	Entries TypeEntryList
+	// RBraceToken is the RBRACE token that closes the table.
+	RBraceToken Token
+}
+
+func (TableType) typeNode() {}
+
+// TypeEntryList represents a list of entries in a table type.
+type TypeEntryList struct {
+	// Items contains each entry in the list.
+	Items []TypeEntry
+	// Seps contains each separator between entries, which will be either a
+	// COMMA or SEMICOLON. The length of Seps is equal to or one less than the
+	// length of Items.
+	Seps []Token
+}
+
+// Len returns the combined length of Items and Seps.
+func (l *TypeEntryList) Len() int {
+	return len(l.Items) + len(l.Seps)
+}
+
+// TypeEntry is the interface that all table type entry nodes implement.
+type TypeEntry interface {
+	Node
+	typeEntryNode()
+}
+
+// TypeIndexEntry represents a table type entry defining an indexer, such as
+// `[string]: number`.
+type TypeIndexEntry struct {
+	// LBrackToken is the LBRACK token that begins the entry key.
+	LBrackToken Token
+	// Key is the type of the keys of the entry.
+	Key Type
+	// RBrackToken is the RBRACK token that ends the entry key.
+	RBrackToken Token
+	// ColonToken is the COLON token that begins the entry value.
+	ColonToken Token
+	// Value is the type of the values of the entry.
+	Value Type
+}
+
+func (TypeIndexEntry) typeEntryNode() {}
+
+// TypeFieldEntry represents a table type entry defining a property, such as
+// `name: string`.
+type TypeFieldEntry struct {
+	// NameToken is the name of the property.
+	NameToken Token
+	// ColonToken is the COLON token that begins the entry value.
+	ColonToken Token
+	// Value is the type of the property.
+	Value Type
+}
+
+func (TypeFieldEntry) typeEntryNode() {}
+
+// TypeValueEntry represents a table type entry defining the type of an array,
+// such as `{number}`.
+type TypeValueEntry struct {
+	// Value is the type of the values of the array.
+	Value Type
+}
+
+func (TypeValueEntry) typeEntryNode() {}
+
+// FunctionType represents a function type, such as `(number) -> string`.
+type FunctionType struct {
+	// Generics is the list of generic type parameters of the function. It is
+	// nil if not present.
+	Generics *GenericList
+	// LParenToken is the LPAREN token that opens the parameters.
+	LParenToken Token
+	// Params contains the type of each parameter. It is nil if there are no
+	// parameters.
+	Params *TypeList
+	// RParenToken is the RPAREN token that closes the parameters.
+	RParenToken Token
+	// ArrowToken is the ARROW token that begins the return type.
+	ArrowToken Token
+	// Return is the return type of the function.
+	Return Type
+}
+
+func (FunctionType) typeNode() {}
+
+// ParamType represents a named parameter within the parameters of a
+// FunctionType, such as `x: number`.
+type ParamType struct {
+	// NameToken is the name of the parameter.
+	NameToken Token
+	// ColonToken is the COLON token that begins the type.
+	ColonToken Token
+	// Type is the type of the parameter.
+	Type Type
+}
+
+func (ParamType) typeNode() {}
+
+// ParenType represents a type enclosed in parentheses.
+type ParenType struct {
+	// LParenToken is the LPAREN token that opens the type.
+	LParenToken Token
+	// Value is the enclosed type.
+	Value Type
+	// RParenToken is the RPAREN token that closes the type.
+	RParenToken Token
+}
+
+func (ParenType) typeNode() {}
+
+// PackType represents a list of types enclosed in parentheses, such as
+// `(number, string)`.
+type PackType struct {
+	// LParenToken is the LPAREN token that opens the pack.
+	LParenToken Token
+	// Types contains each type in the pack. It is nil if the pack is empty.
+	Types *TypeList
+	// RParenToken is the RPAREN token that closes the pack.
+	RParenToken Token
+}
+
+func (PackType) typeNode() {}
+
+// VariadicType represents a variable number of values of a type, such as
+// `...number`.
+type VariadicType struct {
+	// VarArgToken is the VARARG token that begins the type.
+	VarArgToken Token
+	// Value is the type of each value.
+	Value Type
+}
+
+func (VariadicType) typeNode() {}
+
+// GenericPackType represents a generic type pack, such as `T...`.
+type GenericPackType struct {
+	// NameToken is the name of the type pack.
+	NameToken Token
+	// VarArgToken is the VARARG token that follows the name.
+	VarArgToken Token
+}
+
+func (GenericPackType) typeNode() {}
+
+// OptionalType represents a type that may also be nil, such as `number?`.
+type OptionalType struct {
+	// Value is the type being made optional.
+	Value Type
+	// QuestionToken is the QUESTION token that follows the type.
+	QuestionToken Token
+}
+
+func (OptionalType) typeNode() {}
+
+// UnionType represents a union of types, such as `number | string`.
+type UnionType struct {
+	// LeadToken is a PIPE token preceding the first type. It is INVALID if
+	// not present.
+	LeadToken Token
+	// Items contains each type in the union.
+	Items []Type
+	// Seps contains each PIPE between types. The length of Seps is one less
+	// than the length of Items.
+	Seps []Token
+}
+
+func (UnionType) typeNode() {}
+
+// IntersectionType represents an intersection of types, such as `A & B`.
+type IntersectionType struct {
+	// LeadToken is an AMPERSAND token preceding the first type. It is INVALID
+	// if not present.
+	LeadToken Token
+	// Items contains each type in the intersection.
+	Items []Type
+	// Seps contains each AMPERSAND between types. The length of Seps is one
+	// less than the length of Items.
+	Seps []Token
+}
+
+func (IntersectionType) typeNode() {}
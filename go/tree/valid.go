@@ -62,6 +62,12 @@ func (l *NameList) IsValid() bool {
 	if len(l.Items) == 0 || len(l.Seps) != len(l.Items)-1 {
 		return false
 	}
+	if len(l.Attribs) != 0 && len(l.Attribs) != len(l.Items) {
+		return false
+	}
+	if len(l.Types) != 0 && len(l.Types) != len(l.Items) {
+		return false
+	}
 	for _, item := range l.Items {
 		if !ist(item, token.NAME) {
 			return false
@@ -75,6 +81,26 @@ func (l *NameList) IsValid() bool {
 	return true
 }
 
+func (a *Attrib) IsValid() bool {
+	return ist(a.LAngleToken, token.LT) &&
+		ist(a.NameToken, token.NAME) &&
+		ist(a.RAngleToken, token.GT)
+}
+
+func (a *TypeAnnot) IsValid() bool {
+	return ist(a.ColonToken, token.COLON) &&
+		isv(a.Type)
+}
+
+func (e *BadExpr) IsValid() bool {
+	for _, tok := range e.Tokens {
+		if !tok.Type.IsValid() {
+			return false
+		}
+	}
+	return true
+}
+
 func (e *NumberExpr) IsValid() bool {
 	return e.NumberToken.Type.IsNumber()
 }
@@ -163,6 +189,9 @@ func (e *FunctionExpr) IsValid() bool {
 		ist(e.EndToken, token.END)) {
 		return false
 	}
+	if e.VarArgType != nil && !ist(e.VarArgToken, token.VARARG) {
+		return false
+	}
 	if ist(e.VarArgToken, token.VARARG) {
 		if e.Params != nil {
 			return ist(e.VarArgSepToken, token.COMMA)
@@ -199,6 +228,59 @@ func (e *CallExpr) IsValid() bool {
 		isv(e.Args)
 }
 
+func (e *IfExpr) IsValid() bool {
+	return ist(e.IfToken, token.IF) &&
+		isv(e.Cond) &&
+		ist(e.ThenToken, token.THEN) &&
+		isv(e.Value) &&
+		ist(e.ElseToken, token.ELSE) &&
+		isv(e.Else)
+}
+
+func (c *ElseIfExprClause) IsValid() bool {
+	return ist(c.ElseIfToken, token.ELSEIF) &&
+		isv(c.Cond) &&
+		ist(c.ThenToken, token.THEN) &&
+		isv(c.Value)
+}
+
+func (e *InterpExpr) IsValid() bool {
+	if len(e.Segments) == 0 || len(e.Exprs) != len(e.Segments)-1 {
+		return false
+	}
+	if len(e.Segments) == 1 {
+		return ist(e.Segments[0], token.INTERPSTRING)
+	}
+	for i, seg := range e.Segments {
+		switch i {
+		case 0:
+			if !ist(seg, token.INTERPBEGIN) {
+				return false
+			}
+		case len(e.Segments) - 1:
+			if !ist(seg, token.INTERPEND) {
+				return false
+			}
+		default:
+			if !ist(seg, token.INTERPMID) {
+				return false
+			}
+		}
+	}
+	for _, expr := range e.Exprs {
+		if !isv(expr) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *AssertExpr) IsValid() bool {
+	return isv(e.Value) &&
+		ist(e.AssertToken, token.DBCOLON) &&
+		isv(e.Type)
+}
+
 func (c *ListArgs) IsValid() bool {
 	return ist(c.LParenToken, token.LPAREN) &&
 		ist(c.RParenToken, token.RPAREN)
@@ -212,6 +294,19 @@ func (c *StringArg) IsValid() bool {
 	return true
 }
 
+func (s *BadStmt) IsValid() bool {
+	for _, tok := range s.Tokens {
+		if !tok.Type.IsValid() {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *MetaStmt) IsValid() bool {
+	return ist(s.NameToken, token.NAME)
+}
+
 func (s *DoStmt) IsValid() bool {
 	return ist(s.DoToken, token.DO) &&
 		ist(s.EndToken, token.END)
@@ -221,6 +316,12 @@ func (s *AssignStmt) IsValid() bool {
 	return ist(s.AssignToken, token.ASSIGN)
 }
 
+func (s *CompoundAssignStmt) IsValid() bool {
+	return isv(s.Left) &&
+		s.OpToken.Type.IsCompoundAssign() &&
+		isv(s.Right)
+}
+
 func (s *CallStmt) IsValid() bool {
 	return isv(s.Call)
 }
@@ -328,6 +429,214 @@ func (s *BreakStmt) IsValid() bool {
 	return ist(s.BreakToken, token.BREAK)
 }
 
+func (s *ContinueStmt) IsValid() bool {
+	return ist(s.ContinueToken, token.NAME)
+}
+
+func (s *GotoStmt) IsValid() bool {
+	return ist(s.GotoToken, token.GOTO) &&
+		ist(s.NameToken, token.NAME)
+}
+
+func (s *LabelStmt) IsValid() bool {
+	return ist(s.LColonToken, token.DBCOLON) &&
+		ist(s.NameToken, token.NAME) &&
+		ist(s.RColonToken, token.DBCOLON)
+}
+
+func (s *TypeStmt) IsValid() bool {
+	return ist2(s.ExportToken, token.NAME, token.INVALID) &&
+		ist(s.TypeToken, token.NAME) &&
+		ist(s.NameToken, token.NAME) &&
+		ist(s.AssignToken, token.ASSIGN) &&
+		isv(s.Type)
+}
+
 func (s *ReturnStmt) IsValid() bool {
 	return ist(s.ReturnToken, token.RETURN)
 }
+
+func (l *TypeList) IsValid() bool {
+	if len(l.Items) == 0 || len(l.Seps) != len(l.Items)-1 {
+		return false
+	}
+	for _, item := range l.Items {
+		if !isv(item) {
+			return false
+		}
+	}
+	for _, sep := range l.Seps {
+		if !ist(sep, token.COMMA) {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *GenericList) IsValid() bool {
+	if !(ist(l.LAngleToken, token.LT) &&
+		ist(l.RAngleToken, token.GT)) {
+		return false
+	}
+	if len(l.Items) == 0 || len(l.Seps) != len(l.Items)-1 {
+		return false
+	}
+	for _, sep := range l.Seps {
+		if !ist(sep, token.COMMA) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *GenericParam) IsValid() bool {
+	if !(ist(p.NameToken, token.NAME) &&
+		ist2(p.VarArgToken, token.VARARG, token.INVALID)) {
+		return false
+	}
+	if ist(p.AssignToken, token.ASSIGN) {
+		return isv(p.Default)
+	} else if ist(p.AssignToken, token.INVALID) {
+		return !isv(p.Default)
+	}
+	return false
+}
+
+func (t *NamedType) IsValid() bool {
+	if !ist(t.NameToken, token.NAME) {
+		return false
+	}
+	if ist(t.ModuleToken, token.NAME) {
+		return ist(t.DotToken, token.DOT)
+	} else if ist(t.ModuleToken, token.INVALID) {
+		return ist(t.DotToken, token.INVALID)
+	}
+	return false
+}
+
+func (p *TypeParams) IsValid() bool {
+	return ist(p.LAngleToken, token.LT) &&
+		ist(p.RAngleToken, token.GT)
+}
+
+func (t *LiteralType) IsValid() bool {
+	return ist(t.LiteralToken, token.NIL) ||
+		t.LiteralToken.Type.IsBool() ||
+		t.LiteralToken.Type.IsString()
+}
+
+func (t *TypeofType) IsValid() bool {
+	return ist(t.TypeofToken, token.NAME) &&
+		ist(t.LParenToken, token.LPAREN) &&
+		isv(t.Value) &&
+		ist(t.RParenToken, token.RPAREN)
+}
+
+func (t *TableType) IsValid() bool {
+	return ist(t.LBraceToken, token.LBRACE) &&
+		ist(t.RBraceToken, token.RBRACE)
+}
+
+func (l *TypeEntryList) IsValid() bool {
+	if len(l.Seps) != len(l.Items) && len(l.Seps) != len(l.Items)-1 {
+		return false
+	}
+	for _, entry := range l.Items {
+		if !isv(entry) {
+			return false
+		}
+	}
+	for _, sep := range l.Seps {
+		if !ist2(sep, token.COMMA, token.SEMICOLON) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *TypeIndexEntry) IsValid() bool {
+	return ist(e.LBrackToken, token.LBRACK) &&
+		isv(e.Key) &&
+		ist(e.RBrackToken, token.RBRACK) &&
+		ist(e.ColonToken, token.COLON) &&
+		isv(e.Value)
+}
+
+func (e *TypeFieldEntry) IsValid() bool {
+	return ist(e.NameToken, token.NAME) &&
+		ist(e.ColonToken, token.COLON) &&
+		isv(e.Value)
+}
+
+func (e *TypeValueEntry) IsValid() bool {
+	return isv(e.Value)
+}
+
+func (t *FunctionType) IsValid() bool {
+	return ist(t.LParenToken, token.LPAREN) &&
+		ist(t.RParenToken, token.RPAREN) &&
+		ist(t.ArrowToken, token.ARROW) &&
+		isv(t.Return)
+}
+
+func (t *ParamType) IsValid() bool {
+	return ist(t.NameToken, token.NAME) &&
+		ist(t.ColonToken, token.COLON) &&
+		isv(t.Type)
+}
+
+func (t *ParenType) IsValid() bool {
+	return ist(t.LParenToken, token.LPAREN) &&
+		isv(t.Value) &&
+		ist(t.RParenToken, token.RPAREN)
+}
+
+func (t *PackType) IsValid() bool {
+	return ist(t.LParenToken, token.LPAREN) &&
+		ist(t.RParenToken, token.RPAREN)
+}
+
+func (t *VariadicType) IsValid() bool {
+	return ist(t.VarArgToken, token.VARARG) &&
+		isv(t.Value)
+}
+
+func (t *GenericPackType) IsValid() bool {
+	return ist(t.NameToken, token.NAME) &&
+		ist(t.VarArgToken, token.VARARG)
+}
+
+func (t *OptionalType) IsValid() bool {
+	return isv(t.Value) &&
+		ist(t.QuestionToken, token.QUESTION)
+}
+
+func (t *UnionType) IsValid() bool {
+	return isOpTypeValid(t.LeadToken, t.Items, t.Seps, token.PIPE)
+}
+
+func (t *IntersectionType) IsValid() bool {
+	return isOpTypeValid(t.LeadToken, t.Items, t.Seps, token.AMPERSAND)
+}
+
+// isOpTypeValid returns whether the components of a UnionType or
+// IntersectionType are valid, where op is the type of the separating tokens.
+func isOpTypeValid(lead Token, items []Type, seps []Token, op token.Type) bool {
+	if !ist2(lead, op, token.INVALID) {
+		return false
+	}
+	if len(items) == 0 || len(seps) != len(items)-1 {
+		return false
+	}
+	for _, item := range items {
+		if !isv(item) {
+			return false
+		}
+	}
+	for _, sep := range seps {
+		if !ist(sep, op) {
+			return false
+		}
+	}
+	return true
+}
@@ -86,15 +86,45 @@ func Walk(v Visitor, node Node) {
 		}
 
 	case *NameList:
-		if tvok {
-			n := 0
-			for i := range node.Items {
+		n := 0
+		for i := range node.Items {
+			if tvok {
 				tv.VisitToken(node, n, &node.Items[i])
-				n++
-				if i < len(node.Seps) {
+			}
+			n++
+			if i < len(node.Attribs) && node.Attribs[i] != nil {
+				Walk(v, node.Attribs[i])
+			}
+			if i < len(node.Types) && node.Types[i] != nil {
+				Walk(v, node.Types[i])
+			}
+			if i < len(node.Seps) {
+				if tvok {
 					tv.VisitToken(node, n, &node.Seps[i])
-					n++
 				}
+				n++
+			}
+		}
+
+	case *Attrib:
+		if tvok {
+			tv.VisitToken(node, 0, &node.LAngleToken)
+			tv.VisitToken(node, 1, &node.NameToken)
+			tv.VisitToken(node, 2, &node.RAngleToken)
+		}
+
+	case *TypeAnnot:
+		if tvok {
+			tv.VisitToken(node, 0, &node.ColonToken)
+		}
+		if node.Type != nil {
+			Walk(v, node.Type)
+		}
+
+	case *BadExpr:
+		if tvok {
+			for i := range node.Tokens {
+				tv.VisitToken(node, i, &node.Tokens[i])
 			}
 		}
 
@@ -217,6 +247,11 @@ func Walk(v Visitor, node Node) {
 	case *FunctionExpr:
 		if tvok {
 			tv.VisitToken(node, 0, &node.FuncToken)
+		}
+		if node.Generics != nil {
+			Walk(v, node.Generics)
+		}
+		if tvok {
 			tv.VisitToken(node, 1, &node.LParenToken)
 		}
 		if node.Params != nil {
@@ -225,8 +260,16 @@ func Walk(v Visitor, node Node) {
 		if tvok {
 			tv.VisitToken(node, 2, &node.VarArgSepToken)
 			tv.VisitToken(node, 3, &node.VarArgToken)
+		}
+		if node.VarArgType != nil {
+			Walk(v, node.VarArgType)
+		}
+		if tvok {
 			tv.VisitToken(node, 4, &node.RParenToken)
 		}
+		if node.ReturnType != nil {
+			Walk(v, node.ReturnType)
+		}
 		Walk(v, &node.Body)
 		if tvok {
 			tv.VisitToken(node, 5, &node.EndToken)
@@ -275,6 +318,64 @@ func Walk(v Visitor, node Node) {
 			Walk(v, node.Args)
 		}
 
+	case *IfExpr:
+		if tvok {
+			tv.VisitToken(node, 0, &node.IfToken)
+		}
+		if node.Cond != nil {
+			Walk(v, node.Cond)
+		}
+		if tvok {
+			tv.VisitToken(node, 1, &node.ThenToken)
+		}
+		if node.Value != nil {
+			Walk(v, node.Value)
+		}
+		for i := range node.ElseIf {
+			Walk(v, &node.ElseIf[i])
+		}
+		if tvok {
+			tv.VisitToken(node, 2, &node.ElseToken)
+		}
+		if node.Else != nil {
+			Walk(v, node.Else)
+		}
+
+	case *ElseIfExprClause:
+		if tvok {
+			tv.VisitToken(node, 0, &node.ElseIfToken)
+		}
+		if node.Cond != nil {
+			Walk(v, node.Cond)
+		}
+		if tvok {
+			tv.VisitToken(node, 1, &node.ThenToken)
+		}
+		if node.Value != nil {
+			Walk(v, node.Value)
+		}
+
+	case *InterpExpr:
+		for i := range node.Segments {
+			if tvok {
+				tv.VisitToken(node, i, &node.Segments[i])
+			}
+			if i < len(node.Exprs) && node.Exprs[i] != nil {
+				Walk(v, node.Exprs[i])
+			}
+		}
+
+	case *AssertExpr:
+		if node.Value != nil {
+			Walk(v, node.Value)
+		}
+		if tvok {
+			tv.VisitToken(node, 0, &node.AssertToken)
+		}
+		if node.Type != nil {
+			Walk(v, node.Type)
+		}
+
 	case *ListArgs:
 		if tvok {
 			tv.VisitToken(node, 0, &node.LParenToken)
@@ -292,6 +393,18 @@ func Walk(v Visitor, node Node) {
 	case *StringArg:
 		Walk(v, &node.Value)
 
+	case *BadStmt:
+		if tvok {
+			for i := range node.Tokens {
+				tv.VisitToken(node, i, &node.Tokens[i])
+			}
+		}
+
+	case *MetaStmt:
+		if tvok {
+			tv.VisitToken(node, 0, &node.NameToken)
+		}
+
 	case *DoStmt:
 		if tvok {
 			tv.VisitToken(node, 0, &node.DoToken)
@@ -308,6 +421,17 @@ func Walk(v Visitor, node Node) {
 		}
 		Walk(v, &node.Right)
 
+	case *CompoundAssignStmt:
+		if node.Left != nil {
+			Walk(v, node.Left)
+		}
+		if tvok {
+			tv.VisitToken(node, 0, &node.OpToken)
+		}
+		if node.Right != nil {
+			Walk(v, node.Right)
+		}
+
 	case *CallStmt:
 		if node.Call != nil {
 			Walk(v, node.Call)
@@ -356,6 +480,11 @@ func Walk(v Visitor, node Node) {
 		if tvok {
 			tv.VisitToken(node, 0, &node.ForToken)
 			tv.VisitToken(node, 1, &node.NameToken)
+		}
+		if node.NameType != nil {
+			Walk(v, node.NameType)
+		}
+		if tvok {
 			tv.VisitToken(node, 2, &node.AssignToken)
 		}
 		if node.Min != nil {
@@ -442,6 +571,11 @@ func Walk(v Visitor, node Node) {
 			tv.VisitToken(node, 0, &node.LocalToken)
 			tv.VisitToken(node, 1, &node.Func.FuncToken)
 			tv.VisitToken(node, 2, &node.NameToken)
+		}
+		if node.Func.Generics != nil {
+			Walk(v, node.Func.Generics)
+		}
+		if tvok {
 			tv.VisitToken(node, 3, &node.Func.LParenToken)
 		}
 		if node.Func.Params != nil {
@@ -450,8 +584,16 @@ func Walk(v Visitor, node Node) {
 		if tvok {
 			tv.VisitToken(node, 4, &node.Func.VarArgSepToken)
 			tv.VisitToken(node, 5, &node.Func.VarArgToken)
+		}
+		if node.Func.VarArgType != nil {
+			Walk(v, node.Func.VarArgType)
+		}
+		if tvok {
 			tv.VisitToken(node, 6, &node.Func.RParenToken)
 		}
+		if node.Func.ReturnType != nil {
+			Walk(v, node.Func.ReturnType)
+		}
 		Walk(v, &node.Func.Body)
 		if tvok {
 			tv.VisitToken(node, 7, &node.Func.EndToken)
@@ -462,6 +604,9 @@ func Walk(v Visitor, node Node) {
 			tv.VisitToken(node, 0, &node.Func.FuncToken)
 		}
 		Walk(v, &node.Name)
+		if node.Func.Generics != nil {
+			Walk(v, node.Func.Generics)
+		}
 		if tvok {
 			tv.VisitToken(node, 2, &node.Func.LParenToken)
 		}
@@ -471,8 +616,16 @@ func Walk(v Visitor, node Node) {
 		if tvok {
 			tv.VisitToken(node, 3, &node.Func.VarArgSepToken)
 			tv.VisitToken(node, 4, &node.Func.VarArgToken)
+		}
+		if node.Func.VarArgType != nil {
+			Walk(v, node.Func.VarArgType)
+		}
+		if tvok {
 			tv.VisitToken(node, 5, &node.Func.RParenToken)
 		}
+		if node.Func.ReturnType != nil {
+			Walk(v, node.Func.ReturnType)
+		}
 		Walk(v, &node.Func.Body)
 		if tvok {
 			tv.VisitToken(node, 6, &node.Func.EndToken)
@@ -498,6 +651,257 @@ func Walk(v Visitor, node Node) {
 			tv.VisitToken(node, 0, &node.BreakToken)
 		}
 
+	case *ContinueStmt:
+		if tvok {
+			tv.VisitToken(node, 0, &node.ContinueToken)
+		}
+
+	case *GotoStmt:
+		if tvok {
+			tv.VisitToken(node, 0, &node.GotoToken)
+			tv.VisitToken(node, 1, &node.NameToken)
+		}
+
+	case *LabelStmt:
+		if tvok {
+			tv.VisitToken(node, 0, &node.LColonToken)
+			tv.VisitToken(node, 1, &node.NameToken)
+			tv.VisitToken(node, 2, &node.RColonToken)
+		}
+
+	case *TypeStmt:
+		if tvok {
+			tv.VisitToken(node, 0, &node.ExportToken)
+			tv.VisitToken(node, 1, &node.TypeToken)
+			tv.VisitToken(node, 2, &node.NameToken)
+		}
+		if node.Generics != nil {
+			Walk(v, node.Generics)
+		}
+		if tvok {
+			tv.VisitToken(node, 3, &node.AssignToken)
+		}
+		if node.Type != nil {
+			Walk(v, node.Type)
+		}
+
+	case *TypeList:
+		if tvok {
+			for i, item := range node.Items {
+				if item != nil {
+					Walk(v, item)
+				}
+				if i < len(node.Seps) {
+					tv.VisitToken(node, i, &node.Seps[i])
+				}
+			}
+		} else {
+			for _, item := range node.Items {
+				if item != nil {
+					Walk(v, item)
+				}
+			}
+		}
+
+	case *GenericList:
+		if tvok {
+			tv.VisitToken(node, 0, &node.LAngleToken)
+		}
+		n := 1
+		for i := range node.Items {
+			Walk(v, &node.Items[i])
+			if i < len(node.Seps) {
+				if tvok {
+					tv.VisitToken(node, n, &node.Seps[i])
+				}
+				n++
+			}
+		}
+		if tvok {
+			tv.VisitToken(node, n, &node.RAngleToken)
+		}
+
+	case *GenericParam:
+		if tvok {
+			tv.VisitToken(node, 0, &node.NameToken)
+			tv.VisitToken(node, 1, &node.VarArgToken)
+			tv.VisitToken(node, 2, &node.AssignToken)
+		}
+		if node.Default != nil {
+			Walk(v, node.Default)
+		}
+
+	case *NamedType:
+		if tvok {
+			tv.VisitToken(node, 0, &node.ModuleToken)
+			tv.VisitToken(node, 1, &node.DotToken)
+			tv.VisitToken(node, 2, &node.NameToken)
+		}
+		if node.Params != nil {
+			Walk(v, node.Params)
+		}
+
+	case *TypeParams:
+		if tvok {
+			tv.VisitToken(node, 0, &node.LAngleToken)
+		}
+		if node.Types != nil {
+			Walk(v, node.Types)
+		}
+		if tvok {
+			tv.VisitToken(node, 1, &node.RAngleToken)
+		}
+
+	case *LiteralType:
+		if tvok {
+			tv.VisitToken(node, 0, &node.LiteralToken)
+		}
+
+	case *TypeofType:
+		if tvok {
+			tv.VisitToken(node, 0, &node.TypeofToken)
+			tv.VisitToken(node, 1, &node.LParenToken)
+		}
+		if node.Value != nil {
+			Walk(v, node.Value)
+		}
+		if tvok {
+			tv.VisitToken(node, 2, &node.RParenToken)
+		}
+
+	case *TableType:
+		if tvok {
+			tv.VisitToken(node, 0, &node.LBraceToken)
+		}
+		Walk(v, &node.Entries)
+		if tvok {
+			tv.VisitToken(node, 1, &node.RBraceToken)
+		}
+
+	case *TypeEntryList:
+		if tvok {
+			for i, entry := range node.Items {
+				if entry != nil {
+					Walk(v, entry)
+				}
+				if i < len(node.Seps) {
+					tv.VisitToken(node, i, &node.Seps[i])
+				}
+			}
+		} else {
+			for _, entry := range node.Items {
+				if entry != nil {
+					Walk(v, entry)
+				}
+			}
+		}
+
+	case *TypeIndexEntry:
+		if tvok {
+			tv.VisitToken(node, 0, &node.LBrackToken)
+		}
+		if node.Key != nil {
+			Walk(v, node.Key)
+		}
+		if tvok {
+			tv.VisitToken(node, 1, &node.RBrackToken)
+			tv.VisitToken(node, 2, &node.ColonToken)
+		}
+		if node.Value != nil {
+			Walk(v, node.Value)
+		}
+
+	case *TypeFieldEntry:
+		if tvok {
+			tv.VisitToken(node, 0, &node.NameToken)
+			tv.VisitToken(node, 1, &node.ColonToken)
+		}
+		if node.Value != nil {
+			Walk(v, node.Value)
+		}
+
+	case *TypeValueEntry:
+		if node.Value != nil {
+			Walk(v, node.Value)
+		}
+
+	case *FunctionType:
+		if node.Generics != nil {
+			Walk(v, node.Generics)
+		}
+		if tvok {
+			tv.VisitToken(node, 0, &node.LParenToken)
+		}
+		if node.Params != nil {
+			Walk(v, node.Params)
+		}
+		if tvok {
+			tv.VisitToken(node, 1, &node.RParenToken)
+			tv.VisitToken(node, 2, &node.ArrowToken)
+		}
+		if node.Return != nil {
+			Walk(v, node.Return)
+		}
+
+	case *ParamType:
+		if tvok {
+			tv.VisitToken(node, 0, &node.NameToken)
+			tv.VisitToken(node, 1, &node.ColonToken)
+		}
+		if node.Type != nil {
+			Walk(v, node.Type)
+		}
+
+	case *ParenType:
+		if tvok {
+			tv.VisitToken(node, 0, &node.LParenToken)
+		}
+		if node.Value != nil {
+			Walk(v, node.Value)
+		}
+		if tvok {
+			tv.VisitToken(node, 1, &node.RParenToken)
+		}
+
+	case *PackType:
+		if tvok {
+			tv.VisitToken(node, 0, &node.LParenToken)
+		}
+		if node.Types != nil {
+			Walk(v, node.Types)
+		}
+		if tvok {
+			tv.VisitToken(node, 1, &node.RParenToken)
+		}
+
+	case *VariadicType:
+		if tvok {
+			tv.VisitToken(node, 0, &node.VarArgToken)
+		}
+		if node.Value != nil {
+			Walk(v, node.Value)
+		}
+
+	case *GenericPackType:
+		if tvok {
+			tv.VisitToken(node, 0, &node.NameToken)
+			tv.VisitToken(node, 1, &node.VarArgToken)
+		}
+
+	case *OptionalType:
+		if node.Value != nil {
+			Walk(v, node.Value)
+		}
+		if tvok {
+			tv.VisitToken(node, 0, &node.QuestionToken)
+		}
+
+	case *UnionType:
+		walkOpType(v, tv, tvok, node, &node.LeadToken, node.Items, node.Seps)
+
+	case *IntersectionType:
+		walkOpType(v, tv, tvok, node, &node.LeadToken, node.Items, node.Seps)
+
 	case *ReturnStmt:
 		if tvok {
 			tv.VisitToken(node, 0, &node.ReturnToken)
@@ -510,3 +914,19 @@ func Walk(v Visitor, node Node) {
 		panic(fmt.Sprintf("unexpected node type %T", node))
 	}
 }
+
+// walkOpType walks the components of a UnionType or IntersectionType. The lead
+// token is n=0, and each separator is n=i+1.
+func walkOpType(v Visitor, tv TokenVisitor, tvok bool, node Node, lead *Token, items []Type, seps []Token) {
+	if tvok {
+		tv.VisitToken(node, 0, lead)
+	}
+	for i, item := range items {
+		if item != nil {
+			Walk(v, item)
+		}
+		if tvok && i < len(seps) {
+			tv.VisitToken(node, i+1, &seps[i])
+		}
+	}
+}